@@ -0,0 +1,154 @@
+// Command file-plugin is an example plugin of the exec-plugin provider. It
+// serves the audit events of an audit log file of kube-apiserver, which has
+// an audit.k8s.io/v1 event per line, e.g. the file of --audit-log-path.
+//
+// Config:
+//
+//	file: /var/log/kubernetes/audit.log
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/plugin"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	k8saudit "k8s.io/apiserver/pkg/apis/audit"
+)
+
+// progressInterval is the number of lines scanned between progress reports.
+const progressInterval = 10000
+
+type filePlugin struct {
+	file string
+}
+
+func (f *filePlugin) Init(_ context.Context, config map[string]any) error {
+	f.file, _ = config["file"].(string)
+	if f.file == "" {
+		return errors.New("file is required")
+	}
+	if _, err := os.Stat(f.file); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (f *filePlugin) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		Filters:        provider.AllFilters,
+		ResponseStatus: provider.FieldRecorded,
+		Bodies:         provider.FieldRecorded,
+	}
+}
+
+func (f *filePlugin) QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	var result types.AuditLogResult
+	file, err := os.Open(f.file)
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+
+	maxBytes := provider.MaxBytesScanned(ctx)
+	stats := &types.QueryStats{}
+	var entries []types.AuditLogEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		stats.BytesScanned += int64(len(scanner.Bytes())) + 1
+		stats.RecordsScanned++
+		if maxBytes > 0 && stats.BytesScanned > maxBytes {
+			return result, &provider.ScanLimitError{Limit: maxBytes, Scanned: stats.BytesScanned}
+		}
+
+		var event k8saudit.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			log.Printf("skipping invalid audit event: %v", err)
+			continue
+		}
+		if matches(event, params) {
+			entries = append(entries, types.AuditLogEntry(event))
+		}
+		if stats.RecordsScanned%progressInterval == 0 {
+			provider.ReportProgress(ctx, provider.Progress{
+				Message:        fmt.Sprintf("scanning %s", f.file),
+				RecordsScanned: stats.RecordsScanned,
+				RecordsMatched: int64(len(entries)),
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+
+	// Newest first, like the other providers.
+	slices.Reverse(entries)
+	stats.RecordsMatched = int64(len(entries))
+	if params.Limit > 0 && len(entries) > params.Limit {
+		entries = entries[:params.Limit]
+	}
+	result.Entries = entries
+	result.Total = len(entries)
+	result.Stats = stats
+	result.ProviderQuery = fmt.Sprintf("scan %s", f.file)
+	return result, nil
+}
+
+func matches(event k8saudit.Event, params types.QueryAuditLogParams) bool {
+	ts := event.RequestReceivedTimestamp.Time
+	if !params.StartTime.IsZero() && ts.Before(params.StartTime.Time) {
+		return false
+	}
+	if !params.EndTime.IsZero() && ts.After(params.EndTime.Time) {
+		return false
+	}
+	if params.AuditID != "" && string(event.AuditID) != params.AuditID {
+		return false
+	}
+	if !matchKeyword(params.User, event.User.Username) {
+		return false
+	}
+	if len(params.Verbs) > 0 && !slices.Contains(params.Verbs, event.Verb) {
+		return false
+	}
+
+	var namespace, resource, name string
+	if ref := event.ObjectRef; ref != nil {
+		namespace, resource, name = ref.Namespace, ref.Resource, ref.Name
+	}
+	if !matchKeyword(params.Namespace, namespace) || !matchKeyword(params.ResourceName, name) {
+		return false
+	}
+	return len(params.ResourceTypes) == 0 || slices.Contains(params.ResourceTypes, resource)
+}
+
+// matchKeyword matches value with the keyword of a filter, a keyword that
+// ends with * matches the values with the prefix.
+func matchKeyword(keyword, value string) bool {
+	switch {
+	case keyword == "" || keyword == "*":
+		return true
+	case strings.HasSuffix(keyword, "*"):
+		return strings.HasPrefix(value, strings.TrimSuffix(keyword, "*"))
+	default:
+		return value == keyword
+	}
+}
+
+func main() {
+	if err := plugin.Serve(&filePlugin{}); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"errors"
 	"log"
+	"os"
 
 	"github.com/mozillazg/kube-audit-mcp/pkg/cli"
 )
 
 func main() {
 	if err := cli.Run(); err != nil {
+		var exitErr *cli.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		log.Fatal(err)
 	}
 }
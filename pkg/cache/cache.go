@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultMaxEntries     = 256
+	defaultMaxDiskEntries = 4096
+	defaultTTL            = time.Minute
+	defaultIngestionDelay = 5 * time.Minute
+)
+
+type Config struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// MaxEntries is the maximum number of results kept in memory.
+	MaxEntries int `yaml:"max_entries,omitempty" json:"max_entries,omitempty"`
+	// Dir enables the on-disk cache in the given directory. Only results of
+	// immutable time windows are written to disk.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+	// MaxDiskEntries is the maximum number of results kept on disk.
+	// Defaults to 4096.
+	MaxDiskEntries int `yaml:"max_disk_entries,omitempty" json:"max_disk_entries,omitempty"`
+	// TTL is used for queries whose time window is still receiving new
+	// events, e.g. "the last 30 minutes". Defaults to 1m.
+	TTL metav1.Duration `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+	// IngestionDelay is how long it takes for audit events to be queryable.
+	// Queries that end before now-IngestionDelay are cached indefinitely.
+	// Defaults to 5m.
+	IngestionDelay metav1.Duration `yaml:"ingestion_delay,omitempty" json:"ingestion_delay,omitempty"`
+}
+
+type Cache struct {
+	maxEntries     int
+	ttl            time.Duration
+	ingestionDelay time.Duration
+	disk           *diskCache
+
+	entries map[string]*list.Element
+	lru     *list.List
+	mu      sync.Mutex
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type item struct {
+	key       string
+	result    types.AuditLogResult
+	expiresAt time.Time
+}
+
+// Variable for mocking in tests
+var now = time.Now
+
+func New(config *Config) (*Cache, error) {
+	if config == nil || !config.Enabled {
+		return nil, nil
+	}
+
+	c := &Cache{
+		maxEntries:     config.MaxEntries,
+		ttl:            config.TTL.Duration,
+		ingestionDelay: config.IngestionDelay.Duration,
+		entries:        make(map[string]*list.Element),
+		lru:            list.New(),
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = defaultMaxEntries
+	}
+	if c.ttl <= 0 {
+		c.ttl = defaultTTL
+	}
+	if c.ingestionDelay <= 0 {
+		c.ingestionDelay = defaultIngestionDelay
+	}
+	if config.Dir != "" {
+		maxDiskEntries := config.MaxDiskEntries
+		if maxDiskEntries <= 0 {
+			maxDiskEntries = defaultMaxDiskEntries
+		}
+		disk, err := newDiskCache(config.Dir, maxDiskEntries)
+		if err != nil {
+			return nil, err
+		}
+		c.disk = disk
+	}
+
+	return c, nil
+}
+
+func (c *Cache) Get(key string) (types.AuditLogResult, bool) {
+	c.mu.Lock()
+	elem, ok := c.entries[key]
+	if ok {
+		it := elem.Value.(*item)
+		if it.expired() {
+			c.removeElement(elem)
+			ok = false
+		} else {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			c.hits.Add(1)
+			return it.result.DeepCopy(), true
+		}
+	}
+	c.mu.Unlock()
+
+	if c.disk != nil {
+		if it, ok := c.disk.get(key); ok {
+			c.add(it)
+			c.hits.Add(1)
+			return it.result.DeepCopy(), true
+		}
+	}
+
+	c.misses.Add(1)
+	return types.AuditLogResult{}, false
+}
+
+// Set caches the result with an expiry derived from the time window of the
+// query.
+func (c *Cache) Set(key string, params types.QueryAuditLogParams, result types.AuditLogResult) {
+	it := &item{
+		key:    key,
+		result: result.DeepCopy(),
+	}
+	immutable := c.Immutable(params)
+	if !immutable {
+		it.expiresAt = now().Add(c.ttl)
+	}
+	c.add(it)
+
+	// Results that expire after the TTL are not worth persisting, and would
+	// leave a file behind for every relative time window.
+	if c.disk != nil && immutable {
+		c.disk.set(it)
+	}
+}
+
+// Immutable reports whether no new events can show up in the time window of
+// the query any more.
+func (c *Cache) Immutable(params types.QueryAuditLogParams) bool {
+	return !params.EndTime.IsZero() && params.EndTime.Before(now().Add(-c.ingestionDelay))
+}
+
+func (c *Cache) Stats(hit bool) *types.CacheStats {
+	return &types.CacheStats{
+		Hit:    hit,
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+}
+
+func (c *Cache) add(it *item) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[it.key]; ok {
+		elem.Value = it
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[it.key] = c.lru.PushFront(it)
+	for c.lru.Len() > c.maxEntries {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *Cache) removeElement(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*item).key)
+}
+
+func (it *item) expired() bool {
+	return !it.expiresAt.IsZero() && now().After(it.expiresAt)
+}
@@ -0,0 +1,248 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	k8sauth "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type mockProvider struct {
+	calls  int
+	result types.AuditLogResult
+	err    error
+}
+
+func (m *mockProvider) QueryAuditLog(_ context.Context, _ types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	m.calls++
+	return m.result.DeepCopy(), m.err
+}
+
+func (m *mockProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{}
+}
+
+func setNow(t *testing.T, tm time.Time) {
+	orig := now
+	now = func() time.Time { return tm }
+	t.Cleanup(func() { now = orig })
+}
+
+func newResult() types.AuditLogResult {
+	return types.AuditLogResult{
+		Entries: []types.AuditLogEntry{
+			{
+				AuditID:       "audit-1",
+				Verb:          "delete",
+				User:          k8sauth.UserInfo{Username: "alice"},
+				RequestObject: &runtime.Unknown{Raw: []byte(`{"kind":"DeleteOptions"}`)},
+				StageTimestamp: metav1.NewMicroTime(
+					time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)),
+			},
+		},
+		Total: 1,
+	}
+}
+
+func TestNew(t *testing.T) {
+	c, err := New(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	c, err = New(&Config{Enabled: true})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultMaxEntries, c.maxEntries)
+	assert.Equal(t, defaultTTL, c.ttl)
+	assert.Equal(t, defaultIngestionDelay, c.ingestionDelay)
+}
+
+func TestProvider_QueryAuditLog(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 10, 0, time.UTC)
+	setNow(t, base)
+
+	c, err := New(&Config{Enabled: true})
+	assert.NoError(t, err)
+	next := &mockProvider{result: newResult()}
+	p := NewProvider(next, c, "prod", "hash")
+
+	params := types.QueryAuditLogParams{
+		StartTime: types.NewTimeParam(base.Add(-time.Hour)),
+		EndTime:   types.NewTimeParam(base),
+		Verbs:     []string{"delete", "create"},
+		Limit:     10,
+	}
+
+	result, err := p.QueryAuditLog(context.Background(), params)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CacheStats{Hit: false, Hits: 0, Misses: 1}, result.Cache)
+
+	// Mutating the returned result must not affect the cached one
+	result.Entries[0].User.Username = "changed"
+
+	// Same relative window a few seconds later, verbs in a different order
+	setNow(t, base.Add(20*time.Second))
+	params.StartTime = types.NewTimeParam(base.Add(20*time.Second - time.Hour))
+	params.EndTime = types.NewTimeParam(base.Add(20 * time.Second))
+	params.Verbs = []string{"create", "delete"}
+	result, err = p.QueryAuditLog(context.Background(), params)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CacheStats{Hit: true, Hits: 1, Misses: 1}, result.Cache)
+	assert.Equal(t, "alice", result.Entries[0].User.Username)
+	assert.Equal(t, 1, next.calls)
+
+	// The short TTL expired
+	setNow(t, base.Add(2*time.Minute))
+	params.StartTime = types.NewTimeParam(base.Add(20*time.Second - time.Hour))
+	params.EndTime = types.NewTimeParam(base.Add(20 * time.Second))
+	result, err = p.QueryAuditLog(context.Background(), params)
+	assert.NoError(t, err)
+	assert.False(t, result.Cache.Hit)
+	assert.Equal(t, 2, next.calls)
+
+	// A different cluster does not share the entry
+	other := NewProvider(next, c, "dev", "hash")
+	_, err = other.QueryAuditLog(context.Background(), params)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, next.calls)
+
+	// The same cluster with another config does not share the entry
+	other = NewProvider(next, c, "prod", "other-hash")
+	_, err = other.QueryAuditLog(context.Background(), params)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, next.calls)
+}
+
+func TestProvider_QueryAuditLog_Immutable(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	setNow(t, base)
+
+	c, err := New(&Config{Enabled: true})
+	assert.NoError(t, err)
+	next := &mockProvider{result: newResult()}
+	p := NewProvider(next, c, "prod", "hash")
+
+	params := types.QueryAuditLogParams{
+		StartTime: types.NewTimeParam(base.Add(-48 * time.Hour)),
+		EndTime:   types.NewTimeParam(base.Add(-24 * time.Hour)),
+		Limit:     10,
+	}
+	assert.True(t, c.Immutable(params))
+
+	_, err = p.QueryAuditLog(context.Background(), params)
+	assert.NoError(t, err)
+
+	setNow(t, base.Add(30*24*time.Hour))
+	result, err := p.QueryAuditLog(context.Background(), params)
+	assert.NoError(t, err)
+	assert.True(t, result.Cache.Hit)
+	assert.Equal(t, 1, next.calls)
+}
+
+func TestProvider_QueryAuditLog_Error(t *testing.T) {
+	c, err := New(&Config{Enabled: true})
+	assert.NoError(t, err)
+	next := &mockProvider{err: errors.New("boom")}
+	p := NewProvider(next, c, "prod", "hash")
+
+	_, err = p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{Limit: 10})
+	assert.EqualError(t, err, "boom")
+	_, err = p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{Limit: 10})
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, 2, next.calls)
+}
+
+func TestCache_LRU(t *testing.T) {
+	c, err := New(&Config{Enabled: true, MaxEntries: 2})
+	assert.NoError(t, err)
+
+	c.Set("a", types.QueryAuditLogParams{}, newResult())
+	c.Set("b", types.QueryAuditLogParams{}, newResult())
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	c.Set("c", types.QueryAuditLogParams{}, newResult())
+
+	_, ok = c.Get("b")
+	assert.False(t, ok, "least recently used entry should be evicted")
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+}
+
+func TestCache_Disk(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	setNow(t, base)
+	dir := t.TempDir()
+	params := types.QueryAuditLogParams{
+		StartTime: types.NewTimeParam(base.Add(-48 * time.Hour)),
+		EndTime:   types.NewTimeParam(base.Add(-24 * time.Hour)),
+	}
+
+	c, err := New(&Config{Enabled: true, Dir: dir})
+	assert.NoError(t, err)
+	result := newResult()
+	result.ProviderQuery = "verb: delete"
+	c.Set("key", params, result)
+
+	// A new cache instance loads the result from disk
+	c, err = New(&Config{Enabled: true, Dir: dir})
+	assert.NoError(t, err)
+	cached, ok := c.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "verb: delete", cached.ProviderQuery)
+	assert.Equal(t, 1, len(cached.Entries))
+	assert.Equal(t, "alice", cached.Entries[0].User.Username)
+	assert.JSONEq(t, `{"kind":"DeleteOptions"}`, string(cached.Entries[0].RequestObject.Raw))
+	assert.True(t, cached.Entries[0].StageTimestamp.Equal(
+		&metav1.MicroTime{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}))
+
+	_, ok = c.Get("other")
+	assert.False(t, ok)
+}
+
+func TestCache_Disk_OnlyImmutable(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	setNow(t, base)
+	dir := t.TempDir()
+
+	c, err := New(&Config{Enabled: true, Dir: dir})
+	assert.NoError(t, err)
+	c.Set("recent", types.QueryAuditLogParams{
+		StartTime: types.NewTimeParam(base.Add(-time.Hour)),
+	}, newResult())
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestDiskCache_Sweep(t *testing.T) {
+	dir := t.TempDir()
+	d, err := newDiskCache(dir, 2)
+	assert.NoError(t, err)
+
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, key := range []string{"a", "b", "c"} {
+		d.set(&item{key: key, result: newResult()})
+		modTime := base.Add(time.Duration(i) * time.Minute)
+		assert.NoError(t, os.Chtimes(d.path(key), modTime, modTime))
+	}
+	d.set(&item{key: "d", result: newResult()})
+
+	_, ok := d.get("a")
+	assert.False(t, ok, "least recently written entries should be removed")
+	_, ok = d.get("b")
+	assert.False(t, ok, "least recently written entries should be removed")
+	_, ok = d.get("c")
+	assert.True(t, ok)
+	_, ok = d.get("d")
+	assert.True(t, ok)
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+)
+
+// diskCache persists cached results as one JSON file per key, so that they
+// survive restarts of the MCP server. It keeps at most maxEntries files and
+// removes the least recently written ones first.
+type diskCache struct {
+	dir        string
+	maxEntries int
+	mu         sync.Mutex
+}
+
+type diskRecord struct {
+	Key           string               `json:"key"`
+	ExpiresAt     time.Time            `json:"expires_at,omitempty"`
+	ProviderQuery string               `json:"provider_query,omitempty"`
+	Result        types.AuditLogResult `json:"result"`
+}
+
+func newDiskCache(dir string, maxEntries int) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create cache directory %s: %w", dir, err)
+	}
+	return &diskCache{dir: dir, maxEntries: maxEntries}, nil
+}
+
+func (d *diskCache) get(key string) (*item, bool) {
+	path := d.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var record diskRecord
+	if err := json.Unmarshal(data, &record); err != nil || record.Key != key {
+		log.Printf("ignoring invalid cache file %s: %v", path, err)
+		return nil, false
+	}
+	it := &item{
+		key:       record.Key,
+		result:    record.Result,
+		expiresAt: record.ExpiresAt,
+	}
+	it.result.ProviderQuery = record.ProviderQuery
+	if it.expired() {
+		_ = os.Remove(path)
+		return nil, false
+	}
+	return it, true
+}
+
+func (d *diskCache) set(it *item) {
+	record := diskRecord{
+		Key:           it.key,
+		ExpiresAt:     it.expiresAt,
+		ProviderQuery: it.result.ProviderQuery,
+		Result:        it.result,
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("failed to encode cache record: %v", err)
+		return
+	}
+
+	path := d.path(it.key)
+	tmp, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		log.Printf("failed to write cache file: %v", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		log.Printf("failed to write cache file: %v", err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		log.Printf("failed to write cache file: %v", err)
+		return
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		log.Printf("failed to write cache file: %v", err)
+		return
+	}
+	d.sweep()
+}
+
+// sweep removes the least recently written cache files until at most
+// maxEntries are left.
+func (d *diskCache) sweep() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dirEntries, err := os.ReadDir(d.dir)
+	if err != nil {
+		log.Printf("failed to list cache directory: %v", err)
+		return
+	}
+	type cacheFile struct {
+		name    string
+		modTime time.Time
+	}
+	var files []cacheFile
+	for _, e := range dirEntries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{name: e.Name(), modTime: info.ModTime()})
+	}
+	if len(files) <= d.maxEntries {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for _, f := range files[:len(files)-d.maxEntries] {
+		if err := os.Remove(filepath.Join(d.dir, f.name)); err != nil && !os.IsNotExist(err) {
+			log.Printf("failed to remove cache file: %v", err)
+		}
+	}
+}
+
+func (d *diskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+)
+
+// Provider serves repeated queries of the wrapped provider from the cache.
+type Provider struct {
+	next       provider.Provider
+	cache      *Cache
+	cluster    string
+	configHash string
+}
+
+var _ provider.Provider = (*Provider)(nil)
+
+// NewProvider returns the caching provider of the cluster. configHash
+// identifies the config of the provider and of the post-processing of its
+// results, e.g. the redaction rules, so that the cached results are not
+// served when the cluster is pointed at another backend or the rules change.
+func NewProvider(next provider.Provider, cache *Cache, cluster, configHash string) *Provider {
+	return &Provider{
+		next:       next,
+		cache:      cache,
+		cluster:    cluster,
+		configHash: configHash,
+	}
+}
+
+// Capabilities returns the capabilities of the wrapped provider.
+func (p *Provider) Capabilities() provider.Capabilities {
+	return p.next.Capabilities()
+}
+
+// Close closes the wrapped provider.
+func (p *Provider) Close() error {
+	return provider.Close(p.next)
+}
+
+func (p *Provider) QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	key := p.key(params)
+	if result, ok := p.cache.Get(key); ok {
+		result.Cache = p.cache.Stats(true)
+		return result, nil
+	}
+
+	result, err := p.next.QueryAuditLog(ctx, params)
+	if err != nil {
+		return result, err
+	}
+	if !result.Partial {
+		p.cache.Set(key, params, result)
+	}
+	result.Cache = p.cache.Stats(false)
+
+	return result, nil
+}
+
+type cacheKey struct {
+	Cluster       string   `json:"cluster"`
+	ConfigHash    string   `json:"config_hash,omitempty"`
+	StartTime     int64    `json:"start_time"`
+	EndTime       int64    `json:"end_time"`
+	User          string   `json:"user,omitempty"`
+	Namespace     string   `json:"namespace,omitempty"`
+	Verbs         []string `json:"verbs,omitempty"`
+	ResourceTypes []string `json:"resource_types,omitempty"`
+	ResourceName  string   `json:"resource_name,omitempty"`
+	AuditID       string   `json:"audit_id,omitempty"`
+	Limit         int      `json:"limit"`
+}
+
+// key normalizes the params into a cache key. The time window of queries
+// that are still receiving new events is truncated to the TTL, so that
+// "the last N minutes" queries issued within the TTL share the same key.
+func (p *Provider) key(params types.QueryAuditLogParams) string {
+	start, end := params.StartTime.Time, params.EndTime.Time
+	if !p.cache.Immutable(params) {
+		start = start.Truncate(p.cache.ttl)
+		end = end.Truncate(p.cache.ttl)
+	}
+
+	key := cacheKey{
+		Cluster:       p.cluster,
+		ConfigHash:    p.configHash,
+		StartTime:     unixOrZero(start),
+		EndTime:       unixOrZero(end),
+		User:          params.User,
+		Namespace:     params.Namespace,
+		Verbs:         sortedCopy(params.Verbs),
+		ResourceTypes: sortedCopy(params.ResourceTypes),
+		ResourceName:  params.ResourceName,
+		AuditID:       params.AuditID,
+		Limit:         params.Limit,
+	}
+	data, _ := json.Marshal(key)
+	return string(data)
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func sortedCopy(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return slices.Compact(s)
+}
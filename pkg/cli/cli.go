@@ -27,6 +27,9 @@ More info: https://github.com/mozillazg/kube-audit-mcp`,
 func init() {
 	rootCmd.AddCommand(mcpCmd)
 	rootCmd.AddCommand(sampleConfCmd)
+	rootCmd.AddCommand(revealCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(doctorCmd)
 	rootCmd.AddCommand(versionCmd)
 	testcmd.Registry(rootCmd)
 
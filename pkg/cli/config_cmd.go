@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+var configFile string
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the configuration file.",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the configuration file.",
+	Long: `Validate the configuration file and the files it includes.
+
+Unknown fields, duplicate cluster names and the settings of each enabled cluster
+are checked, and the providers are created like the MCP server does.
+The command exits with status 1 when the configuration is invalid.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return runConfigValidateCmd(cmd)
+	},
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the configuration file.",
+	Long: `Print the JSON Schema of the configuration file.
+
+The schema can be used by editors to validate and complete the configuration
+file, e.g. with the yaml-language-server modeline:
+
+  # yaml-language-server: $schema=./config.schema.json`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return runConfigSchemaCmd(cmd)
+	},
+}
+
+type configDiscoverOptions struct {
+	kubeconfig string
+	output     string
+}
+
+var discoverOpts configDiscoverOptions
+
+var configDiscoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Generate the clusters of the configuration from kubeconfig.",
+	Long: `Generate the clusters of the configuration from the contexts of kubeconfig.
+
+The provider of each context is inferred from the server URL, the names and the
+exec plugin of the context:
+
+  EKS  CloudWatch Logs log group /aws/eks/<cluster-name>/cluster
+  GKE  Cloud Logging logs of the project and the cluster
+  ACK  SLS project k8s-log-<cluster-id> and logstore audit-<cluster-id>
+
+The contexts whose provider can not be inferred are reported on stderr.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return runConfigDiscoverCmd(cmd, discoverOpts)
+	},
+}
+
+func init() {
+	configDiscoverCmd.Flags().StringVar(
+		&discoverOpts.kubeconfig, "kubeconfig", "",
+		"Path to the kubeconfig files, defaults to $KUBECONFIG or ~/.kube/config.")
+	configDiscoverCmd.Flags().StringVarP(
+		&discoverOpts.output, "output", "o", "",
+		"Write the configuration to this file instead of stdout.")
+
+	configCmd.PersistentFlags().StringVarP(
+		&configFile, "config", "c",
+		config.ShortHomePath(config.DefaultConfigFile()),
+		"Path to the configuration file.")
+
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configDiscoverCmd)
+	configCmd.AddCommand(configSchemaCmd)
+}
+
+func runConfigValidateCmd(cmd *cobra.Command) error {
+	cfgPath, err := config.ExpandPath(configFile)
+	if err != nil {
+		return fmt.Errorf("expanding config path: %+v", err)
+	}
+
+	out := cmd.OutOrStdout()
+	errs := config.ValidateFile(cfgPath)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+		fmt.Fprintf(out, "configuration file %s is invalid: %d error(s)\n", cfgPath, len(errs))
+		return &ExitError{Code: 1}
+	}
+
+	fmt.Fprintf(out, "configuration file %s is valid\n", cfgPath)
+	return nil
+}
+
+func runConfigSchemaCmd(cmd *cobra.Command) error {
+	data, err := json.MarshalIndent(config.Schema(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal schema: %w", err)
+	}
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), string(data))
+	return err
+}
+
+func runConfigDiscoverCmd(cmd *cobra.Command, opts configDiscoverOptions) error {
+	files, err := config.KubeconfigFiles(opts.kubeconfig)
+	if err != nil {
+		return err
+	}
+	result, err := config.DiscoverClusters(files)
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(result.Skipped)) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "skipping context %s: %s\n", name, result.Skipped[name])
+	}
+	if len(result.Clusters) == 0 {
+		return errors.New("no clusters discovered from kubeconfig")
+	}
+
+	data, err := yaml.Marshal(&config.Config{
+		DefaultCluster: result.CurrentCluster,
+		Clusters:       result.Clusters,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal config: %w", err)
+	}
+
+	if opts.output == "" {
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
+	}
+	f, err := os.OpenFile(opts.output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("create output file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("write output file: %w", err)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "%d clusters are written to %s\n", len(result.Clusters), opts.output)
+	return nil
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/mozillazg/kube-audit-mcp/pkg/doctor"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+type doctorOptions struct {
+	config  string
+	cluster string
+	since   time.Duration
+	maxAge  time.Duration
+	timeout time.Duration
+}
+
+var doctorOpts doctorOptions
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the access to the audit logs of each cluster.",
+	Long: `Check the access to the audit logs of each cluster.
+
+For each enabled cluster, the provider is created, the credentials are checked,
+an audit log query is run, and the age of the newest audit event is reported.
+
+Exit status:
+  0  all checks passed
+  1  a check failed or the configuration could not be loaded
+  2  no check failed, but there are warnings`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return runDoctorCmd(cmd, doctorOpts)
+	},
+}
+
+func init() {
+	doctorCmd.Flags().StringVarP(
+		&doctorOpts.config, "config", "c",
+		config.ShortHomePath(config.DefaultConfigFile()),
+		"Path to the configuration file.")
+	doctorCmd.Flags().StringVar(
+		&doctorOpts.cluster, "cluster", "",
+		"Only check the cluster with this name or alias.")
+	doctorCmd.Flags().DurationVar(
+		&doctorOpts.since, "since", 24*time.Hour,
+		"Time range that is searched for the newest audit event.")
+	doctorCmd.Flags().DurationVar(
+		&doctorOpts.maxAge, "max-age", time.Hour,
+		"Warn when the newest audit event is older than this.")
+	doctorCmd.Flags().DurationVar(
+		&doctorOpts.timeout, "timeout", time.Minute,
+		"Timeout of the checks of each cluster.")
+}
+
+func runDoctorCmd(cmd *cobra.Command, opts doctorOptions) error {
+	cfgPath, err := config.ExpandPath(opts.config)
+	if err != nil {
+		return fmt.Errorf("expanding config path: %+v", err)
+	}
+	cfg, err := config.NewConfigFromFile(cfgPath)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %+v", err)
+	}
+	cfg.ApplyHttpProxy()
+
+	var results []doctor.Result
+	found := false
+	for _, cluster := range cfg.Clusters {
+		if opts.cluster != "" && cluster.Name != opts.cluster && !utils.Contains(cluster.Alias, opts.cluster) {
+			continue
+		}
+		found = true
+		results = append(results, checkCluster(cmd.Context(), cluster, opts)...)
+	}
+	if !found {
+		if opts.cluster == "" {
+			return errors.New("no clusters configured")
+		}
+		return fmt.Errorf("cluster %s not found", opts.cluster)
+	}
+
+	out := cmd.OutOrStdout()
+	printDoctorResults(out, results)
+
+	summary := doctor.Summary(results)
+	fmt.Fprintf(out, "\n%d passed, %d warnings, %d failed, %d skipped\n",
+		summary[doctor.StatusPass], summary[doctor.StatusWarn],
+		summary[doctor.StatusFail], summary[doctor.StatusSkip])
+	switch {
+	case summary[doctor.StatusFail] > 0:
+		return &ExitError{Code: 1}
+	case summary[doctor.StatusWarn] > 0:
+		return &ExitError{Code: 2}
+	}
+	return nil
+}
+
+func checkCluster(ctx context.Context, cluster *config.Cluster, opts doctorOptions) []doctor.Result {
+	if cluster.Disabled {
+		return []doctor.Result{{Cluster: cluster.Name, Check: doctor.CheckConfig,
+			Status: doctor.StatusSkip, Detail: "cluster is disabled"}}
+	}
+	p, err := cluster.NewProvider()
+	if err != nil {
+		return []doctor.Result{{Cluster: cluster.Name, Check: doctor.CheckConfig,
+			Status: doctor.StatusFail, Detail: err.Error()}}
+	}
+	defer provider.Close(p)
+	results := []doctor.Result{{Cluster: cluster.Name, Check: doctor.CheckConfig,
+		Status: doctor.StatusPass, Detail: "provider " + cluster.Provider.Name}}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+	return append(results, doctor.Check(ctx, cluster.Name, p, doctor.Options{
+		Since:  opts.since,
+		MaxAge: opts.maxAge,
+	})...)
+}
+
+func printDoctorResults(out io.Writer, results []doctor.Result) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tCHECK\tSTATUS\tDETAIL")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Cluster, r.Check, r.Status, r.Detail)
+	}
+	w.Flush()
+}
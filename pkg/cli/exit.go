@@ -0,0 +1,13 @@
+package cli
+
+import "fmt"
+
+// ExitError is returned by commands whose result is reported by the exit
+// code, the message is already printed when it is returned.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
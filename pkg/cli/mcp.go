@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
+
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/mozillazg/kube-audit-mcp/pkg/config"
 	"github.com/mozillazg/kube-audit-mcp/pkg/tools"
@@ -11,9 +13,10 @@ import (
 )
 
 type Options struct {
-	config    string
-	transport string
-	addr      string
+	config        string
+	transport     string
+	addr          string
+	watchInterval time.Duration
 }
 
 var opts Options
@@ -38,6 +41,11 @@ func init() {
 		config.ShortHomePath(config.DefaultConfigFile()),
 		"Path to the configuration file.")
 
+	mcpCmd.Flags().DurationVar(
+		&opts.watchInterval, "watch-interval", 2*time.Second,
+		"Interval of checking the configuration file for changes, 0 disables it. "+
+			"The configuration is also reloaded on SIGHUP.")
+
 	mcpCmd.Flags().StringVarP(
 		&opts.transport, "transport", "t",
 		"stdio", "Transport type for MCP server (stdio).")
@@ -59,16 +67,36 @@ func runMcpServer(opts Options) error {
 		return fmt.Errorf("initializing configuration: %+v", err)
 	}
 
+	completer := tools.NewCompleter(cfg)
+	instructions := tools.NewInstructions(cfg)
+	sessions := tools.NewSessionClusters()
+	hooks := &server.Hooks{}
+	hooks.AddAfterInitialize(instructions.AfterInitialize)
+	hooks.AddOnUnregisterSession(sessions.OnUnregisterSession)
 	s := server.NewMCPServer("kube-audit", version,
 		server.WithToolCapabilities(true),
+		server.WithResourceCapabilities(false, true),
+		server.WithPromptCapabilities(true),
+		server.WithCompletions(),
+		server.WithLogging(),
+		server.WithElicitation(),
+		server.WithPromptCompletionProvider(completer),
+		server.WithResourceCompletionProvider(completer),
+		server.WithHooks(hooks),
 	)
 
-	queryAuditLog := tools.NewQueryAuditLogTool(cfg)
-	queryAuditLog.Register(s)
-	listCommonResourceTypes := tools.ListCommonResourceTypesTool{}
-	listCommonResourceTypes.Register(s)
-	listClusters := tools.NewListClustersTool(cfg)
-	listClusters.Register(s)
+	s.EnableSampling()
+
+	s.AddTools(tools.NewServerTools(cfg, sessions)...)
+	s.AddResources(tools.NewServerResources(cfg)...)
+	s.AddResourceTemplates(tools.NewServerResourceTemplates(cfg)...)
+	s.SetPrompts(tools.NewServerPrompts(cfg)...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &reloader{path: cfgPath, cfg: cfg, s: s, completer: completer, instructions: instructions, sessions: sessions}
+	go r.run(ctx, opts.watchInterval)
+	defer r.close()
 
 	switch opts.transport {
 	//case "sse":
@@ -82,6 +110,4 @@ func runMcpServer(opts Options) error {
 	default:
 		return server.ServeStdio(s)
 	}
-
-	return nil
 }
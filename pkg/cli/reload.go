@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/mozillazg/kube-audit-mcp/pkg/tools"
+)
+
+// reloader reloads the configuration file when it changes or the process
+// receives SIGHUP. The tools, resources and prompts are registered again
+// with the new config, which replaces them atomically and notifies the
+// clients that the lists have changed. In-flight calls keep using the
+// providers of the old config, which are closed when the calls are done.
+// The instructions of the new config are sent to the clients that connect
+// later, and the clusters that the users chose in their sessions are kept.
+type reloader struct {
+	path         string
+	cfg          *config.Config
+	s            *server.MCPServer
+	completer    *tools.Completer
+	instructions *tools.Instructions
+	sessions     *tools.SessionClusters
+
+	mu sync.Mutex
+	// closed is true when the server stopped, the config is not reloaded
+	// anymore.
+	closed bool
+}
+
+func (r *reloader) run(ctx context.Context, interval time.Duration) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	changed := make(chan struct{}, 1)
+	if interval > 0 {
+		go config.Watch(ctx, r.path, interval, func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			log.Print("received SIGHUP, reloading configuration")
+		case <-changed:
+			log.Printf("configuration file %s changed, reloading", r.path)
+		}
+		if err := r.reload(); err != nil {
+			log.Printf("keeping the current configuration: %v", err)
+			continue
+		}
+		log.Print("configuration reloaded")
+	}
+}
+
+// reload loads and validates the configuration file, the current config is
+// kept when it is invalid.
+func (r *reloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+
+	cfg, err := config.NewConfigFromFile(r.path)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %+v", err)
+	}
+	if err := cfg.Init(); err != nil {
+		// The providers that were created before the error are closed.
+		go closeConfig(cfg)
+		return fmt.Errorf("initializing configuration: %+v", err)
+	}
+
+	// The tools are added before the removed tools are deleted, e.g. the
+	// disabled tools and the removed saved queries, so that the tools are
+	// never missing.
+	serverTools := tools.NewServerTools(cfg, r.sessions)
+	r.s.AddTools(serverTools...)
+	var removed []string
+	for name := range r.s.ListTools() {
+		if !slices.ContainsFunc(serverTools, func(tool server.ServerTool) bool { return tool.Tool.Name == name }) {
+			removed = append(removed, name)
+		}
+	}
+	if len(removed) > 0 {
+		r.s.DeleteTools(removed...)
+	}
+	r.s.AddResources(tools.NewServerResources(cfg)...)
+	r.s.AddResourceTemplates(tools.NewServerResourceTemplates(cfg)...)
+	// The prompts are replaced, because prompts may be removed from the
+	// prompt files.
+	r.s.SetPrompts(tools.NewServerPrompts(cfg)...)
+	r.completer.SetConfig(cfg)
+	r.instructions.SetConfig(cfg)
+
+	old := r.cfg
+	r.cfg = cfg
+	go closeConfig(old)
+	return nil
+}
+
+// close closes the providers of the current config, when the server stops.
+func (r *reloader) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	closeConfig(r.cfg)
+}
+
+func closeConfig(cfg *config.Config) {
+	if err := cfg.Close(); err != nil {
+		log.Printf("closing configuration: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/mozillazg/kube-audit-mcp/pkg/privacy"
+	"github.com/spf13/cobra"
+)
+
+var revealConfig string
+
+var revealCmd = &cobra.Command{
+	Use:   "reveal [token...]",
+	Short: "Map privacy tokens back to the original user names, emails and IPs.",
+	Long: `Map privacy tokens back to the original user names, emails and IPs.
+
+The tokens are read from the arguments. If no arguments are given, the text from
+stdin is printed with all known tokens replaced.
+
+This command only reads the local mapping file and is not exposed to MCP clients.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRevealCmd(cmd, args)
+	},
+}
+
+func init() {
+	revealCmd.Flags().StringVarP(
+		&revealConfig, "config", "c",
+		config.ShortHomePath(config.DefaultConfigFile()),
+		"Path to the configuration file.")
+}
+
+func runRevealCmd(cmd *cobra.Command, args []string) error {
+	cfgPath, err := config.ExpandPath(revealConfig)
+	if err != nil {
+		return fmt.Errorf("expanding config path: %+v", err)
+	}
+	cfg, err := config.NewConfigFromFile(cfgPath)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %+v", err)
+	}
+	mappingFile, err := cfg.PrivacyMappingFile()
+	if err != nil {
+		return err
+	}
+	store, err := privacy.OpenStore(mappingFile)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		text, err := io.ReadAll(cmd.InOrStdin())
+		if err != nil {
+			return fmt.Errorf("reading stdin: %+v", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), privacy.RevealText(store, string(text)))
+		return nil
+	}
+
+	var unknown int
+	for _, token := range args {
+		value, ok := store.Lookup(token)
+		if !ok {
+			unknown++
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: unknown token\n", token)
+			continue
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", token, value)
+	}
+	if unknown > 0 {
+		return fmt.Errorf("%d unknown token(s)", unknown)
+	}
+	return nil
+}
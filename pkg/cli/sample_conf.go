@@ -31,7 +31,7 @@ func init() {
 }
 
 func runSampleConfCmd(cmd *cobra.Command, args []string) {
-	sampleConf, _ := yaml.Marshal(config.SampleConfig)
+	sampleConf, _ := yaml.Marshal(config.NewSampleConfig())
 	fmt.Println()
 	fmt.Println(string(sampleConf))
 
@@ -1,16 +1,30 @@
 package config
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/mozillazg/kube-audit-mcp/pkg/provider/gcp"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 
+	"github.com/mozillazg/kube-audit-mcp/pkg/cache"
+	"github.com/mozillazg/kube-audit-mcp/pkg/guardrail"
+	"github.com/mozillazg/kube-audit-mcp/pkg/privacy"
+	"github.com/mozillazg/kube-audit-mcp/pkg/prompts"
 	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
 	"github.com/mozillazg/kube-audit-mcp/pkg/provider/alibaba"
 	"github.com/mozillazg/kube-audit-mcp/pkg/provider/aws"
+	"github.com/mozillazg/kube-audit-mcp/pkg/ratelimit"
+	"github.com/mozillazg/kube-audit-mcp/pkg/redact"
+	"github.com/mozillazg/kube-audit-mcp/pkg/toolset"
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
 	"github.com/mozillazg/kube-audit-mcp/pkg/utils"
 	"sigs.k8s.io/yaml"
 )
@@ -19,22 +33,56 @@ type Config struct {
 	DefaultCluster string     `yaml:"default_cluster" json:"default_cluster"`
 	Clusters       []*Cluster `yaml:"clusters,omitempty" json:"clusters,omitempty"`
 
+	// Instructions are the MCP instructions of the server, that the clients
+	// pass to the agent, e.g. the conventions of the organization and which
+	// cluster is which.
+	Instructions string `yaml:"instructions,omitempty" json:"instructions,omitempty"`
+
+	// Include is a list of files, or glob patterns of files, that contain
+	// more clusters. ClustersDir includes all the *.yaml and *.yml files in
+	// the directory. Relative paths are relative to the config file.
+	Include     []string `yaml:"include,omitempty" json:"include,omitempty"`
+	ClustersDir string   `yaml:"clusters_dir,omitempty" json:"clusters_dir,omitempty"`
+
+	// DiscoverFromKubeconfig adds the clusters discovered from the contexts
+	// of Kubeconfig, which defaults to $KUBECONFIG or ~/.kube/config. The
+	// clusters in the config take precedence over the discovered ones.
+	DiscoverFromKubeconfig bool   `yaml:"discover_from_kubeconfig,omitempty" json:"discover_from_kubeconfig,omitempty"`
+	Kubeconfig             string `yaml:"kubeconfig,omitempty" json:"kubeconfig,omitempty"`
+
 	HttpProxy string `yaml:"http_proxy,omitempty" json:"http_proxy,omitempty"`
 
-	mu sync.RWMutex
+	Privacy *privacy.Config `yaml:"privacy,omitempty" json:"privacy,omitempty"`
+	Cache   *cache.Config   `yaml:"cache,omitempty" json:"cache,omitempty"`
+	Prompts *prompts.Config `yaml:"prompts,omitempty" json:"prompts,omitempty"`
+	Tools   *toolset.Config `yaml:"tools,omitempty" json:"tools,omitempty"`
+
+	prompts []*prompts.Prompt
+	mu      sync.RWMutex
 }
 
 type Cluster struct {
-	Name        string `yaml:"name" json:"name"`
+	Name string `yaml:"name" json:"name"`
+	// Description is shown to the agent in the description of the
+	// query_audit_log tool and by list_clusters, e.g. what runs in the
+	// cluster.
 	Description string `yaml:"description" json:"description"`
 
 	Alias    []string `yaml:"alias,omitempty" json:"alias,omitempty"`
 	Disabled bool     `yaml:"disabled" json:"disabled"`
 
-	Provider ProviderConfig `yaml:"provider" json:"provider"`
+	Provider  ProviderConfig `yaml:"provider" json:"provider"`
+	Redaction *redact.Config `yaml:"redaction,omitempty" json:"redaction,omitempty"`
 
-	p  provider.Provider
-	mu sync.RWMutex
+	// source is the file the cluster is loaded from, discovered is true
+	// when the file is a kubeconfig.
+	source     string
+	discovered bool
+
+	p             provider.Provider
+	pseudonymizer *privacy.Pseudonymizer
+	cache         *cache.Cache
+	mu            sync.RWMutex
 }
 
 type ProviderConfig struct {
@@ -42,6 +90,15 @@ type ProviderConfig struct {
 	AlibabaSLS        *alibaba.SLSProviderConfig        `yaml:"alibaba_sls,omitempty" json:"alibaba_sls,omitempty"`
 	AwsCloudWatchLogs *aws.CloudWatchLogsProviderConfig `yaml:"aws_cloudwatch_logs,omitempty" json:"aws_cloudwatch_logs,omitempty"`
 	GcpCloudLogging   *gcp.CloudLoggingProviderConfig   `yaml:"gcp_cloud_logging,omitempty" json:"gcp_cloud_logging,omitempty"`
+
+	Guardrails *guardrail.Config `yaml:"guardrails,omitempty" json:"guardrails,omitempty"`
+	RateLimit  *ratelimit.Config `yaml:"rate_limit,omitempty" json:"rate_limit,omitempty"`
+	Transport  *transport.Config `yaml:"transport,omitempty" json:"transport,omitempty"`
+
+	// Options is the config of the providers without a dedicated field
+	// above, e.g. the providers that are registered by forks. It is
+	// decoded by the DecodeConfig of the provider registration.
+	Options map[string]any `yaml:"options,omitempty" json:"options,omitempty"`
 }
 
 func NewConfigFromFile(filePath string) (*Config, error) {
@@ -52,41 +109,262 @@ func NewConfigFromFile(filePath string) (*Config, error) {
 	return config, nil
 }
 
+// LoadFromFile loads the config from the file, expands the env vars and
+// files referenced in it, and appends the clusters of the included files.
+// Unknown fields are rejected.
 func (c *Config) LoadFromFile(filePath string) error {
-	data, err := os.ReadFile(filePath)
+	raw, data, err := readConfigFile(filePath)
+	if err != nil {
+		return err
+	}
+	if err := checkUnknownFields(raw, reflect.TypeOf(c)); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", filePath, err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("parse config file %s: %w", filePath, err)
+	}
+	for _, cluster := range c.Clusters {
+		cluster.source = filePath
+	}
+	if err := c.resolvePrivacyKeyFile(filepath.Dir(filePath)); err != nil {
+		return err
+	}
+	if err := c.resolvePromptFiles(filepath.Dir(filePath)); err != nil {
+		return err
+	}
+	if err := c.loadIncludes(filepath.Dir(filePath)); err != nil {
+		return err
+	}
+	return c.loadDiscoveredClusters(filepath.Dir(filePath))
+}
+
+// readConfigFile returns the content of the config file as it is, and
+// interpolated. The unknown fields are checked in the raw content, because
+// the interpolated content is marshalled again, without the comments and
+// the blank lines, so its line numbers differ from the file. The keys are
+// not interpolated.
+func readConfigFile(filePath string) (raw, data []byte, err error) {
+	raw, err = os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file %s: %w", filePath, err)
+	}
+	data, err = interpolate(raw, filepath.Dir(filePath))
+	if err != nil {
+		return nil, nil, fmt.Errorf("interpolate config file %s: %w", filePath, err)
+	}
+	return raw, data, nil
+}
+
+func (c *Config) resolvePromptFiles(dir string) error {
+	if c.Prompts == nil {
+		return nil
+	}
+	for i, file := range c.Prompts.Files {
+		p, err := resolvePath(file, dir)
+		if err != nil {
+			return err
+		}
+		c.Prompts.Files[i] = p
+	}
+	return nil
+}
+
+// resolvePrivacyKeyFile resolves a relative key_file against the directory
+// of the config file.
+func (c *Config) resolvePrivacyKeyFile(dir string) error {
+	if c.Privacy == nil || c.Privacy.KeyFile == "" {
+		return nil
+	}
+	p, err := resolvePath(c.Privacy.KeyFile, dir)
+	if err != nil {
+		return err
+	}
+	c.Privacy.KeyFile = p
+	return nil
+}
+
+func (c *Config) loadIncludes(dir string) error {
+	var patterns []string
+	for _, pattern := range c.Include {
+		p, err := resolvePath(pattern, dir)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, p)
+	}
+	if c.ClustersDir != "" {
+		clustersDir, err := resolvePath(c.ClustersDir, dir)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(clustersDir); err != nil {
+			return fmt.Errorf("invalid clusters_dir: %w", err)
+		}
+		patterns = append(patterns, filepath.Join(clustersDir, "*.yaml"), filepath.Join(clustersDir, "*.yml"))
+	}
+
+	sources := map[string]string{}
+	for _, cluster := range c.Clusters {
+		sources[cluster.Name] = cluster.source
+	}
+	for _, pattern := range patterns {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid include pattern %s: %w", pattern, err)
+		}
+		if len(files) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return fmt.Errorf("included file %s does not exist", pattern)
+		}
+		for _, file := range files {
+			clusters, err := loadClustersFromFile(file)
+			if err != nil {
+				return err
+			}
+			for _, cluster := range clusters {
+				if source, ok := sources[cluster.Name]; ok && cluster.Name != "" {
+					return fmt.Errorf("cluster %s in %s is already defined in %s", cluster.Name, file, source)
+				}
+				sources[cluster.Name] = file
+				c.Clusters = append(c.Clusters, cluster)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) loadDiscoveredClusters(dir string) error {
+	if !c.DiscoverFromKubeconfig {
+		return nil
+	}
+	kubeconfig := c.Kubeconfig
+	if kubeconfig != "" {
+		p, err := resolvePath(kubeconfig, dir)
+		if err != nil {
+			return err
+		}
+		kubeconfig = p
+	}
+	files, err := KubeconfigFiles(kubeconfig)
+	if err != nil {
+		return err
+	}
+	result, err := DiscoverClusters(files)
+	if err != nil {
+		return fmt.Errorf("discover clusters from kubeconfig: %w", err)
+	}
+
+	var names []string
+	for _, cluster := range c.Clusters {
+		names = append(names, cluster.Name)
+		names = append(names, cluster.Alias...)
+	}
+	for _, cluster := range result.Clusters {
+		if utils.Contains(names, cluster.Name) {
+			continue
+		}
+		var alias []string
+		for _, name := range cluster.Alias {
+			if !utils.Contains(names, name) {
+				alias = append(alias, name)
+			}
+		}
+		cluster.Alias = alias
+		cluster.discovered = true
+		names = append(names, cluster.Name)
+		names = append(names, alias...)
+		c.Clusters = append(c.Clusters, cluster)
+	}
+	if c.DefaultCluster == "" {
+		c.DefaultCluster = result.CurrentCluster
+	}
+	return nil
+}
+
+// loadClustersFromFile loads the clusters of an included file, which
+// contains either a cluster or a list of clusters.
+func loadClustersFromFile(filePath string) ([]*Cluster, error) {
+	raw, data, err := readConfigFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", filePath, err)
+	}
+
+	var clusters []*Cluster
+	var target any = &clusters
+	if trimmed := bytes.TrimSpace(jsonData); len(trimmed) == 0 || trimmed[0] != '[' {
+		clusters = append(clusters, &Cluster{})
+		target = clusters[0]
+	}
+	if err := checkUnknownFields(raw, reflect.TypeOf(target)); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", filePath, err)
+	}
+	if err := json.Unmarshal(jsonData, target); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", filePath, err)
+	}
+	for _, cluster := range clusters {
+		cluster.source = filePath
+	}
+	return clusters, nil
+}
+
+func resolvePath(path, dir string) (string, error) {
+	path, err := ExpandPath(path)
 	if err != nil {
-		return fmt.Errorf("failed to read config file %s: %w", filePath, err)
+		return "", fmt.Errorf("expanding path %s: %w", path, err)
 	}
-	return yaml.Unmarshal(data, c)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path, nil
 }
 
 func (c *Config) Init() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.HttpProxy != "" {
-		for _, h := range []string{"http_proxy", "https_proxy", "HTTP_PROXY", "HTTPS_PROXY"} {
-			os.Setenv(h, c.HttpProxy)
-		}
+	c.ApplyHttpProxy()
+
+	pseudonymizer, err := c.newPseudonymizer()
+	if err != nil {
+		return fmt.Errorf("init privacy: %w", err)
+	}
+	resultCache, err := c.newCache()
+	if err != nil {
+		return fmt.Errorf("init cache: %w", err)
+	}
+	c.prompts, err = prompts.New(c.Prompts)
+	if err != nil {
+		return fmt.Errorf("init prompts: %w", err)
+	}
+	if err := c.Tools.Validate(); err != nil {
+		return fmt.Errorf("init tools: %w", err)
 	}
 
 	var clusterNames []string
 
 	for _, cluster := range c.Clusters {
+		cluster.pseudonymizer = pseudonymizer
+		cluster.cache = resultCache
 		if cluster.Disabled {
 			continue // Skip disabled clusters
 		}
 		if cluster.Name == "" {
+			if cluster.source != "" {
+				return fmt.Errorf("cluster name is required in %s", cluster.source)
+			}
 			return errors.New("cluster name is required")
 		}
 		if cluster.Provider.Name == "" {
-			return fmt.Errorf("provider name is required for cluster %s", cluster.Name)
+			return fmt.Errorf("provider name is required for cluster %s", cluster.describe())
 		}
 
 		// Initialize provider for validation
 		_, err := cluster.getProvider()
 		if err != nil {
-			return fmt.Errorf("get provider for cluster %s: %w", cluster.Name, err)
+			return fmt.Errorf("get provider for cluster %s: %w", cluster.describe(), err)
 		}
 
 		clusterNames = append(clusterNames, cluster.Name)
@@ -103,6 +381,69 @@ func (c *Config) Init() error {
 	return nil
 }
 
+// ApplyHttpProxy sets the proxy env vars to HttpProxy, so that it is used by
+// all providers without a proxy in their transport config.
+func (c *Config) ApplyHttpProxy() {
+	if c.HttpProxy != "" {
+		for _, h := range []string{"http_proxy", "https_proxy", "HTTP_PROXY", "HTTPS_PROXY"} {
+			os.Setenv(h, c.HttpProxy)
+		}
+	}
+}
+
+func (c *Config) newPseudonymizer() (*privacy.Pseudonymizer, error) {
+	if c.Privacy == nil || !c.Privacy.Enabled {
+		return nil, nil
+	}
+
+	mappingFile, err := c.PrivacyMappingFile()
+	if err != nil {
+		return nil, err
+	}
+	pconfig := *c.Privacy
+	pconfig.MappingFile = mappingFile
+	if pconfig.KeyFile != "" {
+		keyFile, err := ExpandPath(pconfig.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("expanding key file path: %w", err)
+		}
+		pconfig.KeyFile = keyFile
+	}
+
+	return privacy.New(&pconfig)
+}
+
+func (c *Config) newCache() (*cache.Cache, error) {
+	if c.Cache == nil || !c.Cache.Enabled {
+		return nil, nil
+	}
+
+	cconfig := *c.Cache
+	if cconfig.Dir != "" {
+		dir, err := ExpandPath(cconfig.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("expanding cache dir: %w", err)
+		}
+		cconfig.Dir = dir
+	}
+
+	return cache.New(&cconfig)
+}
+
+// PrivacyMappingFile returns the expanded path of the file that maps
+// privacy tokens back to the original values.
+func (c *Config) PrivacyMappingFile() (string, error) {
+	mappingFile := privacy.DefaultMappingFile
+	if c.Privacy != nil && c.Privacy.MappingFile != "" {
+		mappingFile = c.Privacy.MappingFile
+	}
+	p, err := ExpandPath(mappingFile)
+	if err != nil {
+		return "", fmt.Errorf("expanding mapping file path: %w", err)
+	}
+	return p, nil
+}
+
 func (c *Config) GetProviderByName(name string) (provider.Provider, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -128,6 +469,29 @@ func (c *Config) GetProviderByName(name string) (provider.Provider, error) {
 	return nil, fmt.Errorf("provider not found for name: %s", name)
 }
 
+// Close closes the providers of the clusters, e.g. it stops the plugins of
+// the exec-plugin providers. The in-flight queries of a provider may finish
+// before it is closed.
+func (c *Config) Close() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var errs []error
+	for _, cluster := range c.Clusters {
+		if err := cluster.close(); err != nil {
+			errs = append(errs, fmt.Errorf("close provider of cluster %s: %w", cluster.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PromptCatalog returns the prompts of the config, it is empty before Init.
+func (c *Config) PromptCatalog() []*prompts.Prompt {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.prompts
+}
+
 func (c *Config) AvailableClusterNames() []string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -144,6 +508,49 @@ func (c *Config) AvailableClusterNames() []string {
 	return names
 }
 
+// EnabledClusterNames returns the names of the enabled clusters, without
+// the aliases.
+func (c *Config) EnabledClusterNames() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var names []string
+	for _, cluster := range c.Clusters {
+		if !cluster.Disabled {
+			names = append(names, cluster.Name)
+		}
+	}
+	return names
+}
+
+// HasCluster reports whether a cluster, enabled or not, has the name or
+// the alias.
+func (c *Config) HasCluster(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, cluster := range c.Clusters {
+		if cluster.Name == name || utils.Contains(cluster.Alias, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Source returns the config file the cluster is loaded from.
+func (c *Cluster) Source() string {
+	return c.source
+}
+
+// describe returns the name of the cluster and the file it is loaded from,
+// for error messages.
+func (c *Cluster) describe() string {
+	if c.source == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s (%s)", c.Name, c.source)
+}
+
 func (p ProviderConfig) normalizedName() string {
 	return strings.Replace(p.Name, "_", "-", -1)
 }
@@ -151,41 +558,72 @@ func (p ProviderConfig) normalizedName() string {
 // createProvider creates a new provider instance based on the provider configuration
 func (c *Cluster) createProvider() (provider.Provider, error) {
 	pconfig := c.Provider
-	normalizedName := pconfig.normalizedName()
-
-	switch normalizedName {
-	case alibaba.SLSProviderName:
-		if pconfig.AlibabaSLS == nil {
-			return nil, fmt.Errorf("provider %s requires alibaba_sls configuration", pconfig.Name)
-		}
-		p, err := alibaba.NewSLSProvider(pconfig.AlibabaSLS)
-		if err != nil {
-			return nil, fmt.Errorf("init provider %s: %w", pconfig.Name, err)
+	if pconfig.Transport != nil {
+		if err := pconfig.Transport.Init(); err != nil {
+			return nil, fmt.Errorf("invalid transport config of provider %s: %w", pconfig.Name, err)
 		}
-		return p, nil
-	case aws.CloudWatchProviderName:
-		if pconfig.AwsCloudWatchLogs == nil {
-			return nil, fmt.Errorf("provider %s requires aws_cloudwatch_logs configuration", pconfig.Name)
-		}
-		p, err := aws.NewCloudWatchLogsProvider(pconfig.AwsCloudWatchLogs)
+	}
+
+	r, ok := provider.Lookup(pconfig.normalizedName())
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", pconfig.Name)
+	}
+	config, err := pconfig.config(r)
+	if err != nil {
+		return nil, err
+	}
+	p, err := r.New(config, pconfig.Transport)
+	if err != nil {
+		return nil, fmt.Errorf("init provider %s: %w", pconfig.Name, err)
+	}
+	return p, nil
+}
+
+// config returns the config of the provider, which is either in the
+// dedicated field of the provider or in the options.
+func (p ProviderConfig) config(r provider.Registration) (any, error) {
+	field, ok := dedicatedConfigField(r)
+	if !ok {
+		config, err := r.DecodeConfig(p.Options)
 		if err != nil {
-			return nil, fmt.Errorf("init provider %s: %w", pconfig.Name, err)
-		}
-		return p, nil
-	case gcp.CloudLoggingProviderName:
-		if pconfig.GcpCloudLogging == nil {
-			return nil, fmt.Errorf("provider %s requires gcp_cloud_logging configuration", pconfig.Name)
+			return nil, fmt.Errorf("invalid options of provider %s: %w", p.Name, err)
 		}
-		p, err := gcp.NewCloudLoggingProvider(pconfig.GcpCloudLogging)
+		return config, nil
+	}
+
+	value := reflect.ValueOf(p).FieldByIndex(field.Index)
+	switch {
+	case !value.IsNil() && p.Options != nil:
+		return nil, fmt.Errorf("provider %s accepts either %s or options configuration", p.Name, r.ConfigKey)
+	case !value.IsNil():
+		return value.Interface(), nil
+	case p.Options != nil:
+		config, err := r.DecodeConfig(p.Options)
 		if err != nil {
-			return nil, fmt.Errorf("init provider %s: %w", pconfig.Name, err)
+			return nil, fmt.Errorf("invalid options of provider %s: %w", p.Name, err)
 		}
-		return p, nil
+		return config, nil
 	default:
-		return nil, fmt.Errorf("unknown provider: %s", pconfig.Name)
+		return nil, fmt.Errorf("provider %s requires %s configuration", p.Name, r.ConfigKey)
 	}
 }
 
+// dedicatedConfigField returns the field of ProviderConfig whose JSON name
+// is the config key of the provider.
+func dedicatedConfigField(r provider.Registration) (reflect.StructField, bool) {
+	if r.ConfigKey == "" {
+		return reflect.StructField{}, false
+	}
+	field, ok := jsonFields(reflect.TypeOf(ProviderConfig{}))[r.ConfigKey]
+	return field, ok
+}
+
+// NewProvider creates a new provider of the cluster without the decorators
+// of wrapProvider, it is used to diagnose the access to the backend.
+func (c *Cluster) NewProvider() (provider.Provider, error) {
+	return c.createProvider()
+}
+
 func (c *Cluster) getProvider() (provider.Provider, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -202,7 +640,63 @@ func (c *Cluster) getProvider() (provider.Provider, error) {
 	if err != nil {
 		return nil, err
 	}
+	p, err = c.wrapProvider(p)
+	if err != nil {
+		return nil, err
+	}
 
 	c.p = p
 	return p, nil
 }
+
+// cacheConfigHash returns the hash of the provider and redaction config of
+// the cluster, the cached results of another config are not served.
+func (c *Cluster) cacheConfigHash() string {
+	data, _ := json.Marshal(struct {
+		Provider  ProviderConfig `json:"provider"`
+		Redaction *redact.Config `json:"redaction"`
+	}{c.Provider, c.Redaction})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cluster) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.p == nil {
+		return nil
+	}
+	return provider.Close(c.p)
+}
+
+// wrapProvider wraps the provider with the decorators that guard and
+// post-process queries, e.g. the rate limiter of the backend API, the
+// guardrails that reject expensive queries, redaction of sensitive content
+// and pseudonymization of personal data. Results are cached after redaction
+// and before pseudonymization, so that neither the in-memory nor the
+// on-disk cache contains credentials, and cache hits do not count against
+// the guardrails.
+func (c *Cluster) wrapProvider(p provider.Provider) (provider.Provider, error) {
+	if c.Provider.RateLimit != nil {
+		p = ratelimit.NewProvider(p, ratelimit.New(c.Provider.RateLimit))
+	}
+	if c.Provider.Guardrails != nil {
+		p = guardrail.NewProvider(p, c.Provider.Guardrails, c.Name)
+	}
+	redactor, err := redact.New(c.Redaction)
+	if err != nil {
+		return nil, err
+	}
+	if redactor != nil {
+		p = redact.NewProvider(p, redactor)
+	}
+	if c.cache != nil {
+		p = cache.NewProvider(p, c.cache, c.Name, c.cacheConfigHash())
+	}
+	if c.pseudonymizer != nil {
+		p = privacy.NewProvider(p, c.pseudonymizer)
+	}
+
+	return p, nil
+}
@@ -5,12 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
 
+	"github.com/mozillazg/kube-audit-mcp/pkg/privacy"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
 	"github.com/mozillazg/kube-audit-mcp/pkg/provider/alibaba"
 	"github.com/mozillazg/kube-audit-mcp/pkg/provider/aws"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider/gcp"
+	"github.com/mozillazg/kube-audit-mcp/pkg/redact"
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
 	"github.com/mozillazg/kube-audit-mcp/pkg/types"
 )
 
@@ -23,6 +30,10 @@ func (m *mockProvider) QueryAuditLog(_ context.Context, _ types.QueryAuditLogPar
 	return types.AuditLogResult{}, nil
 }
 
+func (m *mockProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{}
+}
+
 func TestGetProviderByName(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -417,6 +428,30 @@ func TestGetProviderByName_MultipleAliasMatches(t *testing.T) {
 	}
 }
 
+func TestConfig_EnabledClusterNames_HasCluster(t *testing.T) {
+	config := &Config{
+		DefaultCluster: "prod",
+		Clusters: []*Cluster{
+			{Name: "prod", Alias: []string{"production"}},
+			{Name: "staging", Alias: []string{"stg"}, Disabled: true},
+			{Name: "dev"},
+		},
+	}
+
+	names := config.EnabledClusterNames()
+	if !reflect.DeepEqual(names, []string{"prod", "dev"}) {
+		t.Errorf("expected enabled clusters [prod dev], got %v", names)
+	}
+	for name, expected := range map[string]bool{
+		"prod": true, "production": true, "staging": true, "stg": true, "dev": true,
+		"prd": false, "": false,
+	} {
+		if got := config.HasCluster(name); got != expected {
+			t.Errorf("HasCluster(%q) = %v, expected %v", name, got, expected)
+		}
+	}
+}
+
 func TestCluster_createProvider(t *testing.T) {
 	os.Setenv("ALIBABA_CLOUD_ACCESS_KEY_ID", "test-access-key-id")
 	os.Setenv("ALIBABA_CLOUD_ACCESS_KEY_SECRET", "test-access")
@@ -519,6 +554,48 @@ func TestCluster_createProvider(t *testing.T) {
 			expectedError: "provider aws-cloudwatch-logs requires aws_cloudwatch_logs configuration",
 			expectedType:  "",
 		},
+		{
+			name: "create alibaba sls provider with options",
+			cluster: &Cluster{
+				Name: "test-cluster",
+				Provider: ProviderConfig{
+					Name: "alibaba-sls",
+					Options: map[string]any{
+						"endpoint": "https://test.aliyuncs.com",
+						"region":   "us-west-1",
+						"project":  "test-project",
+						"logstore": "test-logstore",
+					},
+				},
+			},
+			expectedError: "",
+			expectedType:  "*alibaba.SLSProvider",
+		},
+		{
+			name: "alibaba sls provider with unknown options",
+			cluster: &Cluster{
+				Name: "test-cluster",
+				Provider: ProviderConfig{
+					Name:    "alibaba-sls",
+					Options: map[string]any{"log_store": "test-logstore"},
+				},
+			},
+			expectedError: `invalid options of provider alibaba-sls: json: unknown field "log_store"`,
+			expectedType:  "",
+		},
+		{
+			name: "alibaba sls provider with both alibaba_sls and options",
+			cluster: &Cluster{
+				Name: "test-cluster",
+				Provider: ProviderConfig{
+					Name:       "alibaba-sls",
+					AlibabaSLS: &alibaba.SLSProviderConfig{},
+					Options:    map[string]any{"project": "test-project"},
+				},
+			},
+			expectedError: "provider alibaba-sls accepts either alibaba_sls or options configuration",
+			expectedType:  "",
+		},
 		{
 			name: "unknown provider",
 			cluster: &Cluster{
@@ -644,3 +721,343 @@ func TestProviderConfig_normalizedName(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_Init_Privacy(t *testing.T) {
+	config := &Config{
+		DefaultCluster: "default-cluster",
+		Privacy: &privacy.Config{
+			Enabled: true,
+		},
+	}
+
+	err := config.Init()
+	if err == nil || !strings.Contains(err.Error(), "init privacy: either key_env or key_file is required") {
+		t.Errorf("expected privacy key error, got %v", err)
+	}
+
+	config.Privacy.MappingFile = "/tmp/tokens.jsonl"
+	mappingFile, err := config.PrivacyMappingFile()
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if mappingFile != "/tmp/tokens.jsonl" {
+		t.Errorf("expected mapping file %q, got %q", "/tmp/tokens.jsonl", mappingFile)
+	}
+}
+
+func TestCluster_cacheConfigHash(t *testing.T) {
+	newCluster := func() *Cluster {
+		return &Cluster{
+			Name: "prod",
+			Provider: ProviderConfig{
+				Name:    "fake",
+				Options: map[string]any{"table": "k8s_audit"},
+			},
+		}
+	}
+	hash := newCluster().cacheConfigHash()
+	if hash != newCluster().cacheConfigHash() {
+		t.Error("expected the same hash of the same config")
+	}
+
+	backend := newCluster()
+	backend.Provider.Options["table"] = "other_audit"
+	if backend.cacheConfigHash() == hash {
+		t.Error("expected another hash of another backend")
+	}
+
+	redaction := newCluster()
+	redaction.Redaction = &redact.Config{Patterns: []string{"internal-[0-9]+"}}
+	if redaction.cacheConfigHash() == hash {
+		t.Error("expected another hash of other redaction rules")
+	}
+}
+
+func TestConfig_Init_Prompts(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `default_cluster: main
+clusters:
+  - name: main
+    provider:
+      name: fake
+      options:
+        table: audit
+prompts:
+  files:
+    - prompts/team.yaml
+`,
+		"prompts/team.yaml": `prompts:
+  - name: recent_exec
+    template: Find the pods/exec events.
+`,
+	})
+
+	config, err := NewConfigFromFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := filepath.Join(dir, "prompts/team.yaml"); config.Prompts.Files[0] != expected {
+		t.Errorf("expected prompt file %s, got %s", expected, config.Prompts.Files[0])
+	}
+	if err := config.Init(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	catalog := config.PromptCatalog()
+	if len(catalog) != 5 || catalog[4].Name != "recent_exec" {
+		t.Errorf("expected the built-in prompts and recent_exec, got %d prompts", len(catalog))
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "prompts/team.yaml"), []byte("prompts:\n  - name: a\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err = config.Init()
+	if err == nil || !strings.Contains(err.Error(), "init prompts: invalid prompt file") {
+		t.Errorf("expected prompt file error, got %v", err)
+	}
+}
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestConfig_LoadFromFile_Include(t *testing.T) {
+	t.Setenv("TEST_CONFIG_PROJECT", "audit-prod")
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `default_cluster: main
+include:
+  - extra.yaml
+clusters_dir: clusters.d
+clusters:
+  - name: main
+    provider:
+      name: alibaba-sls
+      alibaba_sls:
+        project: ${TEST_CONFIG_PROJECT}
+`,
+		"extra.yaml": `- name: extra-1
+- name: extra-2
+`,
+		"clusters.d/b.yml": `name: b
+provider:
+  name: alibaba-sls
+  alibaba_sls:
+    project: ${TEST_CONFIG_PROJECT:-default}-b
+`,
+		"clusters.d/a.yaml":      `name: a`,
+		"clusters.d/ignored.txt": `name: ignored`,
+	})
+
+	config, err := NewConfigFromFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"main":    "config.yaml",
+		"extra-1": "extra.yaml",
+		"extra-2": "extra.yaml",
+		"a":       "clusters.d/a.yaml",
+		"b":       "clusters.d/b.yml",
+	}
+	var names []string
+	for _, cluster := range config.Clusters {
+		names = append(names, cluster.Name)
+		if source := filepath.Join(dir, expected[cluster.Name]); cluster.Source() != source {
+			t.Errorf("expected source of cluster %s to be %q, got %q", cluster.Name, source, cluster.Source())
+		}
+	}
+	if got := strings.Join(names, ","); got != "main,extra-1,extra-2,a,b" {
+		t.Errorf("unexpected clusters %s", got)
+	}
+	if project := config.Clusters[0].Provider.AlibabaSLS.Project; project != "audit-prod" {
+		t.Errorf("expected project %q, got %q", "audit-prod", project)
+	}
+	if project := config.Clusters[4].Provider.AlibabaSLS.Project; project != "audit-prod-b" {
+		t.Errorf("expected project %q, got %q", "audit-prod-b", project)
+	}
+
+	err = config.Init()
+	expectedErr := "get provider for cluster main (" + filepath.Join(dir, "config.yaml") + "): "
+	if err == nil || !strings.HasPrefix(err.Error(), expectedErr) {
+		t.Errorf("expected error %q, got %v", expectedErr, err)
+	}
+}
+
+func TestConfig_LoadFromFile_IncludeErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		expectedErr string
+	}{
+		{
+			name: "duplicate cluster",
+			files: map[string]string{
+				"config.yaml":       "clusters_dir: clusters.d\nclusters:\n  - name: a\n",
+				"clusters.d/a.yaml": "name: a\n",
+			},
+			expectedErr: "cluster a in {dir}/clusters.d/a.yaml is already defined in {dir}/config.yaml",
+		},
+		{
+			name: "missing included file",
+			files: map[string]string{
+				"config.yaml": "include:\n  - missing.yaml\n",
+			},
+			expectedErr: "included file {dir}/missing.yaml does not exist",
+		},
+		{
+			name: "missing clusters dir",
+			files: map[string]string{
+				"config.yaml": "clusters_dir: missing\n",
+			},
+			expectedErr: "invalid clusters_dir",
+		},
+		{
+			name: "unset env var in included file",
+			files: map[string]string{
+				"config.yaml": "include:\n  - '*.yml'\n",
+				"a.yml":       "name: a\ndescription: ${TEST_CONFIG_MISSING}\n",
+			},
+			expectedErr: "interpolate config file {dir}/a.yml: line 2: env TEST_CONFIG_MISSING referenced by ${TEST_CONFIG_MISSING} is not set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigFiles(t, tt.files)
+			_, err := NewConfigFromFile(filepath.Join(dir, "config.yaml"))
+			expectedErr := strings.ReplaceAll(tt.expectedErr, "{dir}", dir)
+			if err == nil || !strings.Contains(err.Error(), expectedErr) {
+				t.Errorf("expected error containing %q, got %v", expectedErr, err)
+			}
+		})
+	}
+}
+
+type fakeProviderConfig struct {
+	Table string `json:"table"`
+}
+
+type fakeProvider struct {
+	provider.Provider
+	config *fakeProviderConfig
+}
+
+func init() {
+	provider.Register(provider.Registration{
+		Name:         "fake",
+		DecodeConfig: provider.DecodeConfig[fakeProviderConfig],
+		New: func(config any, _ *transport.Config) (provider.Provider, error) {
+			return &fakeProvider{config: config.(*fakeProviderConfig)}, nil
+		},
+		SampleConfig: &fakeProviderConfig{Table: "audit"},
+	})
+}
+
+func TestCluster_createProvider_Registered(t *testing.T) {
+	cluster := &Cluster{
+		Name: "test-cluster",
+		Provider: ProviderConfig{
+			Name:    "fake",
+			Options: map[string]any{"table": "k8s_audit"},
+		},
+	}
+	p, err := cluster.createProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fake, ok := p.(*fakeProvider)
+	if !ok {
+		t.Fatalf("expected *fakeProvider, got %T", p)
+	}
+	if fake.config.Table != "k8s_audit" {
+		t.Errorf("expected table k8s_audit, got %s", fake.config.Table)
+	}
+
+	sample := NewSampleConfig()
+	var found bool
+	for _, cluster := range sample.Clusters {
+		if cluster.Name == "fake" {
+			found = true
+			if cluster.Provider.Options["table"] != "audit" {
+				t.Errorf("expected sample options of fake provider, got %v", cluster.Provider.Options)
+			}
+		}
+	}
+	if !found {
+		t.Error("expected a sample cluster of fake provider")
+	}
+}
+
+func TestNewSampleConfig(t *testing.T) {
+	sample := NewSampleConfig()
+	if sample.DefaultCluster != "prod" {
+		t.Errorf("expected default cluster prod, got %s", sample.DefaultCluster)
+	}
+
+	providers := map[string]string{}
+	for _, cluster := range sample.Clusters {
+		providers[cluster.Name] = cluster.Provider.Name
+	}
+	for name, providerName := range map[string]string{
+		"prod": aws.CloudWatchProviderName,
+		"dev":  alibaba.SLSProviderName,
+		"test": gcp.CloudLoggingProviderName,
+	} {
+		if providers[name] != providerName {
+			t.Errorf("expected sample cluster %s of provider %s, got %q", name, providerName, providers[name])
+		}
+	}
+	if _, ok := providers["fake-closing"]; ok {
+		t.Error("expected providers without sample config to be left out")
+	}
+}
+
+func TestConfig_Init_PrivacyKeyFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, "privacy.key"), []byte("home-key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, keyFile := range []string{"keys/privacy.key", "~/privacy.key"} {
+		t.Run(keyFile, func(t *testing.T) {
+			dir := writeConfigFiles(t, map[string]string{
+				"config.yaml": `default_cluster: prod
+privacy:
+  enabled: true
+  key_file: ` + keyFile + `
+  mapping_file: ` + filepath.Join(home, "tokens.jsonl") + `
+clusters:
+  - name: prod
+    provider:
+      name: fake
+`,
+				"keys/privacy.key": "config-key",
+			})
+
+			// Relative paths are resolved against the directory of the config file
+			// and not the working directory
+			t.Chdir(t.TempDir())
+			config, err := NewConfigFromFile(filepath.Join(dir, "config.yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := config.Init(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if err := config.Close(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
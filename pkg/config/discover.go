@@ -0,0 +1,363 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider/alibaba"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider/aws"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider/gcp"
+	"github.com/mozillazg/kube-audit-mcp/pkg/utils"
+	"sigs.k8s.io/yaml"
+)
+
+const defaultKubeconfig = "~/.kube/config"
+
+var (
+	eksServerPattern = regexp.MustCompile(`\.([a-z0-9-]+)\.eks\.amazonaws\.com(\.cn)?(:\d+)?/?$`)
+	eksArnPattern    = regexp.MustCompile(`^arn:aws[a-z-]*:eks:([a-z0-9-]+):\d+:cluster/(.+)$`)
+	gkeNamePattern   = regexp.MustCompile(`^gke_([^_]+)_([^_]+)_(.+)$`)
+	ackIdPattern     = regexp.MustCompile(`\bc[0-9a-f]{32}\b`)
+	ackServerPattern = regexp.MustCompile(`\.([a-z]{2}-[a-z0-9-]+)\.cs\.aliyuncs\.com(:\d+)?/?$`)
+)
+
+// kubeconfig is the subset of the kubeconfig file that is used to discover
+// the clusters.
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Clusters       []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server string `json:"server"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Contexts []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster string `json:"cluster"`
+			User    string `json:"user"`
+		} `json:"context"`
+	} `json:"contexts"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			Exec *kubeconfigExec `json:"exec"`
+		} `json:"user"`
+	} `json:"users"`
+}
+
+type kubeconfigExec struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Env     []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"env"`
+}
+
+// arg returns the value of the first flag of the exec plugin in names, e.g.
+// --cluster-name NAME or --cluster-name=NAME.
+func (e *kubeconfigExec) arg(names ...string) string {
+	if e == nil {
+		return ""
+	}
+	for i, arg := range e.Args {
+		for _, name := range names {
+			if arg == name && i+1 < len(e.Args) {
+				return e.Args[i+1]
+			}
+			if value, ok := strings.CutPrefix(arg, name+"="); ok {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+func (e *kubeconfigExec) env(name string) string {
+	if e == nil {
+		return ""
+	}
+	for _, env := range e.Env {
+		if env.Name == name {
+			return env.Value
+		}
+	}
+	return ""
+}
+
+// kubeContext is a context of the kubeconfig with its cluster and user.
+type kubeContext struct {
+	name        string
+	clusterName string
+	userName    string
+	server      string
+	exec        *kubeconfigExec
+	// source is the kubeconfig file that defines the context.
+	source string
+}
+
+// DiscoveryResult is the result of discovering clusters from kubeconfig
+// files.
+type DiscoveryResult struct {
+	Clusters []*Cluster
+	// CurrentCluster is the name of the cluster of the current context, it
+	// is empty when the provider of the current context is unknown.
+	CurrentCluster string
+	// Skipped maps the names of the contexts whose provider is unknown to
+	// the reasons.
+	Skipped map[string]string
+}
+
+// KubeconfigFiles returns the kubeconfig files in path, which is a list of
+// files like $KUBECONFIG. The KUBECONFIG env var and ~/.kube/config are used
+// when path is empty.
+func KubeconfigFiles(path string) ([]string, error) {
+	if path == "" {
+		path = os.Getenv("KUBECONFIG")
+	}
+	if path == "" {
+		path = defaultKubeconfig
+	}
+
+	var files []string
+	for _, file := range filepath.SplitList(path) {
+		if file == "" {
+			continue
+		}
+		file, err := ExpandPath(file)
+		if err != nil {
+			return nil, fmt.Errorf("expanding kubeconfig path: %w", err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// DiscoverClusters discovers the clusters from the contexts of the kubeconfig
+// files. The provider is inferred from the server URL, the names and the exec
+// plugin of each context:
+//
+//   - EKS: the CloudWatch Logs log group /aws/eks/<name>/cluster.
+//   - GKE: the Cloud Logging logs of the project and the cluster.
+//   - ACK: the SLS project k8s-log-<cluster-id> and logstore audit-<cluster-id>.
+//
+// Contexts of the same cluster are merged, the names of the other contexts
+// become aliases.
+func DiscoverClusters(files []string) (*DiscoveryResult, error) {
+	contexts, currentContext, err := loadKubeContexts(files)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DiscoveryResult{Skipped: map[string]string{}}
+	discovered := map[string]*Cluster{}
+	for _, kctx := range contexts {
+		key, pconfig, alias, reason := inferProvider(kctx)
+		if reason != "" {
+			result.Skipped[kctx.name] = reason
+			continue
+		}
+
+		if cluster, ok := discovered[key]; ok {
+			cluster.Alias = utils.RemoveDuplicates(append(cluster.Alias, kctx.name))
+		} else {
+			cluster = &Cluster{
+				Name:        kctx.name,
+				Description: fmt.Sprintf("Discovered from kubeconfig context %s", kctx.name),
+				Provider:    pconfig,
+				source:      kctx.source,
+			}
+			if alias != "" && alias != kctx.name {
+				cluster.Alias = []string{alias}
+			}
+			discovered[key] = cluster
+			result.Clusters = append(result.Clusters, cluster)
+		}
+		if kctx.name == currentContext {
+			result.CurrentCluster = discovered[key].Name
+		}
+	}
+	return result, nil
+}
+
+// loadKubeContexts loads the contexts of the kubeconfig files, the first
+// file that defines a context, cluster or user wins like kubectl.
+func loadKubeContexts(files []string) ([]kubeContext, string, error) {
+	var configs []kubeconfig
+	var sources []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) && len(files) > 1 {
+				continue
+			}
+			return nil, "", fmt.Errorf("read kubeconfig %s: %w", file, err)
+		}
+		var kc kubeconfig
+		if err := yaml.Unmarshal(data, &kc); err != nil {
+			return nil, "", fmt.Errorf("parse kubeconfig %s: %w", file, err)
+		}
+		configs = append(configs, kc)
+		sources = append(sources, file)
+	}
+
+	servers := map[string]string{}
+	execs := map[string]*kubeconfigExec{}
+	var currentContext string
+	for _, kc := range configs {
+		if currentContext == "" {
+			currentContext = kc.CurrentContext
+		}
+		for _, c := range kc.Clusters {
+			if _, ok := servers[c.Name]; !ok {
+				servers[c.Name] = c.Cluster.Server
+			}
+		}
+		for _, u := range kc.Users {
+			if _, ok := execs[u.Name]; !ok {
+				execs[u.Name] = u.User.Exec
+			}
+		}
+	}
+
+	var contexts []kubeContext
+	seen := map[string]bool{}
+	for i, kc := range configs {
+		for _, c := range kc.Contexts {
+			if seen[c.Name] {
+				continue
+			}
+			seen[c.Name] = true
+			contexts = append(contexts, kubeContext{
+				name:        c.Name,
+				clusterName: c.Context.Cluster,
+				userName:    c.Context.User,
+				server:      servers[c.Context.Cluster],
+				exec:        execs[c.Context.User],
+				source:      sources[i],
+			})
+		}
+	}
+	return contexts, currentContext, nil
+}
+
+// inferProvider infers the provider of the context. It returns a key that
+// identifies the cluster, the provider config and the name of the cluster
+// in the cloud, or the reason why the provider is unknown.
+func inferProvider(kctx kubeContext) (key string, pconfig ProviderConfig, alias string, reason string) {
+	names := []string{kctx.clusterName, kctx.name}
+
+	// EKS
+	var eksName, eksRegion string
+	for _, name := range names {
+		if m := eksArnPattern.FindStringSubmatch(name); m != nil {
+			eksRegion, eksName = m[1], m[2]
+			break
+		}
+	}
+	eksServer := eksServerPattern.FindStringSubmatch(kctx.server)
+	if eksServer != nil && eksRegion == "" {
+		eksRegion = eksServer[1]
+	}
+	if isEKSExec(kctx.exec) {
+		if name := kctx.exec.arg("--cluster-name", "--cluster-id", "-i"); name != "" {
+			eksName = name
+		}
+		if region := kctx.exec.arg("--region"); region != "" {
+			eksRegion = region
+		}
+	}
+	if eksName != "" || eksServer != nil || isEKSExec(kctx.exec) {
+		if eksName == "" {
+			return "", pconfig, "", "EKS cluster name not found in the exec plugin args"
+		}
+		pconfig = ProviderConfig{
+			Name: aws.CloudWatchProviderName,
+			AwsCloudWatchLogs: &aws.CloudWatchLogsProviderConfig{
+				Region:       eksRegion,
+				LogGroupName: fmt.Sprintf("/aws/eks/%s/cluster", eksName),
+			},
+		}
+		profile := kctx.exec.arg("--profile")
+		if profile == "" {
+			profile = kctx.exec.env("AWS_PROFILE")
+		}
+		if profile != "" {
+			pconfig.AwsCloudWatchLogs.Credentials = &aws.CloudWatchLogsCredentialsConfig{Profile: profile}
+		}
+		return "eks/" + eksRegion + "/" + eksName + "/" + profile, pconfig, eksName, ""
+	}
+
+	// GKE
+	for _, name := range names {
+		if m := gkeNamePattern.FindStringSubmatch(name); m != nil {
+			pconfig = ProviderConfig{
+				Name: gcp.CloudLoggingProviderName,
+				GcpCloudLogging: &gcp.CloudLoggingProviderConfig{
+					ProjectId:   m[1],
+					ClusterName: m[3],
+				},
+			}
+			return "gke/" + m[1] + "/" + m[2] + "/" + m[3], pconfig, m[3], ""
+		}
+	}
+	if kctx.exec != nil && filepath.Base(kctx.exec.Command) == "gke-gcloud-auth-plugin" {
+		return "", pconfig, "", "GKE project and cluster name not found, expected a cluster named gke_<project>_<location>_<cluster>"
+	}
+
+	// ACK
+	var ackId string
+	for _, s := range append(names, kctx.userName, kctx.server) {
+		if ackId = ackIdPattern.FindString(s); ackId != "" {
+			break
+		}
+	}
+	if kctx.exec != nil {
+		for _, arg := range kctx.exec.Args {
+			if id := ackIdPattern.FindString(arg); id != "" {
+				ackId = id
+				break
+			}
+		}
+	}
+	if ackId != "" {
+		region := kctx.exec.arg("--region-id", "--region")
+		if region == "" {
+			region = kctx.exec.env("ALIBABA_CLOUD_REGION_ID")
+		}
+		if m := ackServerPattern.FindStringSubmatch(kctx.server); m != nil && region == "" {
+			region = m[1]
+		}
+		if region == "" {
+			return "", pconfig, "", fmt.Sprintf("region of ACK cluster %s not found", ackId)
+		}
+		pconfig = ProviderConfig{
+			Name: alibaba.SLSProviderName,
+			AlibabaSLS: &alibaba.SLSProviderConfig{
+				Region:   region,
+				Project:  "k8s-log-" + ackId,
+				LogStore: "audit-" + ackId,
+			},
+		}
+		return "ack/" + ackId, pconfig, ackId, ""
+	}
+
+	return "", pconfig, "", "unknown provider"
+}
+
+func isEKSExec(exec *kubeconfigExec) bool {
+	if exec == nil {
+		return false
+	}
+	switch filepath.Base(exec.Command) {
+	case "aws":
+		return utils.Contains(exec.Args, "eks") && utils.Contains(exec.Args, "get-token")
+	case "aws-iam-authenticator":
+		return true
+	}
+	return false
+}
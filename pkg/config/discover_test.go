@@ -0,0 +1,176 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider/alibaba"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider/aws"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider/gcp"
+)
+
+func TestDiscoverClusters(t *testing.T) {
+	kubeconfig := filepath.Join("testdata", "kubeconfig.yaml")
+	result, err := DiscoverClusters([]string{kubeconfig})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []*Cluster{
+		{
+			Name:        "arn:aws:eks:us-west-2:123456789012:cluster/prod",
+			Description: "Discovered from kubeconfig context arn:aws:eks:us-west-2:123456789012:cluster/prod",
+			Alias:       []string{"prod", "prod-readonly"},
+			Provider: ProviderConfig{
+				Name: aws.CloudWatchProviderName,
+				AwsCloudWatchLogs: &aws.CloudWatchLogsProviderConfig{
+					Region:       "us-west-2",
+					LogGroupName: "/aws/eks/prod/cluster",
+					Credentials:  &aws.CloudWatchLogsCredentialsConfig{Profile: "prod-admin"},
+				},
+			},
+			source: kubeconfig,
+		},
+		{
+			Name:        "staging",
+			Description: "Discovered from kubeconfig context staging",
+			Alias:       []string{"staging-cluster"},
+			Provider: ProviderConfig{
+				Name: aws.CloudWatchProviderName,
+				AwsCloudWatchLogs: &aws.CloudWatchLogsProviderConfig{
+					Region:       "eu-west-1",
+					LogGroupName: "/aws/eks/staging-cluster/cluster",
+				},
+			},
+			source: kubeconfig,
+		},
+		{
+			Name:        "gke_audit-prod-123_us-central1_web",
+			Description: "Discovered from kubeconfig context gke_audit-prod-123_us-central1_web",
+			Alias:       []string{"web"},
+			Provider: ProviderConfig{
+				Name: gcp.CloudLoggingProviderName,
+				GcpCloudLogging: &gcp.CloudLoggingProviderConfig{
+					ProjectId:   "audit-prod-123",
+					ClusterName: "web",
+				},
+			},
+			source: kubeconfig,
+		},
+		{
+			Name:        "hangzhou",
+			Description: "Discovered from kubeconfig context hangzhou",
+			Alias:       []string{"c0123456789abcdef0123456789abcdef"},
+			Provider: ProviderConfig{
+				Name: alibaba.SLSProviderName,
+				AlibabaSLS: &alibaba.SLSProviderConfig{
+					Region:   "cn-hangzhou",
+					Project:  "k8s-log-c0123456789abcdef0123456789abcdef",
+					LogStore: "audit-c0123456789abcdef0123456789abcdef",
+				},
+			},
+			source: kubeconfig,
+		},
+	}
+	if len(result.Clusters) != len(expected) {
+		t.Fatalf("expected %d clusters, got %d", len(expected), len(result.Clusters))
+	}
+	for i, cluster := range result.Clusters {
+		if !reflect.DeepEqual(cluster, expected[i]) {
+			t.Errorf("unexpected cluster %d:\nexpected %+v\ngot      %+v", i, expected[i], cluster)
+		}
+	}
+
+	if result.CurrentCluster != "arn:aws:eks:us-west-2:123456789012:cluster/prod" {
+		t.Errorf("unexpected current cluster %q", result.CurrentCluster)
+	}
+	expectedSkipped := map[string]string{
+		"ack-no-region": "region of ACK cluster c0123456789abcdef0123456789abcdee not found",
+		"minikube":      "unknown provider",
+	}
+	if !reflect.DeepEqual(result.Skipped, expectedSkipped) {
+		t.Errorf("expected skipped contexts %v, got %v", expectedSkipped, result.Skipped)
+	}
+}
+
+func TestKubeconfigFiles(t *testing.T) {
+	t.Setenv("KUBECONFIG", "/a/config"+string(filepath.ListSeparator)+"/b/config")
+	files, err := KubeconfigFiles("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"/a/config", "/b/config"}) {
+		t.Errorf("unexpected files %v", files)
+	}
+
+	files, err = KubeconfigFiles("/c/config")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"/c/config"}) {
+		t.Errorf("unexpected files %v", files)
+	}
+}
+
+func TestConfig_LoadFromFile_DiscoverFromKubeconfig(t *testing.T) {
+	kubeconfig, err := filepath.Abs(filepath.Join("testdata", "kubeconfig.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `discover_from_kubeconfig: true
+kubeconfig: ` + kubeconfig + `
+clusters:
+  - name: staging
+    provider:
+      name: aws-cloudwatch-logs
+      aws_cloudwatch_logs:
+        log_group_name: /custom/staging
+  - name: ack
+    alias: [web]
+    provider:
+      name: alibaba-sls
+`,
+	})
+
+	config, err := NewConfigFromFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.DefaultCluster != "arn:aws:eks:us-west-2:123456789012:cluster/prod" {
+		t.Errorf("expected the current context to be the default cluster, got %q", config.DefaultCluster)
+	}
+
+	var names []string
+	for _, cluster := range config.Clusters {
+		names = append(names, cluster.Name)
+	}
+	expectedNames := []string{
+		"staging",
+		"ack",
+		"arn:aws:eks:us-west-2:123456789012:cluster/prod",
+		"gke_audit-prod-123_us-central1_web",
+		"hangzhou",
+	}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("expected clusters %v, got %v", expectedNames, names)
+	}
+	if got := config.Clusters[0].Provider.AwsCloudWatchLogs.LogGroupName; got != "/custom/staging" {
+		t.Errorf("expected the configured cluster to take precedence, got log group %s", got)
+	}
+	if alias := config.Clusters[3].Alias; len(alias) != 0 {
+		t.Errorf("expected the alias used by another cluster to be dropped, got %v", alias)
+	}
+	if source := config.Clusters[2].Source(); source != kubeconfig {
+		t.Errorf("expected source %s, got %s", kubeconfig, source)
+	}
+
+	// The kubeconfig is not validated as a file of clusters.
+	for _, err := range ValidateFile(filepath.Join(dir, "config.yaml")) {
+		if strings.Contains(err.Error(), kubeconfig) {
+			t.Errorf("unexpected error of kubeconfig: %v", err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+var referencePattern = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+
+// interpolate expands the references in the values of the YAML document:
+//
+//   - ${NAME} is replaced with the value of the env var NAME, which must be set.
+//   - ${NAME:-default} is replaced with default when NAME is unset or empty.
+//   - ${file:/path} is replaced with the content of the file without the
+//     trailing newline, relative paths are relative to dir.
+//   - $${ is replaced with a literal ${.
+//
+// Unquoted values are resolved again after the expansion, so that
+// `qps: ${QPS}` is a number, quoted values are always strings.
+func interpolate(data []byte, dir string) ([]byte, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 {
+		return data, nil
+	}
+	if err := interpolateNode(&doc, dir); err != nil {
+		return nil, err
+	}
+	return yamlv3.Marshal(&doc)
+}
+
+func interpolateNode(node *yamlv3.Node, dir string) error {
+	switch node.Kind {
+	case yamlv3.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return nil
+		}
+		value, err := expandReferences(node.Value, dir)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		node.Value = value
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	case yamlv3.MappingNode:
+		// Only the values are expanded, the keys are kept as they are.
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := interpolateNode(node.Content[i], dir); err != nil {
+				return err
+			}
+		}
+	case yamlv3.DocumentNode, yamlv3.SequenceNode:
+		for _, n := range node.Content {
+			if err := interpolateNode(n, dir); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func expandReferences(s, dir string) (string, error) {
+	var err error
+	expanded := referencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		if ref == "$${" {
+			return "${"
+		}
+		var value string
+		value, err = resolveReference(ref[2:len(ref)-1], dir)
+		return value
+	})
+	return expanded, err
+}
+
+func resolveReference(ref, dir string) (string, error) {
+	if path, ok := strings.CutPrefix(ref, "file:"); ok {
+		path, err := ExpandPath(path)
+		if err != nil {
+			return "", fmt.Errorf("expanding path of ${%s}: %w", ref, err)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read file of ${%s}: %w", ref, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	name, defaultValue, hasDefault := strings.Cut(ref, ":-")
+	if name == "" {
+		return "", fmt.Errorf("invalid reference ${%s}: env var name is required", ref)
+	}
+	if value := os.Getenv(name); value != "" {
+		return value, nil
+	}
+	if hasDefault {
+		return defaultValue, nil
+	}
+	if _, ok := os.LookupEnv(name); ok {
+		return "", nil
+	}
+	return "", fmt.Errorf("env %s referenced by ${%s} is not set", name, ref)
+}
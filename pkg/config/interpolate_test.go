@@ -0,0 +1,117 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "multiline"), []byte("line1\nline2: x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_INTERPOLATE_PROJECT", "audit-prod")
+	t.Setenv("TEST_INTERPOLATE_QPS", "5")
+	t.Setenv("TEST_INTERPOLATE_EMPTY", "")
+
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "env var",
+			input:    "project: ${TEST_INTERPOLATE_PROJECT}-logs\n",
+			expected: "project: audit-prod-logs\n",
+		},
+		{
+			name:     "unquoted value is resolved again",
+			input:    "qps: ${TEST_INTERPOLATE_QPS}\n",
+			expected: "qps: 5\n",
+		},
+		{
+			name:     "quoted value is a string",
+			input:    "project: \"${TEST_INTERPOLATE_QPS}\"\n",
+			expected: "project: \"5\"\n",
+		},
+		{
+			name:     "default of unset env var",
+			input:    "region: ${TEST_INTERPOLATE_MISSING:-us-east-1}\n",
+			expected: "region: us-east-1\n",
+		},
+		{
+			name:     "default of empty env var",
+			input:    "region: ${TEST_INTERPOLATE_EMPTY:-us-east-1}\n",
+			expected: "region: us-east-1\n",
+		},
+		{
+			name:     "empty env var without default",
+			input:    "region: ${TEST_INTERPOLATE_EMPTY}\n",
+			expected: "region:\n",
+		},
+		{
+			name:     "relative file",
+			input:    "token: ${file:token}\n",
+			expected: "token: s3cr3t\n",
+		},
+		{
+			name:     "multiline file",
+			input:    "text: ${file:" + filepath.Join(dir, "multiline") + "}\n",
+			expected: "text: |-\n    line1\n    line2: x\n",
+		},
+		{
+			name:     "escaped reference",
+			input:    "query: $${NOT_EXPANDED} costs $$5\n",
+			expected: "query: ${NOT_EXPANDED} costs $$5\n",
+		},
+		{
+			name:     "keys and comments are not expanded",
+			input:    "# ${TEST_INTERPOLATE_MISSING}\n${KEY}: value\n",
+			expected: "# ${TEST_INTERPOLATE_MISSING}\n${KEY}: value\n",
+		},
+		{
+			name:     "sequence",
+			input:    "alias:\n    - ${TEST_INTERPOLATE_PROJECT}\n",
+			expected: "alias:\n    - audit-prod\n",
+		},
+		{
+			name:        "unset env var",
+			input:       "a: 1\nproject: ${TEST_INTERPOLATE_MISSING}\n",
+			expectedErr: "line 2: env TEST_INTERPOLATE_MISSING referenced by ${TEST_INTERPOLATE_MISSING} is not set",
+		},
+		{
+			name:        "missing file",
+			input:       "token: ${file:missing}\n",
+			expectedErr: "read file of ${file:missing}",
+		},
+		{
+			name:        "empty reference",
+			input:       "token: ${}\n",
+			expectedErr: "invalid reference ${}: env var name is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := interpolate([]byte(tt.input), dir)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(result) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, string(result))
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+package config
+
+// Providers that have no dedicated field in ProviderConfig, they are
+// configured with options.
+import _ "github.com/mozillazg/kube-audit-mcp/pkg/provider/execplugin"
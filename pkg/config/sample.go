@@ -1,51 +1,61 @@
 package config
 
 import (
-	"github.com/mozillazg/kube-audit-mcp/pkg/provider/alibaba"
-	"github.com/mozillazg/kube-audit-mcp/pkg/provider/aws"
-	"github.com/mozillazg/kube-audit-mcp/pkg/provider/gcp"
+	"encoding/json"
+	"reflect"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
 )
 
-var SampleConfig = Config{
-	DefaultCluster: "dev",
-	Clusters: []*Cluster{
-		{
-			Name:     "prod",
-			Alias:    []string{"aws-prod"},
-			Disabled: false,
-			Provider: ProviderConfig{
-				Name: aws.CloudWatchProviderName,
-				AwsCloudWatchLogs: &aws.CloudWatchLogsProviderConfig{
-					Region:             "",
-					LogGroupName:       "/aws/eks/xxx/cluster",
-					LogGroupIdentifier: "",
-				},
-			},
-		},
-		{
-			Name:     "dev",
-			Alias:    []string{"dev-cluster"},
-			Disabled: false,
-			Provider: ProviderConfig{
-				Name: alibaba.SLSProviderName,
-				AlibabaSLS: &alibaba.SLSProviderConfig{
-					Endpoint: "cn-hangzhou.log.aliyuncs.com",
-					Project:  "k8s-cxxx",
-					LogStore: "audit-cxxx",
-				},
-			},
-		},
-		{
-			Name:        "test",
-			Description: "",
-			Alias:       nil,
-			Disabled:    false,
-			Provider: ProviderConfig{
-				Name: gcp.CloudLoggingProviderName,
-				GcpCloudLogging: &gcp.CloudLoggingProviderConfig{
-					ProjectId: "test-233xxx",
-				},
-			},
-		},
-	},
+// sampleDefaultCluster is the default cluster of the sample config, the
+// first cluster is the default when no provider has a cluster of this name.
+const sampleDefaultCluster = "prod"
+
+// NewSampleConfig returns the sample config, which has a cluster of each
+// registered provider that has a sample config.
+func NewSampleConfig() *Config {
+	config := &Config{}
+	for _, r := range provider.Registrations() {
+		if r.SampleConfig == nil {
+			continue
+		}
+		pconfig := ProviderConfig{Name: r.Name}
+		if !pconfig.setConfig(r, r.SampleConfig) {
+			continue
+		}
+		name := r.SampleCluster
+		if name == "" {
+			name = r.Name
+		}
+		config.Clusters = append(config.Clusters, &Cluster{
+			Name:     name,
+			Provider: pconfig,
+		})
+		if name == sampleDefaultCluster {
+			config.DefaultCluster = name
+		}
+	}
+	if config.DefaultCluster == "" && len(config.Clusters) > 0 {
+		config.DefaultCluster = config.Clusters[0].Name
+	}
+	return config
+}
+
+// setConfig sets the dedicated field of the provider to config, or the
+// options when the provider has no dedicated field.
+func (p *ProviderConfig) setConfig(r provider.Registration, config any) bool {
+	if field, ok := dedicatedConfigField(r); ok {
+		value := reflect.ValueOf(config)
+		if value.Type() != field.Type {
+			return false
+		}
+		reflect.ValueOf(p).Elem().FieldByIndex(field.Index).Set(value)
+		return true
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, &p.Options) == nil
 }
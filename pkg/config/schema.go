@@ -0,0 +1,76 @@
+package config
+
+import (
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SchemaID is the $id of the JSON Schema of the config file.
+const SchemaID = "https://github.com/mozillazg/kube-audit-mcp/config.schema.json"
+
+var (
+	durationType  = reflect.TypeOf(metav1.Duration{})
+	quantityType  = reflect.TypeOf(resource.Quantity{})
+	configPkgPath = reflect.TypeOf(Cluster{}).PkgPath()
+)
+
+// Schema returns the JSON Schema of the config file, including the configs
+// of the providers. Unknown fields are not allowed like LoadFromFile.
+func Schema() *jsonschema.Schema {
+	r := &jsonschema.Reflector{
+		RequiredFromJSONSchemaTags: true,
+		Mapper: func(t reflect.Type) *jsonschema.Schema {
+			switch t {
+			case durationType:
+				return &jsonschema.Schema{
+					Type:    "string",
+					Pattern: `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`,
+				}
+			case quantityType:
+				return &jsonschema.Schema{
+					OneOf: []*jsonschema.Schema{{Type: "string"}, {Type: "integer"}},
+				}
+			}
+			return nil
+		},
+		// The configs of the other packages are named Config too, e.g.
+		// cache.Config becomes CacheConfig.
+		Namer: func(t reflect.Type) string {
+			if t.Name() != "Config" || t.PkgPath() == configPkgPath {
+				return t.Name()
+			}
+			pkg := path.Base(t.PkgPath())
+			return strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+		},
+	}
+	schema := r.Reflect(&Config{})
+	schema.ID = SchemaID
+	schema.Title = "kube-audit-mcp configuration"
+
+	// The provider names are the registered names, which editors complete,
+	// or their spellings with underscores that the loader accepts too, e.g.
+	// alibaba_sls.
+	if p, ok := schema.Definitions["ProviderConfig"]; ok {
+		if name, ok := p.Properties.Get("name"); ok {
+			registered := &jsonschema.Schema{}
+			var patterns []string
+			for _, n := range provider.Names() {
+				registered.Enum = append(registered.Enum, n)
+				patterns = append(patterns, strings.ReplaceAll(regexp.QuoteMeta(n), "-", "[-_]"))
+			}
+			name.Enum = nil
+			name.AnyOf = []*jsonschema.Schema{
+				registered,
+				{Pattern: "^(" + strings.Join(patterns, "|") + ")$"},
+			}
+		}
+	}
+	return schema
+}
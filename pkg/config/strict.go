@@ -0,0 +1,133 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/utils"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// checkUnknownFields returns the errors of the fields of the YAML document
+// that are not fields of t. The errors include the line, the YAML path of
+// the field and the known field with the closest name.
+func checkUnknownFields(data []byte, t reflect.Type) error {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	return errors.Join(unknownFields(doc.Content[0], t, "")...)
+}
+
+func unknownFields(node *yamlv3.Node, t reflect.Type, path string) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == yamlv3.AliasNode {
+		node = node.Alias
+	}
+	// Types with custom unmarshaling, e.g. metav1.Duration, are opaque.
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return nil
+	}
+
+	var errs []error
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yamlv3.MappingNode:
+		fields := jsonFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				errs = append(errs, unknownFields(value, t, path)...)
+				continue
+			}
+			fieldPath := joinYAMLPath(path, key.Value)
+			field, ok := fields[key.Value]
+			if !ok {
+				errs = append(errs, unknownFieldError(key, fieldPath, fields))
+				continue
+			}
+			errs = append(errs, unknownFields(value, field.Type, fieldPath)...)
+		}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && node.Kind == yamlv3.SequenceNode:
+		for i, item := range node.Content {
+			errs = append(errs, unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			errs = append(errs, unknownFields(node.Content[i+1], t.Elem(), joinYAMLPath(path, node.Content[i].Value))...)
+		}
+	}
+	return errs
+}
+
+// jsonFields returns the exported fields of the struct by their JSON names.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			if field.Anonymous {
+				ft := field.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					for n, f := range jsonFields(ft) {
+						fields[n] = f
+					}
+					continue
+				}
+			}
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+func unknownFieldError(key *yamlv3.Node, path string, fields map[string]reflect.StructField) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	if suggestion := didYouMean(key.Value, names); suggestion != "" {
+		return fmt.Errorf("line %d: unknown field %s, did you mean %s?", key.Line, path, suggestion)
+	}
+	return fmt.Errorf("line %d: unknown field %s", key.Line, path)
+}
+
+func joinYAMLPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// didYouMean returns the candidate that is the most similar to s, or an
+// empty string when none of them is similar enough.
+func didYouMean(s string, candidates []string) string {
+	if similar := utils.Similar(s, candidates); len(similar) > 0 {
+		return similar[0]
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+)
+
+func TestCheckUnknownFields(t *testing.T) {
+	configType := reflect.TypeOf(&Config{})
+	tests := []struct {
+		name     string
+		data     string
+		expected []string
+	}{
+		{
+			name: "valid",
+			data: `default_cluster: prod
+cache:
+  ttl: 5m
+clusters:
+  - name: prod
+    provider:
+      name: aws-cloudwatch-logs
+      aws_cloudwatch_logs:
+        log_group_name: /aws/eks/prod/cluster
+        query_timeout: 1m
+      guardrails:
+        max_bytes_scanned: 10Gi
+`,
+		},
+		{
+			name: "suggestion",
+			data: `default_cluster: prod
+clusters:
+  - name: prod
+  - name: staging
+    provider:
+      name: aws-cloudwatch-logs
+      aws_cloudwatch_logs:
+        log_group: /aws/eks/staging/cluster
+`,
+			expected: []string{
+				"line 8: unknown field clusters[1].provider.aws_cloudwatch_logs.log_group, did you mean log_group_name?",
+			},
+		},
+		{
+			name: "no suggestion",
+			data: `default_cluster: prod
+foo: bar
+`,
+			expected: []string{"line 2: unknown field foo"},
+		},
+		{
+			name: "multiple errors",
+			data: `defualt_cluster: prod
+clusters:
+  - nmae: prod
+    provider:
+      name: alibaba-sls
+      alibaba_sls:
+        log_store: audit
+`,
+			expected: []string{
+				"line 1: unknown field defualt_cluster, did you mean default_cluster?",
+				"line 3: unknown field clusters[0].nmae, did you mean name?",
+				"line 7: unknown field clusters[0].provider.alibaba_sls.log_store, did you mean logstore?",
+			},
+		},
+		{
+			name: "merge keys",
+			data: `defaults: &defaults
+  regoin: us-east-1
+clusters:
+  - name: prod
+    provider:
+      name: aws-cloudwatch-logs
+      aws_cloudwatch_logs:
+        <<: *defaults
+`,
+			expected: []string{
+				"line 1: unknown field defaults",
+				"line 2: unknown field clusters[0].provider.aws_cloudwatch_logs.regoin, did you mean region?",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkUnknownFields([]byte(tt.data), configType)
+			if len(tt.expected) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors %v, got nil", tt.expected)
+			}
+			if got := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected errors %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestConfig_LoadFromFile_UnknownFields(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `default_cluster: prod
+include: [prod.yaml]
+`,
+		"prod.yaml": `name: prod
+provider:
+  name: alibaba-sls
+  alibaba_sls:
+    projcet: k8s-log
+`,
+	})
+
+	err := (&Config{}).LoadFromFile(dir + "/config.yaml")
+	expected := "invalid config file " + dir + "/prod.yaml: line 5: unknown field provider.alibaba_sls.projcet, did you mean project?"
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected error to contain %q, got %v", expected, err)
+	}
+}
+
+func TestConfig_LoadFromFile_UnknownFieldsLine(t *testing.T) {
+	t.Setenv("TEST_SLS_PROJECT", "k8s-log")
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `# The clusters of the team.
+
+default_cluster: prod
+
+clusters:
+  # Production.
+  - name: prod
+
+    provider:
+      name: alibaba-sls
+      alibaba_sls:
+        # The project of the audit logs.
+        project: ${TEST_SLS_PROJECT}
+
+        # The logstore.
+        log_store: audit
+`,
+	})
+
+	err := (&Config{}).LoadFromFile(dir + "/config.yaml")
+	expected := "line 16: unknown field clusters[0].provider.alibaba_sls.log_store, did you mean logstore?"
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected error to contain %q, got %v", expected, err)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+	if schema.ID != SchemaID {
+		t.Errorf("expected $id %s, got %s", SchemaID, schema.ID)
+	}
+	for _, name := range []string{"Config", "Cluster", "ProviderConfig", "SLSProviderConfig", "CacheConfig", "RedactConfig"} {
+		def, ok := schema.Definitions[name]
+		if !ok {
+			t.Errorf("expected definition %s", name)
+			continue
+		}
+		if def.AdditionalProperties == nil {
+			t.Errorf("expected additional properties of %s to be disallowed", name)
+		}
+	}
+
+	name, _ := schema.Definitions["ProviderConfig"].Properties.Get("name")
+	if len(name.AnyOf) != 2 || len(name.AnyOf[0].Enum) != len(provider.Names()) || name.AnyOf[0].Enum[0] != "alibaba-sls" {
+		t.Fatalf("expected the registered provider names, got %v", name.AnyOf)
+	}
+	pattern := regexp.MustCompile(name.AnyOf[1].Pattern)
+	for value, expected := range map[string]bool{
+		"alibaba-sls": true, "alibaba_sls": true, "aws_cloudwatch_logs": true, "gcp-cloud_logging": true,
+		"alibaba": false, "alibaba-sls-x": false, "alibaba.sls": false,
+	} {
+		// The loader accepts the same names.
+		_, registered := provider.Lookup(ProviderConfig{Name: value}.normalizedName())
+		if got := pattern.MatchString(value); got != expected || registered != expected {
+			t.Errorf("expected the provider name %q to be valid %v, got %v in the schema and %v in the loader", value, expected, got, registered)
+		}
+	}
+	ttl, _ := schema.Definitions["CacheConfig"].Properties.Get("ttl")
+	if ttl.Type != "string" {
+		t.Errorf("expected ttl to be a string, got %q", ttl.Type)
+	}
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"log"
+)
+
+// ValidateFile validates the config file and the files it includes, and
+// returns all the problems found. Besides the checks of LoadFromFile and
+// Init, it rejects cluster names or aliases that are used more than once.
+// The providers created by Init are closed before it returns.
+func ValidateFile(filePath string) []error {
+	config := &Config{}
+	if err := config.LoadFromFile(filePath); err != nil {
+		return []error{err}
+	}
+	defer func() {
+		if err := config.Close(); err != nil {
+			log.Printf("closing configuration: %v", err)
+		}
+	}()
+
+	var errs []error
+	names := map[string]*Cluster{}
+	for _, cluster := range config.Clusters {
+		for _, name := range append([]string{cluster.Name}, cluster.Alias...) {
+			if other, ok := names[name]; ok && other != cluster {
+				errs = append(errs, fmt.Errorf("name %s of cluster %s is already used by cluster %s",
+					name, cluster.describe(), other.describe()))
+				continue
+			}
+			names[name] = cluster
+		}
+	}
+
+	if err := config.Init(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
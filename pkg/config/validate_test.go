@@ -0,0 +1,121 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
+)
+
+func TestValidateFile(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `clusters_dir: clusters.d
+clusters:
+  - name: prod
+    alias: [p]
+    disabled: true
+    provider:
+      name: alibaba-sls
+`,
+		"clusters.d/staging.yaml": `name: staging
+alias: [p]
+disabled: true
+provider:
+  name: aws-cloudwatch-logs
+  aws_cloudwatch_logs:
+    log_group_name: /aws/eks/staging/cluster
+    region: us-east-1
+`,
+	})
+
+	errs := ValidateFile(filepath.Join(dir, "config.yaml"))
+	expected := []string{
+		"name p of cluster staging (" + filepath.Join(dir, "clusters.d/staging.yaml") + ") is already used by cluster prod (" + filepath.Join(dir, "config.yaml") + ")",
+		"default_cluster is required",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, err := range errs {
+		if !strings.Contains(err.Error(), expected[i]) {
+			t.Errorf("expected error %d to contain %q, got %q", i, expected[i], err.Error())
+		}
+	}
+}
+
+func TestValidateFile_UnknownFields(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `defualt_cluster: prod
+clusters:
+  - name: prod
+    provider:
+      name: aws-cloudwatch-logs
+      aws_cloudwatch_logs:
+        log_group_name: /aws/eks/prod/cluster
+        regoin: us-east-1
+`,
+	})
+
+	errs := ValidateFile(filepath.Join(dir, "config.yaml"))
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	for _, expected := range []string{
+		"line 1: unknown field defualt_cluster, did you mean default_cluster?",
+		"line 8: unknown field clusters[0].provider.aws_cloudwatch_logs.regoin, did you mean region?",
+	} {
+		if !strings.Contains(errs[0].Error(), expected) {
+			t.Errorf("expected error to contain %q, got %q", expected, errs[0].Error())
+		}
+	}
+}
+
+func TestValidateFile_LoadError(t *testing.T) {
+	errs := ValidateFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "failed to read config file") {
+		t.Errorf("expected read error, got %v", errs)
+	}
+}
+
+type closingProvider struct {
+	provider.Provider
+}
+
+var closedProviders atomic.Int32
+
+func (p *closingProvider) Close() error {
+	closedProviders.Add(1)
+	return nil
+}
+
+func init() {
+	provider.Register(provider.Registration{
+		Name:         "fake-closing",
+		DecodeConfig: provider.DecodeConfig[fakeProviderConfig],
+		New: func(_ any, _ *transport.Config) (provider.Provider, error) {
+			return &closingProvider{}, nil
+		},
+	})
+}
+
+func TestValidateFile_ClosesProviders(t *testing.T) {
+	closedProviders.Store(0)
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `default_cluster: prod
+clusters:
+  - name: prod
+    provider:
+      name: fake-closing
+`,
+	})
+
+	if errs := ValidateFile(filepath.Join(dir, "config.yaml")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if n := closedProviders.Load(); n != 1 {
+		t.Errorf("expected the provider to be closed once, got %d", n)
+	}
+}
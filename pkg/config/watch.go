@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Watch polls the config file, the files it includes and the prompt files
+// every interval, and calls onChange when their content changes, until ctx
+// is done. The files and their directories are checked by their modification
+// times first, and the config is only loaded again when one of them changed,
+// because loading may discover clusters from kubeconfig and read secret
+// files. The files are read again by path, so editors that save by renaming a
+// new file over the old one are handled too. Errors of reading the files are
+// ignored, because a file may be missing while it is being replaced.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	paths, last := readWatchedFiles(path)
+	stats := statFiles(paths)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if maps.Equal(statFiles(paths), stats) {
+			continue
+		}
+		var data []byte
+		paths, data = readWatchedFiles(path)
+		stats = statFiles(paths)
+		if bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		onChange()
+	}
+}
+
+// fileStat is compared to detect the changes of a watched file or directory
+// without reading it, the zero value means that it does not exist.
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFiles(paths []string) map[string]fileStat {
+	stats := make(map[string]fileStat, len(paths))
+	for _, p := range paths {
+		var stat fileStat
+		if info, err := os.Stat(p); err == nil {
+			stat = fileStat{modTime: info.ModTime(), size: info.Size()}
+		}
+		stats[p] = stat
+	}
+	return stats
+}
+
+// readWatchedFiles returns the paths that are checked for changes and the
+// names and the content of the config file, the files it includes and the
+// prompt files. The paths are the files and the directories that contain
+// them or the included files, so that added files are detected too.
+func readWatchedFiles(path string) ([]string, []byte) {
+	files := []string{path}
+	dirs := []string{filepath.Dir(path)}
+	config := &Config{}
+	if err := config.LoadFromFile(path); err == nil {
+		for _, cluster := range config.Clusters {
+			if cluster.source != path {
+				files = append(files, cluster.source)
+			}
+		}
+		if config.Prompts != nil {
+			files = append(files, config.Prompts.Files...)
+		}
+		dirs = append(dirs, config.includeDirs(filepath.Dir(path))...)
+	}
+
+	var buf bytes.Buffer
+	seen := map[string]bool{}
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+		dirs = append(dirs, filepath.Dir(file))
+		data, _ := os.ReadFile(file)
+		buf.WriteString(file)
+		buf.WriteByte(0)
+		buf.Write(data)
+		buf.WriteByte(0)
+	}
+
+	paths := files[:0:0]
+	for p := range seen {
+		paths = append(paths, p)
+	}
+	for _, dir := range dirs {
+		if !seen[dir] {
+			seen[dir] = true
+			paths = append(paths, dir)
+		}
+	}
+	return paths, buf.Bytes()
+}
+
+// includeDirs returns the directories that the included files are looked up
+// in, i.e. clusters_dir and the directories of the include patterns.
+func (c *Config) includeDirs(dir string) []string {
+	var dirs []string
+	for _, pattern := range c.Include {
+		p, err := resolvePath(pattern, dir)
+		if err != nil {
+			continue
+		}
+		if patternDir := filepath.Dir(p); !strings.ContainsAny(patternDir, "*?[") {
+			dirs = append(dirs, patternDir)
+		}
+	}
+	if c.ClustersDir != "" {
+		if p, err := resolvePath(c.ClustersDir, dir); err == nil {
+			dirs = append(dirs, p)
+		}
+	}
+	return dirs
+}
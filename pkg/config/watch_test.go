@@ -0,0 +1,94 @@
+package config
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("default_cluster: a\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 10)
+	go Watch(ctx, path, 10*time.Millisecond, func() { changes <- struct{}{} })
+
+	// Touching the file without changing the content is not a change.
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("default_cluster: a\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+		t.Fatal("unexpected change")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Replace the file by renaming, like most editors do.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte("default_cluster: b\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change was not detected")
+	}
+}
+
+func TestWatch_ClustersDir(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml":       "clusters_dir: clusters.d\n",
+		"clusters.d/a.yaml": "name: a\n",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 10)
+	go Watch(ctx, filepath.Join(dir, "config.yaml"), 10*time.Millisecond, func() { changes <- struct{}{} })
+	time.Sleep(30 * time.Millisecond)
+
+	for _, step := range []func() error{
+		func() error { return os.WriteFile(filepath.Join(dir, "clusters.d/a.yaml"), []byte("name: a2\n"), 0600) },
+		func() error { return os.WriteFile(filepath.Join(dir, "clusters.d/b.yaml"), []byte("name: b\n"), 0600) },
+		func() error { return os.Remove(filepath.Join(dir, "clusters.d/a.yaml")) },
+	} {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-changes:
+		case <-time.After(time.Second):
+			t.Fatal("change was not detected")
+		}
+	}
+}
+
+func TestReadWatchedFiles_Paths(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml":       "clusters_dir: clusters.d\n",
+		"clusters.d/a.yaml": "name: a\n",
+	})
+	path := filepath.Join(dir, "config.yaml")
+
+	paths, _ := readWatchedFiles(path)
+	stats := statFiles(paths)
+	for _, p := range []string{path, dir, filepath.Join(dir, "clusters.d"), filepath.Join(dir, "clusters.d/a.yaml")} {
+		if _, ok := stats[p]; !ok {
+			t.Errorf("%s is not watched, got %v", p, paths)
+		}
+	}
+	if !maps.Equal(statFiles(paths), stats) {
+		t.Error("stats changed without changing the files")
+	}
+}
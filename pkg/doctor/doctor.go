@@ -0,0 +1,107 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+)
+
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+const (
+	CheckConfig      = "config"
+	CheckCredentials = "credentials"
+	CheckQuery       = "query"
+	CheckNewestEvent = "newest event"
+)
+
+// Result is the result of a check of a cluster.
+type Result struct {
+	Cluster string
+	Check   string
+	Status  Status
+	Detail  string
+}
+
+type Options struct {
+	// Since is the time range that is searched for the newest audit event.
+	Since time.Duration
+	// MaxAge is the age of the newest audit event above which a warning is
+	// reported, because audit logs may not be flowing.
+	MaxAge time.Duration
+}
+
+// Check checks that the credentials of the provider are valid, that it can
+// query the audit logs and how old the newest audit event is.
+func Check(ctx context.Context, cluster string, p provider.Provider, opts Options) []Result {
+	newResult := func(check string, status Status, format string, args ...any) Result {
+		return Result{Cluster: cluster, Check: check, Status: status, Detail: fmt.Sprintf(format, args...)}
+	}
+	var results []Result
+
+	if checker, ok := p.(provider.CredentialsChecker); ok {
+		if err := checker.CheckCredentials(ctx); err != nil {
+			return append(results,
+				newResult(CheckCredentials, StatusFail, "%v", err),
+				newResult(CheckQuery, StatusSkip, "invalid credentials"),
+				newResult(CheckNewestEvent, StatusSkip, "invalid credentials"))
+		}
+		results = append(results, newResult(CheckCredentials, StatusPass, "credentials are valid"))
+	} else {
+		results = append(results, newResult(CheckCredentials, StatusSkip, "checked by the query"))
+	}
+
+	now := time.Now().UTC()
+	result, err := p.QueryAuditLog(ctx, types.QueryAuditLogParams{
+		ClusterName: cluster,
+		StartTime:   types.NewTimeParam(now.Add(-opts.Since)),
+		EndTime:     types.NewTimeParam(now),
+		Limit:       1,
+	})
+	if err != nil {
+		return append(results,
+			newResult(CheckQuery, StatusFail, "%v", err),
+			newResult(CheckNewestEvent, StatusSkip, "query failed"))
+	}
+	results = append(results, newResult(CheckQuery, StatusPass, "audit logs can be queried"))
+
+	if len(result.Entries) == 0 {
+		return append(results, newResult(CheckNewestEvent, StatusWarn,
+			"no audit events in the last %s, check that audit logs are collected", opts.Since))
+	}
+	newest := eventTime(result.Entries[0])
+	age := now.Sub(newest).Truncate(time.Second)
+	if age > opts.MaxAge {
+		return append(results, newResult(CheckNewestEvent, StatusWarn,
+			"newest audit event is %s old (%s), audit logs may be delayed or not collected",
+			age, newest.Format(time.RFC3339)))
+	}
+	return append(results, newResult(CheckNewestEvent, StatusPass,
+		"newest audit event is %s old (%s)", age, newest.Format(time.RFC3339)))
+}
+
+func eventTime(entry types.AuditLogEntry) time.Time {
+	if !entry.StageTimestamp.IsZero() {
+		return entry.StageTimestamp.Time
+	}
+	return entry.RequestReceivedTimestamp.Time
+}
+
+// Summary counts the results of each status.
+func Summary(results []Result) map[Status]int {
+	summary := map[Status]int{}
+	for _, r := range results {
+		summary[r.Status]++
+	}
+	return summary
+}
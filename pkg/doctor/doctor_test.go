@@ -0,0 +1,132 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type mockProvider struct {
+	entries  []types.AuditLogEntry
+	err      error
+	params   types.QueryAuditLogParams
+	queries  int
+	credsErr error
+}
+
+func (m *mockProvider) QueryAuditLog(_ context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	m.queries++
+	m.params = params
+	return types.AuditLogResult{Entries: m.entries}, m.err
+}
+
+func (m *mockProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{}
+}
+
+type mockCredentialsProvider struct {
+	mockProvider
+}
+
+func (m *mockCredentialsProvider) CheckCredentials(_ context.Context) error {
+	return m.credsErr
+}
+
+func eventAt(t time.Time) types.AuditLogEntry {
+	return types.AuditLogEntry{StageTimestamp: metav1.NewMicroTime(t)}
+}
+
+func TestCheck(t *testing.T) {
+	opts := Options{Since: 24 * time.Hour, MaxAge: time.Hour}
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		provider provider.Provider
+		expected []Status
+		detail   string
+	}{
+		{
+			name:     "recent event",
+			provider: &mockCredentialsProvider{mockProvider{entries: []types.AuditLogEntry{eventAt(now.Add(-5 * time.Minute))}}},
+			expected: []Status{StatusPass, StatusPass, StatusPass},
+			detail:   "newest audit event is 5m0s old",
+		},
+		{
+			name:     "credentials are not checked by the provider",
+			provider: &mockProvider{entries: []types.AuditLogEntry{eventAt(now.Add(-5 * time.Minute))}},
+			expected: []Status{StatusSkip, StatusPass, StatusPass},
+			detail:   "newest audit event is 5m0s old",
+		},
+		{
+			name:     "old event",
+			provider: &mockProvider{entries: []types.AuditLogEntry{eventAt(now.Add(-3 * time.Hour))}},
+			expected: []Status{StatusSkip, StatusPass, StatusWarn},
+			detail:   "newest audit event is 3h0m0s old",
+		},
+		{
+			name: "request received timestamp",
+			provider: &mockProvider{entries: []types.AuditLogEntry{
+				{RequestReceivedTimestamp: metav1.NewMicroTime(now.Add(-10 * time.Minute))},
+			}},
+			expected: []Status{StatusSkip, StatusPass, StatusPass},
+			detail:   "newest audit event is 10m0s old",
+		},
+		{
+			name:     "no events",
+			provider: &mockProvider{},
+			expected: []Status{StatusSkip, StatusPass, StatusWarn},
+			detail:   "no audit events in the last 24h0m0s",
+		},
+		{
+			name:     "query failed",
+			provider: &mockProvider{err: errors.New("AccessDeniedException: not authorized")},
+			expected: []Status{StatusSkip, StatusFail, StatusSkip},
+			detail:   "query failed",
+		},
+		{
+			name:     "invalid credentials",
+			provider: &mockCredentialsProvider{mockProvider{credsErr: errors.New("expired token")}},
+			expected: []Status{StatusFail, StatusSkip, StatusSkip},
+			detail:   "invalid credentials",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Check(context.Background(), "prod", tt.provider, opts)
+			var statuses []Status
+			for _, r := range results {
+				assert.Equal(t, "prod", r.Cluster)
+				statuses = append(statuses, r.Status)
+			}
+			assert.Equal(t, tt.expected, statuses)
+			assert.Equal(t, []string{CheckCredentials, CheckQuery, CheckNewestEvent},
+				[]string{results[0].Check, results[1].Check, results[2].Check})
+			assert.Contains(t, results[2].Detail, tt.detail)
+		})
+	}
+}
+
+func TestCheck_QueryParams(t *testing.T) {
+	p := &mockProvider{}
+	Check(context.Background(), "prod", p, Options{Since: 6 * time.Hour, MaxAge: time.Hour})
+
+	assert.Equal(t, 1, p.queries)
+	assert.Equal(t, 1, p.params.Limit)
+	assert.Equal(t, "prod", p.params.ClusterName)
+	assert.WithinDuration(t, p.params.EndTime.Add(-6*time.Hour), p.params.StartTime.Time, time.Second)
+}
+
+func TestSummary(t *testing.T) {
+	summary := Summary([]Result{
+		{Status: StatusPass}, {Status: StatusPass}, {Status: StatusWarn}, {Status: StatusFail},
+	})
+	assert.Equal(t, map[Status]int{StatusPass: 2, StatusWarn: 1, StatusFail: 1}, summary)
+}
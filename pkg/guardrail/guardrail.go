@@ -0,0 +1,164 @@
+package guardrail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type Config struct {
+	// MaxTimeRange is the maximum time window of a query, e.g. 7d is "168h".
+	MaxTimeRange metav1.Duration `yaml:"max_time_range,omitempty" json:"max_time_range,omitempty"`
+	// MaxBytesScanned is the maximum (estimated) bytes scanned by a query,
+	// e.g. "10Gi". Supported by aws-cloudwatch-logs and alibaba-sls.
+	MaxBytesScanned *resource.Quantity `yaml:"max_bytes_scanned,omitempty" json:"max_bytes_scanned,omitempty"`
+	// DailyQueryBudget is the maximum number of queries per day (UTC).
+	DailyQueryBudget int `yaml:"daily_query_budget,omitempty" json:"daily_query_budget,omitempty"`
+}
+
+// Provider rejects queries of the wrapped provider that violate the
+// guardrails of the cluster.
+type Provider struct {
+	next    provider.Provider
+	config  Config
+	cluster string
+	budget  *budget
+}
+
+type budget struct {
+	day  string
+	used int
+	mu   sync.Mutex
+}
+
+var _ provider.Provider = (*Provider)(nil)
+
+// budgets are shared by cluster name, so that the daily usage survives
+// re-creating the provider.
+var (
+	budgets   = map[string]*budget{}
+	budgetsMu sync.Mutex
+)
+
+// Variable for mocking in tests
+var now = time.Now
+
+type withoutBudgetKey struct{}
+
+// WithoutBudget returns a context whose queries do not count against the
+// daily query budget, e.g. the queries of argument completion, which the
+// agent does not ask for.
+func WithoutBudget(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutBudgetKey{}, true)
+}
+
+func NewProvider(next provider.Provider, config *Config, cluster string) *Provider {
+	budgetsMu.Lock()
+	defer budgetsMu.Unlock()
+
+	b, ok := budgets[cluster]
+	if !ok {
+		b = &budget{}
+		budgets[cluster] = b
+	}
+
+	return &Provider{
+		next:    next,
+		config:  *config,
+		cluster: cluster,
+		budget:  b,
+	}
+}
+
+func (p *Provider) QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	if err := p.checkTimeRange(params); err != nil {
+		return types.AuditLogResult{}, err
+	}
+	if exempt, _ := ctx.Value(withoutBudgetKey{}).(bool); !exempt {
+		if err := p.consumeBudget(); err != nil {
+			return types.AuditLogResult{}, err
+		}
+	}
+
+	if p.config.MaxBytesScanned != nil {
+		ctx = provider.WithMaxBytesScanned(ctx, p.config.MaxBytesScanned.Value())
+	}
+	result, err := p.next.QueryAuditLog(ctx, params)
+
+	var scanErr *provider.ScanLimitError
+	if errors.As(err, &scanErr) {
+		return result, fmt.Errorf("%w. Narrow the time window with start_time/end_time, "+
+			"or add filters such as namespace, resource_types, verbs or user "+
+			"to reduce the data scanned on cluster %s", err, p.cluster)
+	}
+	return result, err
+}
+
+// Capabilities returns the capabilities of the wrapped provider, with the
+// maximum time range of the guardrails.
+func (p *Provider) Capabilities() provider.Capabilities {
+	capabilities := p.next.Capabilities()
+	if maxRange := p.config.MaxTimeRange; maxRange.Duration > 0 &&
+		(capabilities.MaxTimeRange.Duration == 0 || maxRange.Duration < capabilities.MaxTimeRange.Duration) {
+		capabilities.MaxTimeRange = maxRange
+	}
+	return capabilities
+}
+
+// Close closes the wrapped provider.
+func (p *Provider) Close() error {
+	return provider.Close(p.next)
+}
+
+func (p *Provider) checkTimeRange(params types.QueryAuditLogParams) error {
+	maxRange := p.config.MaxTimeRange.Duration
+	if maxRange <= 0 {
+		return nil
+	}
+
+	end := params.EndTime.Time
+	if end.IsZero() {
+		end = now()
+	}
+	if params.StartTime.IsZero() || end.Sub(params.StartTime.Time) <= maxRange {
+		return nil
+	}
+
+	return fmt.Errorf("the time range of the query (%s) exceeds the maximum of %s allowed for cluster %s. "+
+		"Narrow the window, e.g. set start_time to %q with the current end_time, "+
+		"or split the investigation into smaller windows",
+		end.Sub(params.StartTime.Time).Round(time.Second), maxRange, p.cluster,
+		end.Add(-maxRange).UTC().Format(time.RFC3339))
+}
+
+func (p *Provider) consumeBudget() error {
+	limit := p.config.DailyQueryBudget
+	if limit <= 0 {
+		return nil
+	}
+
+	p.budget.mu.Lock()
+	defer p.budget.mu.Unlock()
+
+	t := now().UTC()
+	day := t.Format(time.DateOnly)
+	if p.budget.day != day {
+		p.budget.day = day
+		p.budget.used = 0
+	}
+	if p.budget.used >= limit {
+		resetAt := t.Truncate(24 * time.Hour).Add(24 * time.Hour)
+		return fmt.Errorf("the daily query budget of %d queries for cluster %s is exhausted, "+
+			"it resets at %s. Reuse the results of previous queries, "+
+			"or ask the user to raise daily_query_budget", limit, p.cluster, resetAt.Format(time.RFC3339))
+	}
+	p.budget.used++
+	return nil
+}
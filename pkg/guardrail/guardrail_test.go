@@ -0,0 +1,184 @@
+package guardrail
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type mockProvider struct {
+	calls        int
+	maxBytes     int64
+	err          error
+	capabilities provider.Capabilities
+}
+
+func (m *mockProvider) QueryAuditLog(ctx context.Context, _ types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	m.calls++
+	m.maxBytes = provider.MaxBytesScanned(ctx)
+	return types.AuditLogResult{}, m.err
+}
+
+func (m *mockProvider) Capabilities() provider.Capabilities {
+	return m.capabilities
+}
+
+func setNow(t *testing.T, tm time.Time) {
+	orig := now
+	now = func() time.Time { return tm }
+	t.Cleanup(func() { now = orig })
+}
+
+func TestProvider_MaxTimeRange(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	setNow(t, base)
+
+	next := &mockProvider{}
+	p := NewProvider(next, &Config{
+		MaxTimeRange: metav1.Duration{Duration: 7 * 24 * time.Hour},
+	}, "max-time-range")
+
+	tests := []struct {
+		name        string
+		params      types.QueryAuditLogParams
+		expectedErr string
+	}{
+		{
+			name: "within range",
+			params: types.QueryAuditLogParams{
+				StartTime: types.NewTimeParam(base.Add(-24 * time.Hour)),
+				EndTime:   types.NewTimeParam(base),
+			},
+		},
+		{
+			name: "exactly the maximum",
+			params: types.QueryAuditLogParams{
+				StartTime: types.NewTimeParam(base.Add(-7 * 24 * time.Hour)),
+				EndTime:   types.NewTimeParam(base),
+			},
+		},
+		{
+			name: "exceeds the maximum",
+			params: types.QueryAuditLogParams{
+				StartTime: types.NewTimeParam(base.Add(-90 * 24 * time.Hour)),
+				EndTime:   types.NewTimeParam(base),
+			},
+			expectedErr: `the time range of the query (2160h0m0s) exceeds the maximum of 168h0m0s allowed for cluster max-time-range. ` +
+				`Narrow the window, e.g. set start_time to "2025-05-25T12:00:00Z" with the current end_time`,
+		},
+		{
+			name: "end time defaults to now",
+			params: types.QueryAuditLogParams{
+				StartTime: types.NewTimeParam(base.Add(-8 * 24 * time.Hour)),
+			},
+			expectedErr: "exceeds the maximum of 168h0m0s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.QueryAuditLog(context.Background(), tt.params)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestProvider_DailyQueryBudget(t *testing.T) {
+	base := time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC)
+	setNow(t, base)
+
+	next := &mockProvider{}
+	p := NewProvider(next, &Config{DailyQueryBudget: 2}, "daily-budget")
+
+	for i := 0; i < 2; i++ {
+		_, err := p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+		assert.NoError(t, err)
+	}
+	_, err := p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+	assert.EqualError(t, err, "the daily query budget of 2 queries for cluster daily-budget is exhausted, "+
+		"it resets at 2025-06-02T00:00:00Z. Reuse the results of previous queries, "+
+		"or ask the user to raise daily_query_budget")
+	assert.Equal(t, 2, next.calls)
+
+	// The usage is shared with providers re-created for the same cluster
+	p = NewProvider(next, &Config{DailyQueryBudget: 2}, "daily-budget")
+	_, err = p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+	assert.ErrorContains(t, err, "is exhausted")
+
+	// The budget resets on the next day
+	setNow(t, base.Add(2*time.Hour))
+	_, err = p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, next.calls)
+}
+
+func TestProvider_WithoutBudget(t *testing.T) {
+	setNow(t, time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	next := &mockProvider{}
+	p := NewProvider(next, &Config{DailyQueryBudget: 1}, "without-budget")
+
+	for i := 0; i < 3; i++ {
+		_, err := p.QueryAuditLog(WithoutBudget(context.Background()), types.QueryAuditLogParams{})
+		assert.NoError(t, err)
+	}
+	_, err := p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+	assert.NoError(t, err)
+	_, err = p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+	assert.ErrorContains(t, err, "is exhausted")
+	assert.Equal(t, 4, next.calls)
+}
+
+func TestProvider_MaxBytesScanned(t *testing.T) {
+	limit := resource.MustParse("1Gi")
+	next := &mockProvider{
+		err: &provider.ScanLimitError{Limit: 1 << 30, Scanned: 5 << 30},
+	}
+	p := NewProvider(next, &Config{MaxBytesScanned: &limit}, "max-bytes")
+
+	_, err := p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+	assert.Equal(t, int64(1<<30), next.maxBytes)
+	assert.EqualError(t, err, "query scanned 5368709120 bytes, which exceeds the limit of 1073741824 bytes. "+
+		"Narrow the time window with start_time/end_time, or add filters such as namespace, "+
+		"resource_types, verbs or user to reduce the data scanned on cluster max-bytes")
+
+	var scanErr *provider.ScanLimitError
+	assert.ErrorAs(t, err, &scanErr)
+}
+
+func TestProvider_Capabilities(t *testing.T) {
+	tests := []struct {
+		name     string
+		inner    time.Duration
+		config   time.Duration
+		expected time.Duration
+	}{
+		{name: "no limits", expected: 0},
+		{name: "guardrail", config: 24 * time.Hour, expected: 24 * time.Hour},
+		{name: "provider", inner: time.Hour, expected: time.Hour},
+		{name: "guardrail is smaller", inner: 48 * time.Hour, config: 24 * time.Hour, expected: 24 * time.Hour},
+		{name: "provider is smaller", inner: time.Hour, config: 24 * time.Hour, expected: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &mockProvider{capabilities: provider.Capabilities{
+				Filters:      provider.AllFilters,
+				MaxTimeRange: metav1.Duration{Duration: tt.inner},
+			}}
+			p := NewProvider(next, &Config{MaxTimeRange: metav1.Duration{Duration: tt.config}}, t.Name())
+			capabilities := p.Capabilities()
+			assert.Equal(t, tt.expected, capabilities.MaxTimeRange.Duration)
+			assert.Equal(t, provider.AllFilters, capabilities.Filters)
+		})
+	}
+}
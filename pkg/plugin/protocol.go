@@ -0,0 +1,179 @@
+// Package plugin is the SDK of the plugins of the exec-plugin provider.
+//
+// A plugin is an executable that serves the audit logs of a backend over
+// JSON-RPC 2.0 on its stdin and stdout, one message per line. The provider
+// starts the plugin, calls Init with the config of the plugin, and then
+// calls Capabilities and QueryAuditLog. Requests may be sent concurrently.
+// While a query runs, the plugin may send progress and status
+// notifications of it.
+// The plugin should exit when its stdin is closed, and log to stderr.
+//
+// Plugins in Go implement Plugin and call Serve, plugins in other languages
+// implement the messages of this file.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+)
+
+// ProtocolVersion is the version of the protocol, it is sent in Init.
+const ProtocolVersion = 1
+
+// The methods of the protocol.
+const (
+	MethodInit          = "Init"
+	MethodCapabilities  = "Capabilities"
+	MethodQueryAuditLog = "QueryAuditLog"
+	// MethodCancel is a notification that cancels the request with the ID
+	// in CancelParams.
+	MethodCancel = "$/cancelRequest"
+	// MethodProgress is a notification of the plugin with the progress of
+	// the request with the ID in ProgressParams.
+	MethodProgress = "$/progress"
+	// MethodStatus is a notification of the plugin with a status message of
+	// the request with the ID in StatusParams, e.g. that it was throttled.
+	MethodStatus = "$/status"
+)
+
+// The error codes of the protocol, besides the codes of JSON-RPC 2.0.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	// CodeScanLimit is returned when the query exceeds MaxBytesScanned, the
+	// data of the error is a ScanLimitData.
+	CodeScanLimit = -32001
+	// CodeCanceled is returned when the request is canceled.
+	CodeCanceled = -32002
+)
+
+// Request is a JSON-RPC request, or a notification when ID is nil.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC response.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// InitParams are the params of Init.
+type InitParams struct {
+	ProtocolVersion int `json:"protocol_version"`
+	// Config is the config of the plugin in the provider config.
+	Config map[string]any `json:"config,omitempty"`
+}
+
+// InitResult is the result of Init.
+type InitResult struct {
+	ProtocolVersion int `json:"protocol_version"`
+}
+
+// CancelParams are the params of the cancel notification.
+type CancelParams struct {
+	ID int64 `json:"id"`
+}
+
+// ProgressParams are the params of the progress notification.
+type ProgressParams struct {
+	ID int64 `json:"id"`
+	provider.Progress
+}
+
+// StatusParams are the params of the status notification, the level is
+// provider.StatusInfo or provider.StatusWarning.
+type StatusParams struct {
+	ID      int64  `json:"id"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// QueryParams are the params of QueryAuditLog. They are the fields of
+// types.QueryAuditLogParams, with absolute times.
+type QueryParams struct {
+	ClusterName   string    `json:"cluster_name"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	User          string    `json:"user,omitempty"`
+	Namespace     string    `json:"namespace,omitempty"`
+	Verbs         []string  `json:"verbs,omitempty"`
+	ResourceTypes []string  `json:"resource_types,omitempty"`
+	ResourceName  string    `json:"resource_name,omitempty"`
+	AuditID       string    `json:"audit_id,omitempty"`
+	Limit         int       `json:"limit"`
+	// MaxBytesScanned asks the plugin to abort queries that scan more bytes,
+	// 0 means no limit.
+	MaxBytesScanned int64 `json:"max_bytes_scanned,omitempty"`
+}
+
+// NewQueryParams returns the QueryParams of params.
+func NewQueryParams(params types.QueryAuditLogParams) QueryParams {
+	return QueryParams{
+		ClusterName:   params.ClusterName,
+		StartTime:     params.StartTime.Time,
+		EndTime:       params.EndTime.Time,
+		User:          params.User,
+		Namespace:     params.Namespace,
+		Verbs:         params.Verbs,
+		ResourceTypes: params.ResourceTypes,
+		ResourceName:  params.ResourceName,
+		AuditID:       params.AuditID,
+		Limit:         params.Limit,
+	}
+}
+
+// AuditLogParams returns the types.QueryAuditLogParams of p.
+func (p QueryParams) AuditLogParams() types.QueryAuditLogParams {
+	return types.QueryAuditLogParams{
+		ClusterName:   p.ClusterName,
+		StartTime:     types.NewTimeParam(p.StartTime),
+		EndTime:       types.NewTimeParam(p.EndTime),
+		User:          p.User,
+		Namespace:     p.Namespace,
+		Verbs:         p.Verbs,
+		ResourceTypes: p.ResourceTypes,
+		ResourceName:  p.ResourceName,
+		AuditID:       p.AuditID,
+		Limit:         p.Limit,
+	}
+}
+
+// QueryResult is the result of QueryAuditLog. The entries are audit events,
+// both the audit.k8s.io/v1 JSON and the JSON of types.AuditLogEntry are
+// accepted.
+type QueryResult struct {
+	types.AuditLogResult
+	// ProviderQuery is the query that is sent to the backend, for logging.
+	ProviderQuery string `json:"provider_query,omitempty"`
+}
+
+// ScanLimitData is the data of the CodeScanLimit error.
+type ScanLimitData struct {
+	Limit     int64 `json:"limit"`
+	Scanned   int64 `json:"scanned"`
+	Estimated bool  `json:"estimated,omitempty"`
+}
@@ -0,0 +1,223 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+)
+
+// Plugin is the audit log backend of a plugin.
+type Plugin interface {
+	// Init initializes the plugin with the config of the plugin in the
+	// provider config. It is called once, before the other methods.
+	Init(ctx context.Context, config map[string]any) error
+	Capabilities() provider.Capabilities
+	QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error)
+}
+
+// Serve serves the plugin on stdin and stdout until stdin is closed.
+func Serve(p Plugin) error {
+	return ServeIO(context.Background(), p, os.Stdin, os.Stdout)
+}
+
+// ServeIO serves the plugin on r and w until r is closed or ctx is done.
+// The requests are handled concurrently, ServeIO returns after all of them
+// are answered.
+func ServeIO(ctx context.Context, p Plugin, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := &server{
+		plugin:  p,
+		encoder: json.NewEncoder(w),
+		cancels: map[int64]context.CancelFunc{},
+	}
+	defer s.wg.Wait()
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			s.handle(ctx, line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+type server struct {
+	plugin Plugin
+
+	encoder *json.Encoder
+	writeMu sync.Mutex
+
+	cancels  map[int64]context.CancelFunc
+	cancelMu sync.Mutex
+	wg       sync.WaitGroup
+}
+
+func (s *server) handle(ctx context.Context, line []byte) {
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		s.respond(nil, nil, &Error{Code: CodeParseError, Message: err.Error()})
+		return
+	}
+
+	if req.Method == MethodCancel {
+		var params CancelParams
+		if err := json.Unmarshal(req.Params, &params); err == nil {
+			s.cancelMu.Lock()
+			if cancel, ok := s.cancels[params.ID]; ok {
+				cancel()
+			}
+			s.cancelMu.Unlock()
+		}
+		return
+	}
+	// Other notifications are ignored.
+	if req.ID == nil {
+		return
+	}
+
+	id := *req.ID
+	ctx, cancel := context.WithCancel(ctx)
+	s.cancelMu.Lock()
+	s.cancels[id] = cancel
+	s.cancelMu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		result, err := s.call(ctx, req)
+
+		s.cancelMu.Lock()
+		delete(s.cancels, id)
+		s.cancelMu.Unlock()
+		cancel()
+
+		if err != nil {
+			s.respond(req.ID, nil, toError(ctx, err))
+			return
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			s.respond(req.ID, nil, &Error{Code: CodeInternalError, Message: fmt.Sprintf("marshal result: %v", err)})
+			return
+		}
+		s.respond(req.ID, data, nil)
+	}()
+}
+
+func (s *server) call(ctx context.Context, req Request) (any, error) {
+	switch req.Method {
+	case MethodInit:
+		var params InitParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if params.ProtocolVersion != ProtocolVersion {
+			return nil, &Error{
+				Code:    CodeInvalidParams,
+				Message: fmt.Sprintf("unsupported protocol version %d, expected %d", params.ProtocolVersion, ProtocolVersion),
+			}
+		}
+		if err := s.plugin.Init(ctx, params.Config); err != nil {
+			return nil, err
+		}
+		return InitResult{ProtocolVersion: ProtocolVersion}, nil
+	case MethodCapabilities:
+		return s.plugin.Capabilities(), nil
+	case MethodQueryAuditLog:
+		var params QueryParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if params.MaxBytesScanned > 0 {
+			ctx = provider.WithMaxBytesScanned(ctx, params.MaxBytesScanned)
+		}
+		ctx = provider.WithReporter(ctx, &reporter{s: s, id: *req.ID})
+		result, err := s.plugin.QueryAuditLog(ctx, params.AuditLogParams())
+		if err != nil {
+			return nil, err
+		}
+		return QueryResult{AuditLogResult: result, ProviderQuery: result.ProviderQuery}, nil
+	default:
+		return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method %s not found", req.Method)}
+	}
+}
+
+func (s *server) respond(id *int64, result json.RawMessage, rpcErr *Error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	// Errors of the writer are ignored, the reader gets them as EOF when
+	// the provider exits.
+	_ = s.encoder.Encode(Response{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr})
+}
+
+func (s *server) notify(method string, params any) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_ = s.encoder.Encode(Request{JSONRPC: "2.0", Method: method, Params: data})
+}
+
+// reporter sends the progress and the status messages of a query to the
+// provider as notifications.
+type reporter struct {
+	s  *server
+	id int64
+}
+
+func (r *reporter) Progress(progress provider.Progress) {
+	r.s.notify(MethodProgress, ProgressParams{ID: r.id, Progress: progress})
+}
+
+func (r *reporter) Status(level, message string) {
+	r.s.notify(MethodStatus, StatusParams{ID: r.id, Level: level, Message: message})
+}
+
+func unmarshalParams(data json.RawMessage, v any) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// toError converts the error of the plugin to a JSON-RPC error.
+func toError(ctx context.Context, err error) *Error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	var scanErr *provider.ScanLimitError
+	if errors.As(err, &scanErr) {
+		data, _ := json.Marshal(ScanLimitData{Limit: scanErr.Limit, Scanned: scanErr.Scanned, Estimated: scanErr.Estimated})
+		return &Error{Code: CodeScanLimit, Message: err.Error(), Data: data}
+	}
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return &Error{Code: CodeCanceled, Message: err.Error()}
+	}
+	return &Error{Code: CodeInternalError, Message: err.Error()}
+}
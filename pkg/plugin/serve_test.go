@@ -0,0 +1,103 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type echoPlugin struct {
+	config map[string]any
+}
+
+func (e *echoPlugin) Init(_ context.Context, config map[string]any) error {
+	e.config = config
+	return nil
+}
+
+func (e *echoPlugin) Capabilities() provider.Capabilities {
+	return provider.Capabilities{Filters: []string{provider.FilterNamespace}}
+}
+
+func (e *echoPlugin) QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	if maxBytes := provider.MaxBytesScanned(ctx); maxBytes > 0 {
+		return types.AuditLogResult{}, &provider.ScanLimitError{Limit: maxBytes, Scanned: maxBytes + 1}
+	}
+	provider.ReportProgress(ctx, provider.Progress{RecordsScanned: 10, RecordsMatched: 1})
+	provider.ReportStatus(ctx, provider.StatusWarning, "throttled")
+	return types.AuditLogResult{
+		Entries:       []types.AuditLogEntry{{AuditID: "1"}},
+		Total:         1,
+		Note:          params.Namespace,
+		ProviderQuery: "namespace=" + params.Namespace,
+	}, nil
+}
+
+func TestServeIO(t *testing.T) {
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"Init","params":{"protocol_version":1,"config":{"table":"audit"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"Capabilities"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"QueryAuditLog","params":{"namespace":"default","limit":10}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"QueryAuditLog","params":{"max_bytes_scanned":100}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"Init","params":{"protocol_version":2}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"Unknown"}`,
+		`{"jsonrpc":"2.0","method":"Ignored"}`,
+		`not json`,
+	}, "\n")
+
+	p := &echoPlugin{}
+	var output bytes.Buffer
+	err := ServeIO(context.Background(), p, strings.NewReader(input), &output)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"table": "audit"}, p.config)
+
+	responses := map[int64]Response{}
+	notifications := map[string]json.RawMessage{}
+	var parseError *Error
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var resp Response
+		if !assert.NoError(t, json.Unmarshal([]byte(line), &resp)) {
+			return
+		}
+		var notification Request
+		if !assert.NoError(t, json.Unmarshal([]byte(line), &notification)) {
+			return
+		}
+		if notification.Method != "" {
+			notifications[notification.Method] = notification.Params
+			continue
+		}
+		if resp.ID == nil {
+			parseError = resp.Error
+			continue
+		}
+		responses[*resp.ID] = resp
+	}
+	assert.Len(t, responses, 6)
+
+	assert.JSONEq(t, `{"protocol_version":1}`, string(responses[1].Result))
+	assert.JSONEq(t, `{"filters":["namespace"],"pagination":false}`, string(responses[2].Result))
+
+	var result QueryResult
+	assert.NoError(t, json.Unmarshal(responses[3].Result, &result))
+	assert.Equal(t, "default", result.Note)
+	assert.Equal(t, "namespace=default", result.ProviderQuery)
+	assert.Len(t, result.Entries, 1)
+
+	assert.Equal(t, CodeScanLimit, responses[4].Error.Code)
+	assert.JSONEq(t, `{"limit":100,"scanned":101}`, string(responses[4].Error.Data))
+	assert.Equal(t, CodeInvalidParams, responses[5].Error.Code)
+	assert.Equal(t, "unsupported protocol version 2, expected 1", responses[5].Error.Message)
+	assert.Equal(t, CodeMethodNotFound, responses[6].Error.Code)
+	assert.JSONEq(t, `{"id":3,"records_scanned":10,"records_matched":1}`, string(notifications[MethodProgress]))
+	assert.JSONEq(t, `{"id":3,"level":"warning","message":"throttled"}`, string(notifications[MethodStatus]))
+	if assert.NotNil(t, parseError) {
+		assert.Equal(t, CodeParseError, parseError.Code)
+	}
+}
@@ -0,0 +1,398 @@
+package privacy
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	authnv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const DefaultMappingFile = "~/.config/kube-audit-mcp/privacy-tokens.jsonl"
+
+// DefaultExclude keeps the Kubernetes system users readable, they do not
+// identify a person.
+var DefaultExclude = []string{"system:*"}
+
+type Config struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// KeyEnv or KeyFile provides the HMAC key used to pseudonymize values.
+	KeyEnv  string `yaml:"key_env,omitempty" json:"key_env,omitempty"`
+	KeyFile string `yaml:"key_file,omitempty" json:"key_file,omitempty"`
+
+	// MappingFile is the local file used by the `reveal` command to map
+	// tokens back to the original values.
+	MappingFile string `yaml:"mapping_file,omitempty" json:"mapping_file,omitempty"`
+
+	// Exclude lists user names that are kept as-is. Supports suffix wildcards.
+	Exclude []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+}
+
+type Pseudonymizer struct {
+	key     []byte
+	exclude []string
+	store   *Store
+}
+
+var tokenPattern = regexp.MustCompile(`\b(user|email|ip|uid|group|extra)-[0-9a-f]{16}\b`)
+
+func New(config *Config) (*Pseudonymizer, error) {
+	if config == nil || !config.Enabled {
+		return nil, nil
+	}
+
+	key, err := config.key()
+	if err != nil {
+		return nil, err
+	}
+	store, err := OpenStore(config.MappingFile)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude := config.Exclude
+	if exclude == nil {
+		exclude = DefaultExclude
+	}
+
+	return &Pseudonymizer{
+		key:     key,
+		exclude: exclude,
+		store:   store,
+	}, nil
+}
+
+func (c *Config) key() ([]byte, error) {
+	var key string
+	switch {
+	case c.KeyEnv != "":
+		key = os.Getenv(c.KeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("privacy key env %s is empty", c.KeyEnv)
+		}
+	case c.KeyFile != "":
+		data, err := os.ReadFile(c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read privacy key file: %w", err)
+		}
+		key = strings.TrimSpace(string(data))
+		if key == "" {
+			return nil, fmt.Errorf("privacy key file %s is empty", c.KeyFile)
+		}
+	default:
+		return nil, errors.New("either key_env or key_file is required when privacy is enabled")
+	}
+	return []byte(key), nil
+}
+
+// PseudonymizeResult replaces the identities of all entries with stable
+// tokens, see PseudonymizeEntry.
+func (p *Pseudonymizer) PseudonymizeResult(result *types.AuditLogResult) {
+	if p == nil {
+		return
+	}
+	for i := range result.Entries {
+		p.PseudonymizeEntry(&result.Entries[i])
+	}
+}
+
+// PseudonymizeEntry replaces the identities of the entry with stable tokens:
+// the user names, UIDs, groups and extra values of the user and the
+// impersonated user, the source IPs, and the users and groups of the
+// subjects of the request and response objects, e.g. of RoleBindings. The
+// identities are also replaced wherever they appear in the response message,
+// the annotations, e.g. authorization.k8s.io/reason, and the objects.
+func (p *Pseudonymizer) PseudonymizeEntry(entry *types.AuditLogEntry) {
+	if p == nil {
+		return
+	}
+
+	replacements := map[string]string{}
+	p.userInfo(&entry.User, replacements)
+	if entry.ImpersonatedUser != nil {
+		p.userInfo(entry.ImpersonatedUser, replacements)
+	}
+	for i, ip := range entry.SourceIPs {
+		entry.SourceIPs[i] = p.replace("ip", ip, replacements)
+	}
+
+	var objects []map[string]any
+	for _, obj := range []*runtime.Unknown{entry.RequestObject, entry.ResponseObject} {
+		var decoded map[string]any
+		if obj != nil && json.Unmarshal(obj.Raw, &decoded) == nil {
+			p.subjects(decoded, replacements)
+		}
+		objects = append(objects, decoded)
+	}
+
+	replacer := newReplacer(replacements)
+	if entry.ResponseStatus != nil {
+		entry.ResponseStatus.Message = replacer.Replace(entry.ResponseStatus.Message)
+	}
+	for key, value := range entry.Annotations {
+		entry.Annotations[key] = replacer.Replace(value)
+	}
+	for i, obj := range []*runtime.Unknown{entry.RequestObject, entry.ResponseObject} {
+		if obj == nil {
+			continue
+		}
+		if objects[i] == nil {
+			// Objects that are not JSON objects are replaced as text.
+			obj.Raw = []byte(newJSONReplacer(replacements).Replace(string(obj.Raw)))
+			continue
+		}
+		if data, err := json.Marshal(replaceStrings(objects[i], replacer)); err == nil {
+			obj.Raw = data
+		}
+	}
+}
+
+// userInfo pseudonymizes the user and records the replaced values.
+func (p *Pseudonymizer) userInfo(user *authnv1.UserInfo, replacements map[string]string) {
+	if user.Username == "" || p.excluded(user.Username) {
+		return
+	}
+	user.Username = p.replace(userKind(user.Username), user.Username, replacements)
+	user.UID = p.replace("uid", user.UID, replacements)
+	for i, group := range user.Groups {
+		if !p.excluded(group) {
+			user.Groups[i] = p.replace("group", group, replacements)
+		}
+	}
+	for key, values := range user.Extra {
+		pseudonymized := make(authnv1.ExtraValue, len(values))
+		for i, value := range values {
+			pseudonymized[i] = p.replace("extra", value, replacements)
+		}
+		user.Extra[key] = pseudonymized
+	}
+}
+
+// subjects pseudonymizes the names of the User and Group subjects of the
+// object, recursively, e.g. the subjects of a RoleBinding.
+func (p *Pseudonymizer) subjects(v any, replacements map[string]string) {
+	switch v := v.(type) {
+	case map[string]any:
+		name, _ := v["name"].(string)
+		switch v["kind"] {
+		case "User":
+			if !p.excluded(name) {
+				v["name"] = p.replace(userKind(name), name, replacements)
+			}
+		case "Group":
+			if !p.excluded(name) {
+				v["name"] = p.replace("group", name, replacements)
+			}
+		}
+		for _, value := range v {
+			p.subjects(value, replacements)
+		}
+	case []any:
+		for _, value := range v {
+			p.subjects(value, replacements)
+		}
+	}
+}
+
+// replace returns the token of the value, and records the replacement.
+func (p *Pseudonymizer) replace(kind, value string, replacements map[string]string) string {
+	if value == "" {
+		return value
+	}
+	if tokenPattern.FindString(value) == value {
+		// Already pseudonymized, e.g. a subject that is also the user.
+		return value
+	}
+	token := p.token(kind, value)
+	replacements[value] = token
+	return token
+}
+
+// minReplacedLength is the minimal length of the identities that are
+// replaced in text, shorter values are too likely to be unrelated words.
+const minReplacedLength = 3
+
+// identityReplacer replaces the identities in text where they are whole
+// words, so that an identity is not replaced in the unrelated words that
+// contain it, e.g. the group "dev" in "development", "dev-tools" or
+// "registry.example.com/dev/app".
+type identityReplacer struct {
+	// values are the identities, the longest first, so that an identity is
+	// not partially replaced by a shorter one it contains.
+	values []string
+	tokens map[string]string
+}
+
+// newReplacer returns the replacer of the identities in text.
+func newReplacer(replacements map[string]string) *identityReplacer {
+	return newIdentityReplacer(replacements, func(s string) string { return s })
+}
+
+// newJSONReplacer replaces the identities in JSON, in their escaped form.
+func newJSONReplacer(replacements map[string]string) *identityReplacer {
+	return newIdentityReplacer(replacements, func(s string) string {
+		data, _ := json.Marshal(s)
+		return string(data[1 : len(data)-1])
+	})
+}
+
+func newIdentityReplacer(replacements map[string]string, escape func(string) string) *identityReplacer {
+	r := &identityReplacer{tokens: map[string]string{}}
+	for value, token := range replacements {
+		if len(value) >= minReplacedLength {
+			r.values = append(r.values, escape(value))
+			r.tokens[escape(value)] = token
+		}
+	}
+	slices.SortFunc(r.values, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), cmp.Compare(a, b))
+	})
+	return r
+}
+
+// Replace returns s with the identities that are whole words replaced by
+// their tokens.
+func (r *identityReplacer) Replace(s string) string {
+	if !slices.ContainsFunc(r.values, func(value string) bool { return strings.Contains(s, value) }) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		value := ""
+		for _, v := range r.values {
+			if strings.HasPrefix(s[i:], v) && !joined(s, i-1, -1) && !joined(s, i+len(v), 1) {
+				value = v
+				break
+			}
+		}
+		if value == "" {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		b.WriteString(r.tokens[value])
+		i += len(value)
+	}
+	return b.String()
+}
+
+// joined reports whether the byte s[i] next to an identity joins it to a
+// longer word, dir is the direction away from the identity. Letters, digits
+// and underscores are part of words. Separators such as "-", ".", "/" and
+// ":" are part of words when a letter or digit follows them, e.g. in
+// "dev-tools", but not at the end of a sentence, e.g. "created by dev.".
+func joined(s string, i, dir int) bool {
+	if i < 0 || i >= len(s) {
+		return false
+	}
+	if isWordByte(s[i]) {
+		return true
+	}
+	if strings.IndexByte("-./:@", s[i]) < 0 {
+		return false
+	}
+	next := i + dir
+	return next >= 0 && next < len(s) && isWordByte(s[next])
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= utf8.RuneSelf ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// replaceStrings replaces the identities in the strings of the decoded JSON
+// value.
+func replaceStrings(v any, replacer *identityReplacer) any {
+	switch v := v.(type) {
+	case string:
+		return replacer.Replace(v)
+	case map[string]any:
+		for key, value := range v {
+			v[key] = replaceStrings(value, replacer)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = replaceStrings(value, replacer)
+		}
+	}
+	return v
+}
+
+// Reveal maps a token back to the original value. Values that are not
+// known tokens are returned unchanged.
+func (p *Pseudonymizer) Reveal(value string) string {
+	if p == nil {
+		return value
+	}
+	if original, ok := p.store.Lookup(value); ok {
+		return original
+	}
+	return value
+}
+
+func userKind(name string) string {
+	if isEmail(name) {
+		return "email"
+	}
+	return "user"
+}
+
+func (p *Pseudonymizer) excluded(name string) bool {
+	for _, pattern := range p.exclude {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pseudonymizer) token(kind, value string) string {
+	if value == "" {
+		return value
+	}
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(value))
+	token := fmt.Sprintf("%s-%s", kind, hex.EncodeToString(mac.Sum(nil))[:16])
+
+	if err := p.store.Add(token, value); err != nil {
+		log.Printf("failed to record privacy token: %v", err)
+	}
+	return token
+}
+
+// RevealText replaces all known tokens in the text with the original values.
+func RevealText(store *Store, text string) string {
+	return tokenPattern.ReplaceAllStringFunc(text, func(token string) string {
+		if value, ok := store.Lookup(token); ok {
+			return value
+		}
+		return token
+	})
+}
+
+func isEmail(s string) bool {
+	if !strings.Contains(s, "@") {
+		return false
+	}
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
@@ -0,0 +1,275 @@
+package privacy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	k8sauth "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type mockProvider struct {
+	params types.QueryAuditLogParams
+	result types.AuditLogResult
+}
+
+func (m *mockProvider) QueryAuditLog(_ context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	m.params = params
+	return m.result, nil
+}
+
+func (m *mockProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{}
+}
+
+func newTestPseudonymizer(t *testing.T) (*Pseudonymizer, string) {
+	t.Setenv("TEST_PRIVACY_KEY", "test-key")
+	mappingFile := filepath.Join(t.TempDir(), "tokens.jsonl")
+	p, err := New(&Config{
+		Enabled:     true,
+		KeyEnv:      "TEST_PRIVACY_KEY",
+		MappingFile: mappingFile,
+	})
+	assert.NoError(t, err)
+	return p, mappingFile
+}
+
+func TestNew(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("file-key\n"), 0600))
+	t.Setenv("TEST_EMPTY_PRIVACY_KEY", "")
+
+	tests := []struct {
+		name        string
+		config      *Config
+		expectNil   bool
+		expectedErr string
+	}{
+		{
+			name:      "nil config",
+			config:    nil,
+			expectNil: true,
+		},
+		{
+			name:      "disabled",
+			config:    &Config{Enabled: false, KeyEnv: "TEST_EMPTY_PRIVACY_KEY"},
+			expectNil: true,
+		},
+		{
+			name:        "missing key",
+			config:      &Config{Enabled: true},
+			expectedErr: "either key_env or key_file is required",
+		},
+		{
+			name:        "empty key env",
+			config:      &Config{Enabled: true, KeyEnv: "TEST_EMPTY_PRIVACY_KEY"},
+			expectedErr: "privacy key env TEST_EMPTY_PRIVACY_KEY is empty",
+		},
+		{
+			name:        "missing key file",
+			config:      &Config{Enabled: true, KeyFile: filepath.Join(t.TempDir(), "missing")},
+			expectedErr: "read privacy key file",
+		},
+		{
+			name:   "key file",
+			config: &Config{Enabled: true, KeyFile: keyFile},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.config)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.expectNil {
+				assert.Nil(t, p)
+			} else {
+				assert.NotNil(t, p)
+			}
+		})
+	}
+}
+
+func TestPseudonymizer_PseudonymizeEntry(t *testing.T) {
+	p, _ := newTestPseudonymizer(t)
+
+	entry := types.AuditLogEntry{
+		User:             k8sauth.UserInfo{Username: "alice@example.com"},
+		ImpersonatedUser: &k8sauth.UserInfo{Username: "kubernetes-admin"},
+		SourceIPs:        []string{"10.0.0.1", "192.168.0.1"},
+	}
+	p.PseudonymizeEntry(&entry)
+
+	assert.Regexp(t, regexp.MustCompile(`^email-[0-9a-f]{16}$`), entry.User.Username)
+	assert.Regexp(t, regexp.MustCompile(`^user-[0-9a-f]{16}$`), entry.ImpersonatedUser.Username)
+	assert.Regexp(t, regexp.MustCompile(`^ip-[0-9a-f]{16}$`), entry.SourceIPs[0])
+	assert.NotEqual(t, entry.SourceIPs[0], entry.SourceIPs[1])
+
+	// The same value always maps to the same token
+	again := types.AuditLogEntry{User: k8sauth.UserInfo{Username: "alice@example.com"}}
+	p.PseudonymizeEntry(&again)
+	assert.Equal(t, entry.User.Username, again.User.Username)
+
+	assert.Equal(t, "alice@example.com", p.Reveal(entry.User.Username))
+	assert.Equal(t, "10.0.0.1", p.Reveal(entry.SourceIPs[0]))
+	assert.Equal(t, "unknown", p.Reveal("unknown"))
+}
+
+// pseudonymizeUser returns the token of the user name in an entry
+// pseudonymized by p.
+func pseudonymizeUser(p *Pseudonymizer, name string) string {
+	entry := types.AuditLogEntry{User: k8sauth.UserInfo{Username: name}}
+	p.PseudonymizeEntry(&entry)
+	return entry.User.Username
+}
+
+func TestPseudonymizer_PseudonymizeEntry_Identities(t *testing.T) {
+	p, _ := newTestPseudonymizer(t)
+
+	entry := types.AuditLogEntry{
+		User: k8sauth.UserInfo{
+			Username: "alice@corp.example",
+			UID:      "5f1c-alice-uid",
+			Groups:   []string{"platform-team", "system:authenticated"},
+			Extra:    map[string]k8sauth.ExtraValue{"iam.example.com/arn": {"arn:aws:iam::123:user/alice"}},
+		},
+		ResponseStatus: &metav1.Status{
+			Code:    403,
+			Message: `rolebindings.rbac.authorization.k8s.io "ops" is forbidden: User "alice@corp.example" cannot create resource "rolebindings"`,
+		},
+		Annotations: map[string]string{
+			"authorization.k8s.io/decision": "allow",
+			"authorization.k8s.io/reason":   `RBAC: allowed by ClusterRoleBinding "admins" of ClusterRole "admin" to Group "platform-team"`,
+		},
+		RequestObject: &runtime.Unknown{Raw: []byte(`{"kind":"RoleBinding","metadata":{"name":"ops","annotations":{"owner":"alice@corp.example"}},` +
+			`"subjects":[{"kind":"User","name":"bob"},{"kind":"Group","name":"oncall"},{"kind":"ServiceAccount","name":"default","namespace":"ops"}]}`)},
+		ResponseObject: &runtime.Unknown{Raw: []byte(`not json, created by alice@corp.example`)},
+	}
+	p.PseudonymizeEntry(&entry)
+
+	alice := pseudonymizeUser(p, "alice@corp.example")
+	bob := pseudonymizeUser(p, "bob")
+	platform := p.token("group", "platform-team")
+	oncall := p.token("group", "oncall")
+
+	assert.Regexp(t, regexp.MustCompile(`^email-[0-9a-f]{16}$`), alice)
+	assert.Equal(t, alice, entry.User.Username)
+	assert.Regexp(t, regexp.MustCompile(`^uid-[0-9a-f]{16}$`), entry.User.UID)
+	assert.Equal(t, []string{platform, "system:authenticated"}, entry.User.Groups)
+	assert.Regexp(t, regexp.MustCompile(`^extra-[0-9a-f]{16}$`), entry.User.Extra["iam.example.com/arn"][0])
+
+	assert.Equal(t, `rolebindings.rbac.authorization.k8s.io "ops" is forbidden: User "`+alice+`" cannot create resource "rolebindings"`,
+		entry.ResponseStatus.Message)
+	assert.Equal(t, `RBAC: allowed by ClusterRoleBinding "admins" of ClusterRole "admin" to Group "`+platform+`"`,
+		entry.Annotations["authorization.k8s.io/reason"])
+	assert.Equal(t, "allow", entry.Annotations["authorization.k8s.io/decision"])
+
+	assert.JSONEq(t, `{"kind":"RoleBinding","metadata":{"name":"ops","annotations":{"owner":"`+alice+`"}},`+
+		`"subjects":[{"kind":"User","name":"`+bob+`"},{"kind":"Group","name":"`+oncall+`"},{"kind":"ServiceAccount","name":"default","namespace":"ops"}]}`,
+		string(entry.RequestObject.Raw))
+	assert.Equal(t, "not json, created by "+alice, string(entry.ResponseObject.Raw))
+
+	assert.Equal(t, "bob", p.Reveal(bob))
+	assert.Equal(t, `RBAC: allowed by ClusterRoleBinding "admins" of ClusterRole "admin" to Group "platform-team"`,
+		RevealText(p.store, entry.Annotations["authorization.k8s.io/reason"]))
+}
+
+func TestPseudonymizer_PseudonymizeEntry_WholeWords(t *testing.T) {
+	p, _ := newTestPseudonymizer(t)
+
+	entry := types.AuditLogEntry{
+		User: k8sauth.UserInfo{
+			Username: "ops",
+			Groups:   []string{"dev"},
+		},
+		ResponseStatus: &metav1.Status{
+			Code:    403,
+			Message: `deployments.apps "development" is forbidden: User "ops" cannot patch resource "deployments" in the namespace "dev-tools"`,
+		},
+		Annotations: map[string]string{
+			"authorization.k8s.io/reason": `RBAC: allowed to Group "dev", see https://wiki.example.com/dev/ops-runbook`,
+		},
+		RequestObject: &runtime.Unknown{Raw: []byte(`{"kind":"Deployment","metadata":{"name":"development","namespace":"dev-tools",` +
+			`"labels":{"team":"dev","app":"devops"}},"spec":{"template":{"spec":{"containers":[{"image":"registry.example.com/ops/app:dev"}]}}}}`)},
+		ResponseObject: &runtime.Unknown{Raw: []byte(`not json, created by ops.`)},
+	}
+	p.PseudonymizeEntry(&entry)
+
+	ops := p.token("user", "ops")
+	dev := p.token("group", "dev")
+	assert.Equal(t, ops, entry.User.Username)
+	assert.Equal(t, []string{dev}, entry.User.Groups)
+
+	assert.Equal(t, `deployments.apps "development" is forbidden: User "`+ops+`" cannot patch resource "deployments" in the namespace "dev-tools"`,
+		entry.ResponseStatus.Message)
+	assert.Equal(t, `RBAC: allowed to Group "`+dev+`", see https://wiki.example.com/dev/ops-runbook`,
+		entry.Annotations["authorization.k8s.io/reason"])
+	assert.JSONEq(t, `{"kind":"Deployment","metadata":{"name":"development","namespace":"dev-tools",`+
+		`"labels":{"team":"`+dev+`","app":"devops"}},"spec":{"template":{"spec":{"containers":[{"image":"registry.example.com/ops/app:dev"}]}}}}`,
+		string(entry.RequestObject.Raw))
+	assert.Equal(t, "not json, created by "+ops+".", string(entry.ResponseObject.Raw))
+}
+
+func TestPseudonymizer_Exclude(t *testing.T) {
+	p, _ := newTestPseudonymizer(t)
+
+	entry := types.AuditLogEntry{
+		User: k8sauth.UserInfo{Username: "system:serviceaccount:kube-system:default"},
+	}
+	p.PseudonymizeEntry(&entry)
+	assert.Equal(t, "system:serviceaccount:kube-system:default", entry.User.Username)
+
+	p.exclude = []string{"ci-bot"}
+	entry = types.AuditLogEntry{User: k8sauth.UserInfo{Username: "ci-bot"}}
+	p.PseudonymizeEntry(&entry)
+	assert.Equal(t, "ci-bot", entry.User.Username)
+}
+
+func TestStore_Persistence(t *testing.T) {
+	p, mappingFile := newTestPseudonymizer(t)
+
+	entry := types.AuditLogEntry{User: k8sauth.UserInfo{Username: "bob"}}
+	p.PseudonymizeEntry(&entry)
+
+	store, err := OpenStore(mappingFile)
+	assert.NoError(t, err)
+	value, ok := store.Lookup(entry.User.Username)
+	assert.True(t, ok)
+	assert.Equal(t, "bob", value)
+
+	text := "deleted by " + entry.User.Username + " and user-0000000000000000"
+	assert.Equal(t, "deleted by bob and user-0000000000000000", RevealText(store, text))
+
+	info, err := os.Stat(mappingFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestProvider_QueryAuditLog(t *testing.T) {
+	p, _ := newTestPseudonymizer(t)
+	token := pseudonymizeUser(p, "carol@example.com")
+
+	next := &mockProvider{
+		result: types.AuditLogResult{
+			Entries: []types.AuditLogEntry{
+				{User: k8sauth.UserInfo{Username: "carol@example.com"}},
+			},
+		},
+	}
+	provider := NewProvider(next, p)
+
+	result, err := provider.QueryAuditLog(context.Background(), types.QueryAuditLogParams{User: token})
+	assert.NoError(t, err)
+	assert.Equal(t, "carol@example.com", next.params.User)
+	assert.Equal(t, token, result.Entries[0].User.Username)
+}
@@ -0,0 +1,46 @@
+package privacy
+
+import (
+	"context"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+)
+
+// Provider pseudonymizes the results of the wrapped provider. Tokens passed
+// back by the agent as the user filter are mapped to the original values
+// before querying.
+type Provider struct {
+	next          provider.Provider
+	pseudonymizer *Pseudonymizer
+}
+
+var _ provider.Provider = (*Provider)(nil)
+
+func NewProvider(next provider.Provider, pseudonymizer *Pseudonymizer) *Provider {
+	return &Provider{
+		next:          next,
+		pseudonymizer: pseudonymizer,
+	}
+}
+
+// Capabilities returns the capabilities of the wrapped provider.
+func (p *Provider) Capabilities() provider.Capabilities {
+	return p.next.Capabilities()
+}
+
+// Close closes the wrapped provider.
+func (p *Provider) Close() error {
+	return provider.Close(p.next)
+}
+
+func (p *Provider) QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	params.User = p.pseudonymizer.Reveal(params.User)
+
+	result, err := p.next.QueryAuditLog(ctx, params)
+	if err != nil {
+		return result, err
+	}
+	p.pseudonymizer.PseudonymizeResult(&result)
+	return result, nil
+}
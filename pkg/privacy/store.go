@@ -0,0 +1,97 @@
+package privacy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store keeps the mapping from tokens back to the original values. It is
+// persisted as JSON lines to a local file, so that the `reveal` command can
+// map tokens back after the MCP server has exited.
+type Store struct {
+	path   string
+	tokens map[string]string
+
+	mu sync.RWMutex
+}
+
+type storeRecord struct {
+	Token string `json:"token"`
+	Value string `json:"value"`
+}
+
+func OpenStore(path string) (*Store, error) {
+	s := &Store{
+		path:   path,
+		tokens: make(map[string]string),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open mapping file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record storeRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Token == "" {
+			continue
+		}
+		s.tokens[record.Token] = record.Value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read mapping file %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+func (s *Store) Lookup(token string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.tokens[token]
+	return value, ok
+}
+
+// Add records the mapping and appends it to the mapping file if it is new.
+func (s *Store) Add(token, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[token]; ok {
+		return nil
+	}
+	s.tokens[token] = value
+	if s.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("create directory for mapping file: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open mapping file %s: %w", s.path, err)
+	}
+	defer f.Close()
+
+	line, _ := json.Marshal(storeRecord{Token: token, Value: value})
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write mapping file %s: %w", s.path, err)
+	}
+	return nil
+}
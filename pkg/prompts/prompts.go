@@ -0,0 +1,186 @@
+// Package prompts implements the catalog of the MCP prompts, the playbooks
+// of common investigations that expand into step-by-step instructions for
+// the agent.
+package prompts
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"sigs.k8s.io/yaml"
+)
+
+type Config struct {
+	// DisableBuiltinPrompts keeps only the prompts of Files.
+	DisableBuiltinPrompts bool `yaml:"disable_builtin_prompts,omitempty" json:"disable_builtin_prompts,omitempty"`
+	// Files are YAML files of prompts, in the format of the built-in
+	// catalog. A prompt replaces the prompt of the same name in the built-in
+	// catalog or a previous file. Relative paths are relative to the config
+	// file.
+	Files []string `yaml:"files,omitempty" json:"files,omitempty"`
+}
+
+// Catalog is the content of a prompt file.
+type Catalog struct {
+	Prompts []Prompt `yaml:"prompts" json:"prompts"`
+}
+
+// Prompt is a playbook. The template is a Go text/template that is rendered
+// with the arguments, e.g. {{ .namespace }}, and the default function, e.g.
+// {{ default "24h" .since }}.
+type Prompt struct {
+	Name        string     `yaml:"name" json:"name"`
+	Description string     `yaml:"description,omitempty" json:"description,omitempty"`
+	Arguments   []Argument `yaml:"arguments,omitempty" json:"arguments,omitempty"`
+	Template    string     `yaml:"template" json:"template"`
+
+	tmpl *template.Template
+}
+
+type Argument struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Required    bool   `yaml:"required,omitempty" json:"required,omitempty"`
+}
+
+//go:embed builtin.yaml
+var builtinCatalog []byte
+
+var funcs = template.FuncMap{
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// New returns the prompts of the config, in the order of the built-in
+// catalog and the files.
+func New(config *Config) ([]*Prompt, error) {
+	var prompts []*Prompt
+	add := func(catalog []*Prompt) {
+		for _, p := range catalog {
+			replaced := false
+			for i, old := range prompts {
+				if old.Name == p.Name {
+					prompts[i] = p
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				prompts = append(prompts, p)
+			}
+		}
+	}
+
+	if config == nil || !config.DisableBuiltinPrompts {
+		builtin, err := Parse(builtinCatalog)
+		if err != nil {
+			return nil, fmt.Errorf("parse built-in prompts: %w", err)
+		}
+		add(builtin)
+	}
+	if config != nil {
+		for _, file := range config.Files {
+			catalog, err := LoadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			add(catalog)
+		}
+	}
+	return prompts, nil
+}
+
+// LoadFile loads the prompts of a YAML file.
+func LoadFile(path string) ([]*Prompt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read prompt file %s: %w", path, err)
+	}
+	prompts, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt file %s: %w", path, err)
+	}
+	return prompts, nil
+}
+
+// Parse parses and validates the prompts of a YAML catalog.
+func Parse(data []byte) ([]*Prompt, error) {
+	var catalog Catalog
+	if err := yaml.UnmarshalStrict(data, &catalog); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	names := map[string]bool{}
+	prompts := make([]*Prompt, 0, len(catalog.Prompts))
+	for i := range catalog.Prompts {
+		p := &catalog.Prompts[i]
+		if err := p.init(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if names[p.Name] {
+			errs = append(errs, fmt.Errorf("duplicate prompt %s", p.Name))
+			continue
+		}
+		names[p.Name] = true
+		prompts = append(prompts, p)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return prompts, nil
+}
+
+func (p *Prompt) init() error {
+	if p.Name == "" {
+		return errors.New("prompt name is required")
+	}
+	if strings.TrimSpace(p.Template) == "" {
+		return fmt.Errorf("template of prompt %s is required", p.Name)
+	}
+	args := map[string]bool{}
+	for _, arg := range p.Arguments {
+		if arg.Name == "" {
+			return fmt.Errorf("argument name of prompt %s is required", p.Name)
+		}
+		if args[arg.Name] {
+			return fmt.Errorf("duplicate argument %s of prompt %s", arg.Name, p.Name)
+		}
+		args[arg.Name] = true
+	}
+
+	tmpl, err := template.New(p.Name).Funcs(funcs).Option("missingkey=zero").Parse(p.Template)
+	if err != nil {
+		return fmt.Errorf("parse template of prompt %s: %w", p.Name, err)
+	}
+	p.tmpl = tmpl
+	return nil
+}
+
+// Render renders the template with the arguments, the missing optional
+// arguments are empty strings.
+func (p *Prompt) Render(args map[string]string) (string, error) {
+	data := make(map[string]string, len(p.Arguments))
+	for _, arg := range p.Arguments {
+		value := strings.TrimSpace(args[arg.Name])
+		if value == "" && arg.Required {
+			return "", fmt.Errorf("argument %s of prompt %s is required", arg.Name, p.Name)
+		}
+		data[arg.Name] = value
+	}
+
+	var out strings.Builder
+	if err := p.tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("render prompt %s: %w", p.Name, err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
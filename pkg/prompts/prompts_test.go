@@ -0,0 +1,129 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_Builtin(t *testing.T) {
+	prompts, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range prompts {
+		names = append(names, p.Name)
+	}
+	assert.Equal(t, []string{
+		"who_changed_workload",
+		"investigate_leaked_service_account_token",
+		"review_rbac_changes",
+		"explain_pod_deletion",
+	}, names)
+
+	text, err := prompts[0].Render(map[string]string{
+		"namespace":     "default",
+		"resource_type": "deployments",
+		"name":          "nginx",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, text, "Find out who changed the deployments default/nginx in the last 7d")
+	assert.Contains(t, text, `resource_name "nginx"`)
+	assert.NotContains(t, text, "cluster_name")
+
+	text, err = prompts[2].Render(map[string]string{"cluster": "prod", "since": "48h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, text, "Review the RBAC changes in cluster prod in the last 48h.")
+	assert.Contains(t, text, `start_time "48h"`)
+	assert.Contains(t, text, `cluster_name "prod"`)
+
+	_, err = prompts[3].Render(map[string]string{"namespace": "default"})
+	assert.EqualError(t, err, "argument pod of prompt explain_pod_deletion is required")
+}
+
+func TestNew_Files(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "prompts.yaml")
+	err := os.WriteFile(file, []byte(`
+prompts:
+  - name: review_rbac_changes
+    description: Review the RBAC changes of our team.
+    template: Review the RBAC changes in namespace team-a.
+  - name: recent_exec
+    arguments:
+      - name: namespace
+        required: true
+    template: Find the pods/exec events in {{ .namespace }}{{ .unknown }}.
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prompts, err := New(&Config{Files: []string{file}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, prompts, 5)
+	assert.Equal(t, "Review the RBAC changes of our team.", prompts[2].Description)
+	text, err := prompts[4].Render(map[string]string{"namespace": "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Find the pods/exec events in default.", text)
+
+	prompts, err = New(&Config{DisableBuiltinPrompts: true, Files: []string{file}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, prompts, 2)
+
+	_, err = New(&Config{Files: []string{filepath.Join(dir, "missing.yaml")}})
+	assert.ErrorContains(t, err, "read prompt file")
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "unknown field",
+			data: "prompts:\n  - name: a\n    template: a\n    args: []\n",
+			err:  `unknown field "args"`,
+		},
+		{
+			name: "missing template",
+			data: "prompts:\n  - name: a\n",
+			err:  "template of prompt a is required",
+		},
+		{
+			name: "duplicate prompt",
+			data: "prompts:\n  - name: a\n    template: a\n  - name: a\n    template: b\n",
+			err:  "duplicate prompt a",
+		},
+		{
+			name: "duplicate argument",
+			data: "prompts:\n  - name: a\n    template: a\n    arguments:\n      - name: x\n      - name: x\n",
+			err:  "duplicate argument x of prompt a",
+		},
+		{
+			name: "invalid template",
+			data: "prompts:\n  - name: a\n    template: '{{ .x'\n",
+			err:  "parse template of prompt a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package alibaba
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aliyun/credentials-go/credentials"
+	"github.com/aliyun/credentials-go/credentials/providers"
+	"github.com/mozillazg/kube-audit-mcp/pkg/utils"
+)
+
+const (
+	CredentialTypeAccessKey   = "access_key"
+	CredentialTypeSTS         = "sts"
+	CredentialTypeRAMRoleARN  = "ram_role_arn"
+	CredentialTypeOIDCRoleARN = "oidc_role_arn"
+	CredentialTypeProfile     = "profile"
+)
+
+const defaultRoleSessionName = "kube-audit-mcp"
+
+// SLSCredentialsConfig configures the credentials of a cluster. The default
+// credential chain is used when it is not set. Secrets are read from env vars
+// or files.
+type SLSCredentialsConfig struct {
+	// Type is one of access_key, sts, ram_role_arn, oidc_role_arn and profile.
+	Type string `yaml:"type" json:"type"`
+
+	// AccessKeyId, AccessKeySecret and SecurityToken are used by the
+	// access_key and sts types, and as the source credentials of the
+	// ram_role_arn type.
+	AccessKeyIdEnv      string `yaml:"access_key_id_env,omitempty" json:"access_key_id_env,omitempty"`
+	AccessKeyIdFile     string `yaml:"access_key_id_file,omitempty" json:"access_key_id_file,omitempty"`
+	AccessKeySecretEnv  string `yaml:"access_key_secret_env,omitempty" json:"access_key_secret_env,omitempty"`
+	AccessKeySecretFile string `yaml:"access_key_secret_file,omitempty" json:"access_key_secret_file,omitempty"`
+	SecurityTokenEnv    string `yaml:"security_token_env,omitempty" json:"security_token_env,omitempty"`
+	SecurityTokenFile   string `yaml:"security_token_file,omitempty" json:"security_token_file,omitempty"`
+
+	// RoleArn is the RAM role assumed by the ram_role_arn and oidc_role_arn
+	// types.
+	RoleArn         string `yaml:"role_arn,omitempty" json:"role_arn,omitempty"`
+	RoleSessionName string `yaml:"role_session_name,omitempty" json:"role_session_name,omitempty"`
+	ExternalId      string `yaml:"external_id,omitempty" json:"external_id,omitempty"`
+
+	OIDCProviderArn string `yaml:"oidc_provider_arn,omitempty" json:"oidc_provider_arn,omitempty"`
+	OIDCTokenFile   string `yaml:"oidc_token_file,omitempty" json:"oidc_token_file,omitempty"`
+
+	// Profile is the name of a profile in the Alibaba Cloud CLI config file,
+	// ProfileFile defaults to ~/.aliyun/config.json.
+	Profile     string `yaml:"profile,omitempty" json:"profile,omitempty"`
+	ProfileFile string `yaml:"profile_file,omitempty" json:"profile_file,omitempty"`
+}
+
+func (c *SLSCredentialsConfig) Init() error {
+	if c.RoleSessionName == "" {
+		c.RoleSessionName = defaultRoleSessionName
+	}
+
+	hasAccessKey := c.AccessKeyIdEnv != "" || c.AccessKeyIdFile != ""
+	switch c.Type {
+	case CredentialTypeAccessKey, CredentialTypeSTS:
+		if !hasAccessKey {
+			return fmt.Errorf("access_key_id_env or access_key_id_file is required for %s credentials", c.Type)
+		}
+		if c.AccessKeySecretEnv == "" && c.AccessKeySecretFile == "" {
+			return fmt.Errorf("access_key_secret_env or access_key_secret_file is required for %s credentials", c.Type)
+		}
+		if c.Type == CredentialTypeSTS && c.SecurityTokenEnv == "" && c.SecurityTokenFile == "" {
+			return errors.New("security_token_env or security_token_file is required for sts credentials")
+		}
+	case CredentialTypeRAMRoleARN:
+		if c.RoleArn == "" {
+			return errors.New("role_arn is required for ram_role_arn credentials")
+		}
+	case CredentialTypeOIDCRoleARN:
+		if c.RoleArn == "" || c.OIDCProviderArn == "" || c.OIDCTokenFile == "" {
+			return errors.New("role_arn, oidc_provider_arn and oidc_token_file are required for oidc_role_arn credentials")
+		}
+	case CredentialTypeProfile:
+		if c.Profile == "" {
+			return errors.New("profile is required for profile credentials")
+		}
+	case "":
+		return errors.New("credentials type is required")
+	default:
+		return fmt.Errorf("unknown credentials type %q, must be one of %s, %s, %s, %s and %s",
+			c.Type, CredentialTypeAccessKey, CredentialTypeSTS, CredentialTypeRAMRoleARN,
+			CredentialTypeOIDCRoleARN, CredentialTypeProfile)
+	}
+	return nil
+}
+
+// NewCredential creates the credential of the config, the default credential
+// chain is used when config is nil. The roles are assumed with httpClient
+// when it is not nil, so that the requests to STS use the proxy and the CA
+// certificates of the transport config.
+func (c *SLSCredentialsConfig) NewCredential(httpClient *http.Client) (credentials.Credential, error) {
+	if c == nil {
+		return credentials.NewCredential(nil)
+	}
+
+	var p providers.CredentialsProvider
+	var err error
+	switch c.Type {
+	case CredentialTypeAccessKey, CredentialTypeSTS:
+		p, err = c.staticProvider()
+	case CredentialTypeRAMRoleARN:
+		var source providers.CredentialsProvider = providers.NewDefaultCredentialsProvider()
+		if c.AccessKeyIdEnv != "" || c.AccessKeyIdFile != "" {
+			if source, err = c.staticProvider(); err != nil {
+				return nil, err
+			}
+		}
+		if httpClient != nil {
+			p = c.roleProvider(httpClient, source)
+			break
+		}
+		p, err = providers.NewRAMRoleARNCredentialsProviderBuilder().
+			WithCredentialsProvider(source).
+			WithRoleArn(c.RoleArn).
+			WithRoleSessionName(c.RoleSessionName).
+			WithExternalId(c.ExternalId).
+			Build()
+	case CredentialTypeOIDCRoleARN:
+		if httpClient != nil {
+			p = c.roleProvider(httpClient, nil)
+			break
+		}
+		p, err = providers.NewOIDCCredentialsProviderBuilder().
+			WithRoleArn(c.RoleArn).
+			WithOIDCProviderARN(c.OIDCProviderArn).
+			WithOIDCTokenFilePath(c.OIDCTokenFile).
+			WithRoleSessionName(c.RoleSessionName).
+			Build()
+	case CredentialTypeProfile:
+		p, err = providers.NewCLIProfileCredentialsProviderBuilder().
+			WithProfileName(c.Profile).
+			WithProfileFile(c.ProfileFile).
+			Build()
+	default:
+		return nil, fmt.Errorf("unknown credentials type %q", c.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s credentials: %w", c.Type, err)
+	}
+
+	return credentials.FromCredentialsProvider(c.Type, p), nil
+}
+
+func (c *SLSCredentialsConfig) roleProvider(httpClient *http.Client, source providers.CredentialsProvider) *roleCredentialsProvider {
+	return &roleCredentialsProvider{
+		httpClient:      httpClient,
+		name:            c.Type,
+		source:          source,
+		roleArn:         c.RoleArn,
+		roleSessionName: c.RoleSessionName,
+		externalId:      c.ExternalId,
+		oidcProviderArn: c.OIDCProviderArn,
+		oidcTokenFile:   c.OIDCTokenFile,
+	}
+}
+
+func (c *SLSCredentialsConfig) staticProvider() (providers.CredentialsProvider, error) {
+	accessKeyId, err := utils.ReadSecret(c.AccessKeyIdEnv, c.AccessKeyIdFile)
+	if err != nil {
+		return nil, fmt.Errorf("read access key id: %w", err)
+	}
+	accessKeySecret, err := utils.ReadSecret(c.AccessKeySecretEnv, c.AccessKeySecretFile)
+	if err != nil {
+		return nil, fmt.Errorf("read access key secret: %w", err)
+	}
+	securityToken, err := utils.ReadSecret(c.SecurityTokenEnv, c.SecurityTokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("read security token: %w", err)
+	}
+
+	if securityToken == "" {
+		return providers.NewStaticAKCredentialsProviderBuilder().
+			WithAccessKeyId(accessKeyId).
+			WithAccessKeySecret(accessKeySecret).
+			Build()
+	}
+	return providers.NewStaticSTSCredentialsProviderBuilder().
+		WithAccessKeyId(accessKeyId).
+		WithAccessKeySecret(accessKeySecret).
+		WithSecurityToken(securityToken).
+		Build()
+}
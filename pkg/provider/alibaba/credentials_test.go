@@ -0,0 +1,165 @@
+package alibaba
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSLSCredentialsConfig_Init(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      SLSCredentialsConfig
+		expectedErr string
+	}{
+		{
+			name:   "access key",
+			config: SLSCredentialsConfig{Type: "access_key", AccessKeyIdEnv: "ID", AccessKeySecretFile: "/secret"},
+		},
+		{
+			name:        "access key without secret",
+			config:      SLSCredentialsConfig{Type: "access_key", AccessKeyIdEnv: "ID"},
+			expectedErr: "access_key_secret_env or access_key_secret_file is required for access_key credentials",
+		},
+		{
+			name:        "sts without token",
+			config:      SLSCredentialsConfig{Type: "sts", AccessKeyIdEnv: "ID", AccessKeySecretEnv: "SECRET"},
+			expectedErr: "security_token_env or security_token_file is required for sts credentials",
+		},
+		{
+			name:   "ram role arn with default source credentials",
+			config: SLSCredentialsConfig{Type: "ram_role_arn", RoleArn: "acs:ram::123:role/audit"},
+		},
+		{
+			name:        "ram role arn without role",
+			config:      SLSCredentialsConfig{Type: "ram_role_arn"},
+			expectedErr: "role_arn is required for ram_role_arn credentials",
+		},
+		{
+			name:        "oidc role arn without token file",
+			config:      SLSCredentialsConfig{Type: "oidc_role_arn", RoleArn: "acs:ram::123:role/audit", OIDCProviderArn: "acs:ram::123:oidc-provider/ack"},
+			expectedErr: "role_arn, oidc_provider_arn and oidc_token_file are required for oidc_role_arn credentials",
+		},
+		{
+			name:        "profile without name",
+			config:      SLSCredentialsConfig{Type: "profile"},
+			expectedErr: "profile is required for profile credentials",
+		},
+		{
+			name:        "missing type",
+			config:      SLSCredentialsConfig{},
+			expectedErr: "credentials type is required",
+		},
+		{
+			name:        "unknown type",
+			config:      SLSCredentialsConfig{Type: "ecs_ram_role"},
+			expectedErr: `unknown credentials type "ecs_ram_role"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Init()
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, defaultRoleSessionName, tt.config.RoleSessionName)
+		})
+	}
+}
+
+func TestSLSCredentialsConfig_NewCredential(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	assert.NoError(t, os.WriteFile(secretFile, []byte("file-secret\n"), 0600))
+	profileFile := filepath.Join(dir, "config.json")
+	assert.NoError(t, os.WriteFile(profileFile, []byte(`{
+  "current": "default",
+  "profiles": [
+    {"name": "default", "mode": "AK", "access_key_id": "default-id", "access_key_secret": "default-secret"},
+    {"name": "prod", "mode": "AK", "access_key_id": "prod-id", "access_key_secret": "prod-secret"}
+  ]
+}`), 0600))
+	t.Setenv("TEST_SLS_AK_ID", "env-id")
+	t.Setenv("TEST_SLS_AK_SECRET", "env-secret")
+	t.Setenv("TEST_SLS_STS_TOKEN", "env-token")
+
+	tests := []struct {
+		name           string
+		config         *SLSCredentialsConfig
+		expectedType   string
+		expectedId     string
+		expectedSecret string
+		expectedToken  string
+		expectedErr    string
+	}{
+		{
+			name: "access key from env and file",
+			config: &SLSCredentialsConfig{
+				Type:                "access_key",
+				AccessKeyIdEnv:      "TEST_SLS_AK_ID",
+				AccessKeySecretFile: secretFile,
+			},
+			expectedType:   "access_key",
+			expectedId:     "env-id",
+			expectedSecret: "file-secret",
+		},
+		{
+			name: "sts",
+			config: &SLSCredentialsConfig{
+				Type:               "sts",
+				AccessKeyIdEnv:     "TEST_SLS_AK_ID",
+				AccessKeySecretEnv: "TEST_SLS_AK_SECRET",
+				SecurityTokenEnv:   "TEST_SLS_STS_TOKEN",
+			},
+			expectedType:   "sts",
+			expectedId:     "env-id",
+			expectedSecret: "env-secret",
+			expectedToken:  "env-token",
+		},
+		{
+			name: "named profile",
+			config: &SLSCredentialsConfig{
+				Type:        "profile",
+				Profile:     "prod",
+				ProfileFile: profileFile,
+			},
+			expectedType:   "profile",
+			expectedId:     "prod-id",
+			expectedSecret: "prod-secret",
+		},
+		{
+			name: "missing env",
+			config: &SLSCredentialsConfig{
+				Type:               "access_key",
+				AccessKeyIdEnv:     "TEST_SLS_MISSING",
+				AccessKeySecretEnv: "TEST_SLS_AK_SECRET",
+			},
+			expectedErr: "read access key id: env TEST_SLS_MISSING is empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cred, err := tt.config.NewCredential(nil)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedType, *cred.GetType())
+
+			model, err := cred.GetCredential()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedId, *model.AccessKeyId)
+			assert.Equal(t, tt.expectedSecret, *model.AccessKeySecret)
+			if tt.expectedToken != "" {
+				assert.Equal(t, tt.expectedToken, *model.SecurityToken)
+			}
+		})
+	}
+}
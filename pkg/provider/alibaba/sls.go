@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"strings"
 
 	"github.com/alibabacloud-go/tea/tea"
@@ -13,6 +14,8 @@ import (
 	"github.com/aliyun/aliyun-log-go-sdk/util"
 	"github.com/aliyun/credentials-go/credentials"
 	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/ratelimit"
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
 	"github.com/mozillazg/kube-audit-mcp/pkg/types"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	k8saudit "k8s.io/apiserver/pkg/apis/audit"
@@ -20,8 +23,16 @@ import (
 
 const SLSProviderName = "alibaba-sls"
 
+// estimatedBytesPerEvent is the average size of an audit event, used to
+// estimate the bytes scanned by a query from the number of matched events.
+const estimatedBytesPerEvent = 2 * 1024
+
+// maxLines is the maximum number of logs that GetLogs returns.
+const maxLines = 100
+
 type SLSProvider struct {
 	client SLSClientInterface
+	cred   credentials.Credential
 
 	project  string
 	logstore string
@@ -34,6 +45,8 @@ type SLSProviderConfig struct {
 
 	Project  string `yaml:"project" json:"project"`
 	LogStore string `yaml:"logstore" json:"logstore"`
+
+	Credentials *SLSCredentialsConfig `yaml:"credentials,omitempty" json:"credentials,omitempty"`
 }
 
 type SLSAuthProvider struct {
@@ -41,17 +54,49 @@ type SLSAuthProvider struct {
 }
 
 type SLSClientInterface interface {
+	GetHistograms(project, logstore string, topic string, from int64, to int64,
+		queryExp string) (*sls.GetHistogramsResponse, error)
 	GetLogs(project, logstore, topic string, from, to int64, query string,
 		lines, offset int64, reverse bool) (*sls.GetLogsResponse, error)
 }
 
 var _ provider.Provider = (*SLSProvider)(nil)
+var _ provider.CredentialsChecker = (*SLSProvider)(nil)
+
+var slsCapabilities = provider.Capabilities{
+	Filters:        provider.AllFilters,
+	ResponseStatus: provider.FieldRecorded,
+	Bodies:         provider.FieldRecorded,
+	MaxEvents:      maxLines,
+}
 
-func NewSLSProvider(config *SLSProviderConfig) (*SLSProvider, error) {
+func init() {
+	provider.Register(provider.Registration{
+		Name:         SLSProviderName,
+		ConfigKey:    "alibaba_sls",
+		DecodeConfig: provider.DecodeConfig[SLSProviderConfig],
+		New: func(config any, transportConfig *transport.Config) (provider.Provider, error) {
+			return NewSLSProvider(config.(*SLSProviderConfig), transportConfig)
+		},
+		SampleConfig: &SLSProviderConfig{
+			Endpoint: "cn-hangzhou.log.aliyuncs.com",
+			Project:  "k8s-cxxx",
+			LogStore: "audit-cxxx",
+		},
+		SampleCluster: "dev",
+	})
+}
+
+func NewSLSProvider(config *SLSProviderConfig, transportConfig *transport.Config) (*SLSProvider, error) {
 	if err := config.Init(); err != nil {
 		return nil, fmt.Errorf("invalid %s provider config: %w", SLSProviderName, err)
 	}
-	cred, err := credentials.NewCredential(nil)
+	httpClient, err := transportConfig.HTTPClient()
+	if err != nil {
+		return nil, fmt.Errorf("invalid transport config: %w", err)
+	}
+
+	cred, err := config.Credentials.NewCredential(httpClient)
 	if err != nil {
 		return nil, fmt.Errorf("create credential error: %w", err)
 	}
@@ -59,19 +104,38 @@ func NewSLSProvider(config *SLSProviderConfig) (*SLSProvider, error) {
 		return nil, fmt.Errorf("get credential error: %w", err)
 	}
 
-	client := sls.CreateNormalInterfaceV2(config.Endpoint, &SLSAuthProvider{cred: cred})
+	endpoint := config.Endpoint
+	if transportConfig != nil && transportConfig.Endpoint != "" {
+		endpoint = transportConfig.Endpoint
+	}
+	client := sls.CreateNormalInterfaceV2(endpoint, &SLSAuthProvider{cred: cred})
 	if config.V4Auth() {
 		client.SetRegion(config.Region)
 		client.SetAuthVersion(sls.AuthV4)
 	}
+	if httpClient != nil {
+		client.SetHTTPClient(httpClient)
+	}
 
 	return &SLSProvider{
 		client:   client,
+		cred:     cred,
 		project:  config.Project,
 		logstore: config.LogStore,
 	}, nil
 }
 
+func (s *SLSProvider) CheckCredentials(_ context.Context) error {
+	if _, err := s.cred.GetCredential(); err != nil {
+		return fmt.Errorf("get credential error: %w", err)
+	}
+	return nil
+}
+
+func (s *SLSProvider) Capabilities() provider.Capabilities {
+	return slsCapabilities
+}
+
 func (s *SLSProvider) QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
 	var result types.AuditLogResult
 
@@ -82,17 +146,44 @@ func (s *SLSProvider) QueryAuditLog(ctx context.Context, params types.QueryAudit
 		From:    params.StartTime.Unix(),
 		To:      params.EndTime.Unix(),
 		Topic:   "",
-		Lines:   int64(params.Limit),
+		Lines:   int64(min(params.Limit, maxLines)),
 		Offset:  0,
 		Reverse: true,
 		Query:   query,
 	}
 
-	resp, err := s.client.GetLogs(s.project, s.logstore, req.Topic,
-		req.From, req.To, req.Query, req.Lines, req.Offset, req.Reverse)
+	if maxBytes := provider.MaxBytesScanned(ctx); maxBytes > 0 {
+		stats, err := s.estimateScan(ctx, req)
+		if err != nil {
+			return result, err
+		}
+		result.Stats = stats
+		provider.ReportProgress(ctx, provider.Progress{
+			Message:        "estimated the matched logs",
+			RecordsMatched: stats.RecordsMatched,
+		})
+		if stats.BytesScanned > maxBytes {
+			return result, &provider.ScanLimitError{
+				Limit:     maxBytes,
+				Scanned:   stats.BytesScanned,
+				Estimated: true,
+			}
+		}
+	}
+
+	provider.ReportProgress(ctx, provider.Progress{Message: "getting logs"})
+	var resp *sls.GetLogsResponse
+	err := ratelimit.Do(ctx, isThrottlingError, func() (err error) {
+		resp, err = s.client.GetLogs(s.project, s.logstore, req.Topic,
+			req.From, req.To, req.Query, req.Lines, req.Offset, req.Reverse)
+		return err
+	})
 	if err != nil {
 		return result, fmt.Errorf("get logs error: %w", err)
 	}
+	if !resp.IsComplete() {
+		provider.ReportStatus(ctx, provider.StatusWarning, "logs progress: %s, the result may be incomplete", resp.Progress)
+	}
 
 	entries := make([]types.AuditLogEntry, 0, len(resp.Logs))
 	for _, item := range resp.Logs {
@@ -106,6 +197,45 @@ func (s *SLSProvider) QueryAuditLog(ctx context.Context, params types.QueryAudit
 	return result, nil
 }
 
+// estimateScan estimates the bytes scanned by the query from the number of
+// matched events reported by GetHistograms.
+func (s *SLSProvider) estimateScan(ctx context.Context, req *sls.GetLogRequest) (*types.QueryStats, error) {
+	var resp *sls.GetHistogramsResponse
+	err := ratelimit.Do(ctx, isThrottlingError, func() (err error) {
+		resp, err = s.client.GetHistograms(s.project, s.logstore, req.Topic,
+			req.From, req.To, req.Query)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get histograms error: %w", err)
+	}
+	if !resp.IsComplete() {
+		provider.ReportStatus(ctx, provider.StatusWarning, "histograms progress: %s, the estimate may be too low", resp.Progress)
+	}
+
+	return &types.QueryStats{
+		BytesScanned:   resp.Count * estimatedBytesPerEvent,
+		RecordsMatched: resp.Count,
+		Estimated:      true,
+	}, nil
+}
+
+// isThrottlingError reports whether the request was rejected because of the
+// read quota of the project or logstore, or because the server is busy.
+func isThrottlingError(err error) bool {
+	var slsErr *sls.Error
+	if !errors.As(err, &slsErr) {
+		return false
+	}
+	switch slsErr.Code {
+	case sls.READ_QUOTA_EXCEED, sls.SHARD_READ_QUOTA_EXCEED,
+		sls.PROJECT_QUOTA_EXCEED, sls.SERVER_BUSY:
+		return true
+	}
+	return slsErr.HTTPCode == http.StatusTooManyRequests ||
+		slsErr.HTTPCode == http.StatusServiceUnavailable
+}
+
 func (s *SLSProvider) buildQuery(params types.QueryAuditLogParams) string {
 	query := "*"
 
@@ -137,6 +267,10 @@ func (s *SLSProvider) buildQuery(params types.QueryAuditLogParams) string {
 		query += fmt.Sprintf(" and objectRef.name: %s", getSLSFilterExp(params.ResourceName))
 	}
 
+	if params.AuditID != "" {
+		query += fmt.Sprintf(" and auditID: %q", params.AuditID)
+	}
+
 	return query
 }
 
@@ -208,6 +342,11 @@ func (c *SLSProviderConfig) Init() error {
 	if c.LogStore == "" {
 		return errors.New("logstore is required")
 	}
+	if c.Credentials != nil {
+		if err := c.Credentials.Init(); err != nil {
+			return fmt.Errorf("invalid credentials: %w", err)
+		}
+	}
 	return nil
 }
 
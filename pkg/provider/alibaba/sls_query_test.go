@@ -0,0 +1,184 @@
+package alibaba
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/ratelimit"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var errReadQuotaExceed = &sls.Error{HTTPCode: 403, Code: sls.READ_QUOTA_EXCEED, Message: "read quota exceed"}
+
+type mockSLSClient struct {
+	histograms    *sls.GetHistogramsResponse
+	histogramsErr error
+	logs          *sls.GetLogsResponse
+	logsErr       error
+	// throttled is the number of GetLogs calls rejected by the read quota
+	// before logs is returned.
+	throttled int
+
+	histogramsCalls int
+	logsCalls       int
+}
+
+func (m *mockSLSClient) GetHistograms(_, _ string, _ string, _ int64, _ int64,
+	_ string) (*sls.GetHistogramsResponse, error) {
+	m.histogramsCalls++
+	return m.histograms, m.histogramsErr
+}
+
+func (m *mockSLSClient) GetLogs(_, _ string, _ string, _ int64, _ int64, _ string,
+	_, _ int64, _ bool) (*sls.GetLogsResponse, error) {
+	m.logsCalls++
+	if m.logsCalls <= m.throttled {
+		return nil, errReadQuotaExceed
+	}
+	return m.logs, m.logsErr
+}
+
+func TestSLSProvider_QueryAuditLog(t *testing.T) {
+	params := types.QueryAuditLogParams{
+		StartTime: types.NewTimeParam(time.Now().Add(-1 * time.Hour)),
+		EndTime:   types.NewTimeParam(time.Now()),
+		Limit:     10,
+	}
+
+	tests := []struct {
+		name               string
+		maxBytes           int64
+		rateLimit          *ratelimit.Config
+		client             *mockSLSClient
+		expectedErr        string
+		expectedEntries    int
+		expectedStats      *types.QueryStats
+		expectedHistograms int
+		expectedLogs       int
+	}{
+		{
+			name: "no scan limit",
+			client: &mockSLSClient{
+				logs: &sls.GetLogsResponse{
+					Progress: "Complete",
+					Count:    1,
+					Logs:     []map[string]string{{"auditID": "a", "verb": "get"}},
+				},
+			},
+			expectedEntries: 1,
+			expectedLogs:    1,
+		},
+		{
+			name:     "within scan limit",
+			maxBytes: 1024 * 1024,
+			client: &mockSLSClient{
+				histograms: &sls.GetHistogramsResponse{Progress: "Complete", Count: 100},
+				logs: &sls.GetLogsResponse{
+					Progress: "Complete",
+					Count:    1,
+					Logs:     []map[string]string{{"auditID": "a", "verb": "get"}},
+				},
+			},
+			expectedEntries:    1,
+			expectedStats:      &types.QueryStats{BytesScanned: 100 * estimatedBytesPerEvent, RecordsMatched: 100, Estimated: true},
+			expectedHistograms: 1,
+			expectedLogs:       1,
+		},
+		{
+			name:     "exceeds scan limit",
+			maxBytes: 1024 * 1024,
+			client: &mockSLSClient{
+				histograms: &sls.GetHistogramsResponse{Progress: "Complete", Count: 1000},
+			},
+			expectedErr:        "query is estimated to scan 2048000 bytes, which exceeds the limit of 1048576 bytes",
+			expectedStats:      &types.QueryStats{BytesScanned: 1000 * estimatedBytesPerEvent, RecordsMatched: 1000, Estimated: true},
+			expectedHistograms: 1,
+		},
+		{
+			name:     "histograms error",
+			maxBytes: 1024,
+			client: &mockSLSClient{
+				histogramsErr: errors.New("denied"),
+			},
+			expectedErr:        "get histograms error: denied",
+			expectedHistograms: 1,
+		},
+		{
+			name: "get logs error",
+			client: &mockSLSClient{
+				logsErr: errors.New("denied"),
+			},
+			expectedErr:  "get logs error: denied",
+			expectedLogs: 1,
+		},
+		{
+			name:      "throttled and retried",
+			rateLimit: &ratelimit.Config{RetryBaseDelay: metav1.Duration{Duration: time.Millisecond}},
+			client: &mockSLSClient{
+				throttled: 2,
+				logs: &sls.GetLogsResponse{
+					Progress: "Complete",
+					Count:    1,
+					Logs:     []map[string]string{{"auditID": "a", "verb": "get"}},
+				},
+			},
+			expectedEntries: 1,
+			expectedLogs:    3,
+		},
+		{
+			name: "throttled without rate limit uses the default retries",
+			client: &mockSLSClient{
+				throttled: 1,
+				logs: &sls.GetLogsResponse{
+					Progress: "Complete",
+					Count:    1,
+					Logs:     []map[string]string{{"auditID": "a", "verb": "get"}},
+				},
+			},
+			expectedEntries: 1,
+			expectedLogs:    2,
+		},
+		{
+			name: "throttled too many times",
+			rateLimit: &ratelimit.Config{
+				MaxRetries:     1,
+				RetryBaseDelay: metav1.Duration{Duration: time.Millisecond},
+			},
+			client: &mockSLSClient{
+				throttled: 5,
+			},
+			expectedErr:  "get logs error: " + errReadQuotaExceed.Error(),
+			expectedLogs: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &SLSProvider{client: tt.client, project: "p", logstore: "l"}
+			ctx := context.Background()
+			if tt.maxBytes > 0 {
+				ctx = provider.WithMaxBytesScanned(ctx, tt.maxBytes)
+			}
+			if tt.rateLimit != nil {
+				ctx = ratelimit.NewContext(ctx, ratelimit.New(tt.rateLimit))
+			}
+
+			result, err := p.QueryAuditLog(ctx, params)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedEntries, len(result.Entries))
+			assert.Equal(t, tt.expectedStats, result.Stats)
+			assert.Equal(t, tt.expectedHistograms, tt.client.histogramsCalls)
+			assert.Equal(t, tt.expectedLogs, tt.client.logsCalls)
+		})
+	}
+}
@@ -208,6 +208,16 @@ func TestSLSProvider_buildQuery(t *testing.T) {
 			},
 			expected: `* and user.username: "user@domain.com" and objectRef.namespace: "test-namespace" and objectRef.name: "my-pod-with-dashes"`,
 		},
+		{
+			name: "audit id parameter",
+			params: types.QueryAuditLogParams{
+				StartTime: types.NewTimeParam(time.Now().Add(-1 * time.Hour)),
+				EndTime:   types.NewTimeParam(time.Now()),
+				AuditID:   "5b4c0a4e-7c52-4c8d-9b3f-3c1d2e0f6a7b",
+				Limit:     1,
+			},
+			expected: `* and auditID: "5b4c0a4e-7c52-4c8d-9b3f-3c1d2e0f6a7b"`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,177 @@
+package alibaba
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/credentials-go/credentials/providers"
+)
+
+const (
+	stsDurationSeconds = 3600
+	stsRequestTimeout  = 10 * time.Second
+	// stsRefreshBefore is how long before their expiration the credentials
+	// of the role are refreshed.
+	stsRefreshBefore = 3 * time.Minute
+)
+
+// Variable for mocking in tests
+var stsEndpoint = "https://sts.aliyuncs.com"
+
+// roleCredentialsProvider assumes a RAM role with the STS API. It is used
+// instead of the providers of credentials-go when the transport of the
+// cluster is customized, because they only support a proxy but not the CA
+// certificates of the transport config.
+type roleCredentialsProvider struct {
+	httpClient *http.Client
+	name       string
+
+	// source signs the AssumeRole requests, the role is assumed with the
+	// OIDC token instead when it is nil.
+	source          providers.CredentialsProvider
+	roleArn         string
+	roleSessionName string
+	externalId      string
+	oidcProviderArn string
+	oidcTokenFile   string
+
+	mu          sync.Mutex
+	credentials *providers.Credentials
+	expiration  time.Time
+}
+
+type assumeRoleResponse struct {
+	Credentials *struct {
+		AccessKeyId     string `json:"AccessKeyId"`
+		AccessKeySecret string `json:"AccessKeySecret"`
+		SecurityToken   string `json:"SecurityToken"`
+		Expiration      string `json:"Expiration"`
+	} `json:"Credentials"`
+}
+
+func (p *roleCredentialsProvider) GetProviderName() string {
+	return p.name
+}
+
+func (p *roleCredentialsProvider) GetCredentials() (*providers.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.credentials == nil || time.Until(p.expiration) <= stsRefreshBefore {
+		if err := p.assumeRole(); err != nil {
+			return nil, err
+		}
+	}
+	cc := *p.credentials
+	return &cc, nil
+}
+
+func (p *roleCredentialsProvider) assumeRole() error {
+	params := url.Values{
+		"Version":         {"2015-04-01"},
+		"Format":          {"JSON"},
+		"Timestamp":       {time.Now().UTC().Format("2006-01-02T15:04:05Z")},
+		"RoleArn":         {p.roleArn},
+		"RoleSessionName": {p.roleSessionName},
+		"DurationSeconds": {strconv.Itoa(stsDurationSeconds)},
+	}
+	if p.source == nil {
+		token, err := os.ReadFile(p.oidcTokenFile)
+		if err != nil {
+			return fmt.Errorf("read oidc_token_file: %w", err)
+		}
+		params.Set("Action", "AssumeRoleWithOIDC")
+		params.Set("OIDCProviderArn", p.oidcProviderArn)
+		params.Set("OIDCToken", strings.TrimSpace(string(token)))
+	} else {
+		source, err := p.source.GetCredentials()
+		if err != nil {
+			return fmt.Errorf("get source credentials: %w", err)
+		}
+		params.Set("Action", "AssumeRole")
+		if p.externalId != "" {
+			params.Set("ExternalId", p.externalId)
+		}
+		params.Set("AccessKeyId", source.AccessKeyId)
+		if source.SecurityToken != "" {
+			params.Set("SecurityToken", source.SecurityToken)
+		}
+		params.Set("SignatureMethod", "HMAC-SHA1")
+		params.Set("SignatureVersion", "1.0")
+		params.Set("SignatureNonce", stsNonce())
+		params.Set("Signature", signRPC(http.MethodPost, params, source.AccessKeySecret))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stsRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, stsEndpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("assume role %s: %w", p.roleArn, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("assume role %s: %w", p.roleArn, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("assume role %s: %s: %s", p.roleArn, resp.Status, body)
+	}
+
+	var data assumeRoleResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return fmt.Errorf("assume role %s: decode response: %w", p.roleArn, err)
+	}
+	if data.Credentials == nil || data.Credentials.AccessKeyId == "" {
+		return fmt.Errorf("assume role %s: no credentials in response", p.roleArn)
+	}
+	expiration, err := time.Parse("2006-01-02T15:04:05Z", data.Credentials.Expiration)
+	if err != nil {
+		return fmt.Errorf("assume role %s: invalid expiration: %w", p.roleArn, err)
+	}
+
+	p.credentials = &providers.Credentials{
+		AccessKeyId:     data.Credentials.AccessKeyId,
+		AccessKeySecret: data.Credentials.AccessKeySecret,
+		SecurityToken:   data.Credentials.SecurityToken,
+		ProviderName:    p.name,
+	}
+	p.expiration = expiration
+	return nil
+}
+
+// signRPC returns the signature of the parameters of an RPC style request
+// of Alibaba Cloud APIs.
+func signRPC(method string, params url.Values, accessKeySecret string) string {
+	// url.Values.Encode sorts the parameters by key
+	canonicalized := strings.NewReplacer("+", "%20", "*", "%2A", "%7E", "~").Replace(params.Encode())
+	stringToSign := method + "&%2F&" + url.QueryEscape(canonicalized)
+
+	mac := hmac.New(sha1.New, []byte(accessKeySecret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func stsNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
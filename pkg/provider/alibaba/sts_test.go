@@ -0,0 +1,137 @@
+package alibaba
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aliyun/credentials-go/credentials/providers"
+	"github.com/aliyun/credentials-go/credentials/utils"
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSTSServer returns an STS server that is only trusted with the CA of
+// the returned transport config.
+func newSTSServer(t *testing.T, handler func(form url.Values)) *transport.Config {
+	expiration := time.Now().Add(time.Hour).UTC().Format("2006-01-02T15:04:05Z")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		handler(r.PostForm)
+		_, _ = w.Write([]byte(`{"Credentials":{"AccessKeyId":"STS.id","AccessKeySecret":"sts-secret",` +
+			`"SecurityToken":"sts-token","Expiration":"` + expiration + `"}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	orig := stsEndpoint
+	stsEndpoint = server.URL
+	t.Cleanup(func() { stsEndpoint = orig })
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: server.Certificate().Raw,
+	}), 0600))
+	return &transport.Config{CAFile: caFile}
+}
+
+func TestSLSCredentialsConfig_NewCredential_RAMRoleARN(t *testing.T) {
+	t.Setenv("TEST_SLS_AK_ID", "source-id")
+	t.Setenv("TEST_SLS_AK_SECRET", "source-secret")
+	var requests int
+	transportConfig := newSTSServer(t, func(form url.Values) {
+		requests++
+		assert.Equal(t, "AssumeRole", form.Get("Action"))
+		assert.Equal(t, "acs:ram::123:role/audit", form.Get("RoleArn"))
+		assert.Equal(t, "ext", form.Get("ExternalId"))
+		assert.Equal(t, "source-id", form.Get("AccessKeyId"))
+
+		// The signature is the one of the providers of credentials-go
+		signParams := map[string]string{}
+		for key := range form {
+			if key != "Signature" {
+				signParams[key] = form.Get(key)
+			}
+		}
+		stringToSign := strings.NewReplacer("+", "%20", "*", "%2A", "%7E", "~").
+			Replace(utils.GetURLFormedMap(signParams))
+		stringToSign = "POST&%2F&" + url.QueryEscape(stringToSign)
+		assert.Equal(t, utils.ShaHmac1(stringToSign, "source-secret&"), form.Get("Signature"))
+	})
+	httpClient, err := transportConfig.HTTPClient()
+	assert.NoError(t, err)
+
+	config := &SLSCredentialsConfig{
+		Type:               CredentialTypeRAMRoleARN,
+		AccessKeyIdEnv:     "TEST_SLS_AK_ID",
+		AccessKeySecretEnv: "TEST_SLS_AK_SECRET",
+		RoleArn:            "acs:ram::123:role/audit",
+		ExternalId:         "ext",
+	}
+	assert.NoError(t, config.Init())
+	cred, err := config.NewCredential(httpClient)
+	assert.NoError(t, err)
+
+	for range 2 {
+		model, err := cred.GetCredential()
+		assert.NoError(t, err)
+		assert.Equal(t, "STS.id", *model.AccessKeyId)
+		assert.Equal(t, "sts-secret", *model.AccessKeySecret)
+		assert.Equal(t, "sts-token", *model.SecurityToken)
+	}
+	assert.Equal(t, 1, requests, "credentials should be reused until they expire")
+}
+
+func TestSLSCredentialsConfig_NewCredential_OIDCRoleARN(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("oidc-token\n"), 0600))
+	transportConfig := newSTSServer(t, func(form url.Values) {
+		assert.Equal(t, "AssumeRoleWithOIDC", form.Get("Action"))
+		assert.Equal(t, "acs:ram::123:oidc-provider/ack", form.Get("OIDCProviderArn"))
+		assert.Equal(t, "oidc-token", form.Get("OIDCToken"))
+		assert.Empty(t, form.Get("Signature"))
+	})
+	httpClient, err := transportConfig.HTTPClient()
+	assert.NoError(t, err)
+
+	config := &SLSCredentialsConfig{
+		Type:            CredentialTypeOIDCRoleARN,
+		RoleArn:         "acs:ram::123:role/audit",
+		OIDCProviderArn: "acs:ram::123:oidc-provider/ack",
+		OIDCTokenFile:   tokenFile,
+	}
+	assert.NoError(t, config.Init())
+	cred, err := config.NewCredential(httpClient)
+	assert.NoError(t, err)
+	model, err := cred.GetCredential()
+	assert.NoError(t, err)
+	assert.Equal(t, "STS.id", *model.AccessKeyId)
+}
+
+func TestRoleCredentialsProvider_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"Code":"NoPermission"}`))
+	}))
+	defer server.Close()
+	orig := stsEndpoint
+	stsEndpoint = server.URL
+	t.Cleanup(func() { stsEndpoint = orig })
+
+	source, err := providers.NewStaticAKCredentialsProviderBuilder().
+		WithAccessKeyId("id").WithAccessKeySecret("secret").Build()
+	assert.NoError(t, err)
+	p := &roleCredentialsProvider{
+		httpClient: server.Client(),
+		name:       CredentialTypeRAMRoleARN,
+		source:     source,
+		roleArn:    "acs:ram::123:role/audit",
+	}
+	_, err = p.GetCredentials()
+	assert.EqualError(t, err, `assume role acs:ram::123:role/audit: 403 Forbidden: {"Code":"NoPermission"}`)
+}
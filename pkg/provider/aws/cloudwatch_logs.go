@@ -10,67 +10,148 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	cloudwatchlogstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/smithy-go"
 	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/ratelimit"
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
 	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8saudit "k8s.io/apiserver/pkg/apis/audit"
 )
 
 const CloudWatchProviderName = "aws-cloudwatch-logs"
 
+// Variables for mocking in tests
+var (
+	minPollInterval = 250 * time.Millisecond
+	maxPollInterval = 5 * time.Second
+)
+
+// stopQueryTimeout bounds the StopQuery request that is sent when a query
+// is abandoned, the context of the query may already be done.
+const stopQueryTimeout = 10 * time.Second
+
 type CloudWatchLogsProvider struct {
-	client *cloudwatchlogs.Client
+	client      CloudWatchLogsClientInterface
+	credentials aws.CredentialsProvider
 
 	logGroupName       string
 	logGroupIdentifier string
+	queryTimeout       time.Duration
 }
 
 type CloudWatchLogsProviderConfig struct {
 	Region             string `yaml:"region,omitempty" json:"region,omitempty"`
 	LogGroupName       string `yaml:"log_group_name" json:"log_group_name"`
 	LogGroupIdentifier string `yaml:"log_group_identifier,omitempty" json:"log_group_identifier,omitempty"`
+
+	// QueryTimeout stops the Logs Insights query when it is still running
+	// after the timeout, the results found so far are returned as partial
+	// results.
+	QueryTimeout metav1.Duration `yaml:"query_timeout,omitempty" json:"query_timeout,omitzero"`
+
+	Credentials *CloudWatchLogsCredentialsConfig `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+}
+
+type CloudWatchLogsClientInterface interface {
+	StartQuery(ctx context.Context, params *cloudwatchlogs.StartQueryInput,
+		optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error)
+	GetQueryResults(ctx context.Context, params *cloudwatchlogs.GetQueryResultsInput,
+		optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error)
+	StopQuery(ctx context.Context, params *cloudwatchlogs.StopQueryInput,
+		optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error)
+}
+
+// queryOutput is the outcome of a Logs Insights query.
+type queryOutput struct {
+	messages []string
+	stats    *types.QueryStats
+	partial  bool
 }
 
 var _ provider.Provider = (*CloudWatchLogsProvider)(nil)
+var _ provider.CredentialsChecker = (*CloudWatchLogsProvider)(nil)
+
+var cloudWatchLogsCapabilities = provider.Capabilities{
+	Filters:        provider.AllFilters,
+	ResponseStatus: provider.FieldRecorded,
+	Bodies:         provider.FieldRecorded,
+	MaxEvents:      10000,
+	Notes: []string{
+		"Logs Insights returns at most 10000 events per query.",
+	},
+}
+
+func init() {
+	provider.Register(provider.Registration{
+		Name:         CloudWatchProviderName,
+		ConfigKey:    "aws_cloudwatch_logs",
+		DecodeConfig: provider.DecodeConfig[CloudWatchLogsProviderConfig],
+		New: func(config any, transportConfig *transport.Config) (provider.Provider, error) {
+			return NewCloudWatchLogsProvider(config.(*CloudWatchLogsProviderConfig), transportConfig)
+		},
+		SampleConfig: &CloudWatchLogsProviderConfig{
+			LogGroupName: "/aws/eks/xxx/cluster",
+		},
+		SampleCluster: "prod",
+	})
+}
 
-func NewCloudWatchLogsProvider(config *CloudWatchLogsProviderConfig) (*CloudWatchLogsProvider, error) {
+func NewCloudWatchLogsProvider(config *CloudWatchLogsProviderConfig, transportConfig *transport.Config) (*CloudWatchLogsProvider, error) {
 	if err := config.Init(); err != nil {
 		return nil, fmt.Errorf("invalid %s provider config: %w", CloudWatchProviderName, err)
 	}
 
-	var opts []func(*awsconfig.LoadOptions) error
-	if config.Region != "" {
-		opts = append(opts, awsconfig.WithRegion(config.Region))
-	}
-	cfg, err := awsconfig.LoadDefaultConfig(context.TODO(), opts...)
+	cfg, err := loadConfig(context.TODO(), config, transportConfig)
 	if err != nil {
-		return nil, fmt.Errorf("unable to load SDK config: %w", err)
+		return nil, err
 	}
-	client := cloudwatchlogs.NewFromConfig(cfg)
+	client := cloudwatchlogs.NewFromConfig(cfg, func(o *cloudwatchlogs.Options) {
+		if transportConfig != nil && transportConfig.Endpoint != "" {
+			o.BaseEndpoint = aws.String(transportConfig.Endpoint)
+		}
+	})
 
 	return &CloudWatchLogsProvider{
 		client:             client,
+		credentials:        cfg.Credentials,
 		logGroupName:       config.LogGroupName,
 		logGroupIdentifier: config.LogGroupIdentifier,
+		queryTimeout:       config.QueryTimeout.Duration,
 	}, nil
 }
 
+func (c *CloudWatchLogsProvider) CheckCredentials(ctx context.Context) error {
+	if c.credentials == nil {
+		return errors.New("no credentials found")
+	}
+	if _, err := c.credentials.Retrieve(ctx); err != nil {
+		return fmt.Errorf("retrieve credentials: %w", err)
+	}
+	return nil
+}
+
+func (c *CloudWatchLogsProvider) Capabilities() provider.Capabilities {
+	return cloudWatchLogsCapabilities
+}
+
 func (c *CloudWatchLogsProvider) QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
 	var result types.AuditLogResult
 	query := c.buildQuery(params)
 	log.Printf("query: %s", query)
 
-	queryResults, err := c.queryLogs(ctx, params, query)
+	output, err := c.queryLogs(ctx, params, query)
+	result.Stats = output.stats
 	if err != nil {
 		return result, fmt.Errorf("failed to query logs: %w", err)
 	}
 
-	entries := make([]types.AuditLogEntry, 0, len(queryResults))
-	result.Total = len(entries)
+	entries := make([]types.AuditLogEntry, 0, len(output.messages))
 	result.ProviderQuery = query
-	for _, item := range queryResults {
+	result.Partial = output.partial
+	for _, item := range output.messages {
 		entry, err := c.convertLogToK8sAudit(item)
 		if err != nil {
 			return result, fmt.Errorf("failed to convert log to k8s audit: %w", err)
@@ -78,11 +159,19 @@ func (c *CloudWatchLogsProvider) QueryAuditLog(ctx context.Context, params types
 		entries = append(entries, types.AuditLogEntry(entry))
 	}
 	result.Entries = entries
+	result.Total = len(entries)
 
 	return result, nil
 }
 
-func (c *CloudWatchLogsProvider) queryLogs(ctx context.Context, params types.QueryAuditLogParams, query string) ([]string, error) {
+// queryLogs starts a Logs Insights query and polls its results with an
+// increasing interval until it completes. The query is stopped when it
+// returns before the query has finished, e.g. when ctx is done, the scan
+// limit is exceeded or the results can't be fetched, so that it does not
+// keep running and billing. When the query timeout is reached the results
+// found so far are returned as partial results.
+func (c *CloudWatchLogsProvider) queryLogs(ctx context.Context, params types.QueryAuditLogParams, query string) (queryOutput, error) {
+	var output queryOutput
 	var logGroupIdentifiers []string
 	var logGroupName *string
 	if c.logGroupName != "" {
@@ -101,50 +190,136 @@ func (c *CloudWatchLogsProvider) queryLogs(ctx context.Context, params types.Que
 		QueryString:         aws.String(query),
 	}
 
-	resp, err := c.client.StartQuery(ctx, &req)
+	var resp *cloudwatchlogs.StartQueryOutput
+	err := ratelimit.Do(ctx, isThrottlingError, func() (err error) {
+		resp, err = c.client.StartQuery(ctx, &req)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to start query: %w", err)
+		return output, fmt.Errorf("failed to start query: %w", err)
 	}
-
-	var queryResults []string
-getResults:
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, fmt.Errorf("query was cancled: %w", ctx.Err())
-		default:
+	provider.ReportProgress(ctx, provider.Progress{Message: "Logs Insights query started"})
+	finished := false
+	defer func() {
+		if !finished {
+			c.stopQuery(ctx, resp.QueryId)
 		}
+	}()
 
-		output, err := c.client.GetQueryResults(context.TODO(), &cloudwatchlogs.GetQueryResultsInput{
-			QueryId: resp.QueryId,
+	var timeout <-chan time.Time
+	if c.queryTimeout > 0 {
+		timer := time.NewTimer(c.queryTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	maxBytes := provider.MaxBytesScanned(ctx)
+	interval := minPollInterval
+	for {
+		var results *cloudwatchlogs.GetQueryResultsOutput
+		err := ratelimit.Do(ctx, isThrottlingError, func() (err error) {
+			results, err = c.client.GetQueryResults(ctx, &cloudwatchlogs.GetQueryResultsInput{
+				QueryId: resp.QueryId,
+			})
+			return err
 		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to get query results: %w", err)
+			if ctx.Err() != nil {
+				return output, fmt.Errorf("query was canceled: %w", ctx.Err())
+			}
+			return output, fmt.Errorf("failed to get query results: %w", err)
 		}
 
-		log.Printf("query status: %s", output.Status)
-		switch output.Status {
-		case cloudwatchlogstypes.QueryStatusComplete:
-			for _, kvals := range output.Results {
-				for _, item := range kvals {
-					if item.Field == nil || *item.Field != "@message" {
-						log.Printf("skipping field %s, only @message is processed", *item.Field)
-						continue
-					}
-					queryResults = append(queryResults, aws.ToString(item.Value))
-				}
+		output.stats = convertQueryStatistics(results.Statistics)
+		if maxBytes > 0 && output.stats != nil && output.stats.BytesScanned > maxBytes {
+			return output, &provider.ScanLimitError{
+				Limit:   maxBytes,
+				Scanned: output.stats.BytesScanned,
 			}
-			break getResults
+		}
+
+		log.Printf("query status: %s", results.Status)
+		// Running queries return the results found so far
+		output.messages = getMessages(results.Results)
+		progress := provider.Progress{Message: fmt.Sprintf("Logs Insights query %s", strings.ToLower(string(results.Status)))}
+		if output.stats != nil {
+			progress.RecordsScanned = output.stats.RecordsScanned
+			progress.RecordsMatched = output.stats.RecordsMatched
+		}
+		provider.ReportProgress(ctx, progress)
+		switch results.Status {
+		case cloudwatchlogstypes.QueryStatusComplete:
+			finished = true
+			return output, nil
 		case cloudwatchlogstypes.QueryStatusFailed, cloudwatchlogstypes.QueryStatusCancelled, cloudwatchlogstypes.QueryStatusTimeout:
-			return nil, fmt.Errorf("query failed with status: %s", output.Status)
-		default:
-			break
+			finished = true
+			return output, fmt.Errorf("query failed with status: %s", results.Status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return output, fmt.Errorf("query was canceled: %w", ctx.Err())
+		case <-timeout:
+			provider.ReportStatus(ctx, provider.StatusWarning,
+				"query did not complete within %s, returning partial results", c.queryTimeout)
+			output.partial = true
+			return output, nil
+		case <-time.After(interval):
+		}
+		interval = min(interval*2, maxPollInterval)
+	}
+}
+
+func (c *CloudWatchLogsProvider) stopQuery(ctx context.Context, queryId *string) {
+	// ctx may already be canceled, the query should be stopped anyway
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stopQueryTimeout)
+	defer cancel()
+
+	if _, err := c.client.StopQuery(ctx, &cloudwatchlogs.StopQueryInput{
+		QueryId: queryId,
+	}); err != nil {
+		log.Printf("failed to stop query %s: %v", aws.ToString(queryId), err)
+	}
+}
+
+func getMessages(results [][]cloudwatchlogstypes.ResultField) []string {
+	var messages []string
+	for _, kvals := range results {
+		for _, item := range kvals {
+			if item.Field == nil || *item.Field != "@message" {
+				log.Printf("skipping field %s, only @message is processed", aws.ToString(item.Field))
+				continue
+			}
+			messages = append(messages, aws.ToString(item.Value))
 		}
+	}
+	return messages
+}
 
-		time.Sleep(1 * time.Second)
+// isThrottlingError reports whether the request was throttled, e.g. when the
+// limit of concurrent Logs Insights queries of the account is reached.
+func isThrottlingError(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
 	}
+	switch apiErr.ErrorCode() {
+	case "LimitExceededException", "ThrottlingException",
+		"TooManyRequestsException", "ServiceUnavailableException":
+		return true
+	}
+	return false
+}
 
-	return queryResults, nil
+func convertQueryStatistics(statistics *cloudwatchlogstypes.QueryStatistics) *types.QueryStats {
+	if statistics == nil {
+		return nil
+	}
+	return &types.QueryStats{
+		BytesScanned:   int64(statistics.BytesScanned),
+		RecordsScanned: int64(statistics.RecordsScanned),
+		RecordsMatched: int64(statistics.RecordsMatched),
+	}
 }
 
 func (c *CloudWatchLogsProvider) buildQuery(params types.QueryAuditLogParams) string {
@@ -183,6 +358,10 @@ func (c *CloudWatchLogsProvider) buildQuery(params types.QueryAuditLogParams) st
 		filters = append(filters, fmt.Sprintf("objectRef.name %s %q", exp, val))
 	}
 
+	if params.AuditID != "" {
+		filters = append(filters, fmt.Sprintf("auditID = %q", params.AuditID))
+	}
+
 	if len(filters) > 0 {
 		query = fmt.Sprintf("%s | filter %s", query, strings.Join(filters, " and "))
 	}
@@ -206,6 +385,14 @@ func (c *CloudWatchLogsProviderConfig) Init() error {
 	if c.LogGroupName != "" && c.LogGroupIdentifier != "" {
 		return errors.New("only one of log_group_name or log_group_identifier can be provided")
 	}
+	if c.QueryTimeout.Duration < 0 {
+		return errors.New("query_timeout must not be negative")
+	}
+	if c.Credentials != nil {
+		if err := c.Credentials.Init(); err != nil {
+			return fmt.Errorf("invalid credentials: %w", err)
+		}
+	}
 	return nil
 }
 
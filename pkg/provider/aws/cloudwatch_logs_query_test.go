@@ -0,0 +1,295 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cloudwatchlogstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/smithy-go"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/ratelimit"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var errConcurrencyLimit = &smithy.GenericAPIError{
+	Code:    "LimitExceededException",
+	Message: "Account maximum query concurrency limit of [30] reached",
+}
+
+type mockCloudWatchLogsClient struct {
+	// startThrottled is the number of StartQuery calls rejected by the
+	// concurrency limit before the query is started.
+	startThrottled int
+	startErr       error
+	// results are returned by GetQueryResults in order, the last one is
+	// repeated.
+	results    []*cloudwatchlogs.GetQueryResultsOutput
+	resultsErr error
+	// cancel is called after the given number of GetQueryResults calls.
+	cancel      context.CancelFunc
+	cancelAfter int
+
+	mu           sync.Mutex
+	startCalls   int
+	resultsCalls int
+	stopCalls    int
+	stopCtxErr   error
+}
+
+func (m *mockCloudWatchLogsClient) StartQuery(_ context.Context, _ *cloudwatchlogs.StartQueryInput,
+	_ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.startCalls++
+	if m.startCalls <= m.startThrottled {
+		return nil, errConcurrencyLimit
+	}
+	if m.startErr != nil {
+		return nil, m.startErr
+	}
+	return &cloudwatchlogs.StartQueryOutput{QueryId: aws.String("query-1")}, nil
+}
+
+func (m *mockCloudWatchLogsClient) GetQueryResults(ctx context.Context, _ *cloudwatchlogs.GetQueryResultsInput,
+	_ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resultsCalls++
+	if m.cancel != nil && m.resultsCalls >= m.cancelAfter {
+		m.cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if m.resultsErr != nil {
+		return nil, m.resultsErr
+	}
+	return m.results[min(m.resultsCalls, len(m.results))-1], nil
+}
+
+func (m *mockCloudWatchLogsClient) StopQuery(ctx context.Context, _ *cloudwatchlogs.StopQueryInput,
+	_ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopCalls++
+	m.stopCtxErr = ctx.Err()
+	return &cloudwatchlogs.StopQueryOutput{Success: true}, nil
+}
+
+func queryResults(status cloudwatchlogstypes.QueryStatus, bytesScanned float64, auditIDs ...string) *cloudwatchlogs.GetQueryResultsOutput {
+	output := &cloudwatchlogs.GetQueryResultsOutput{
+		Status:     status,
+		Statistics: &cloudwatchlogstypes.QueryStatistics{BytesScanned: bytesScanned},
+	}
+	for _, id := range auditIDs {
+		output.Results = append(output.Results, []cloudwatchlogstypes.ResultField{
+			{Field: aws.String("@ptr"), Value: aws.String("ptr")},
+			{Field: aws.String("@message"), Value: aws.String(`{"auditID":"` + id + `","verb":"get"}`)},
+		})
+	}
+	return output
+}
+
+func setPollInterval(t *testing.T, interval time.Duration) {
+	origMin, origMax := minPollInterval, maxPollInterval
+	minPollInterval, maxPollInterval = interval, interval
+	t.Cleanup(func() { minPollInterval, maxPollInterval = origMin, origMax })
+}
+
+func TestCloudWatchLogsProvider_QueryAuditLog(t *testing.T) {
+	setPollInterval(t, time.Millisecond)
+	params := types.QueryAuditLogParams{
+		StartTime: types.NewTimeParam(time.Now().Add(-1 * time.Hour)),
+		EndTime:   types.NewTimeParam(time.Now()),
+		Limit:     10,
+	}
+
+	tests := []struct {
+		name            string
+		queryTimeout    time.Duration
+		maxBytes        int64
+		rateLimit       *ratelimit.Config
+		client          *mockCloudWatchLogsClient
+		expectedErr     string
+		expectedIDs     []string
+		expectedPartial bool
+		expectedStats   *types.QueryStats
+		expectedStarts  int
+		expectedStops   int
+	}{
+		{
+			name: "complete after polling",
+			client: &mockCloudWatchLogsClient{
+				results: []*cloudwatchlogs.GetQueryResultsOutput{
+					queryResults(cloudwatchlogstypes.QueryStatusScheduled, 0),
+					queryResults(cloudwatchlogstypes.QueryStatusRunning, 100, "a"),
+					queryResults(cloudwatchlogstypes.QueryStatusComplete, 200, "a", "b"),
+				},
+			},
+			expectedIDs:    []string{"a", "b"},
+			expectedStats:  &types.QueryStats{BytesScanned: 200},
+			expectedStarts: 1,
+		},
+		{
+			name: "query failed",
+			client: &mockCloudWatchLogsClient{
+				results: []*cloudwatchlogs.GetQueryResultsOutput{
+					queryResults(cloudwatchlogstypes.QueryStatusFailed, 0),
+				},
+			},
+			expectedErr:    "failed to query logs: query failed with status: Failed",
+			expectedStats:  &types.QueryStats{},
+			expectedStarts: 1,
+		},
+		{
+			name: "timeout returns partial results",
+			// The query keeps running
+			queryTimeout: 20 * time.Millisecond,
+			client: &mockCloudWatchLogsClient{
+				results: []*cloudwatchlogs.GetQueryResultsOutput{
+					queryResults(cloudwatchlogstypes.QueryStatusRunning, 100, "a"),
+				},
+			},
+			expectedIDs:     []string{"a"},
+			expectedPartial: true,
+			expectedStats:   &types.QueryStats{BytesScanned: 100},
+			expectedStarts:  1,
+			expectedStops:   1,
+		},
+		{
+			name:     "scan limit stops the query",
+			maxBytes: 150,
+			client: &mockCloudWatchLogsClient{
+				results: []*cloudwatchlogs.GetQueryResultsOutput{
+					queryResults(cloudwatchlogstypes.QueryStatusRunning, 100),
+					queryResults(cloudwatchlogstypes.QueryStatusRunning, 200, "a"),
+				},
+			},
+			expectedErr:    "failed to query logs: query scanned 200 bytes, which exceeds the limit of 150 bytes",
+			expectedStats:  &types.QueryStats{BytesScanned: 200},
+			expectedStarts: 1,
+			expectedStops:  1,
+		},
+		{
+			name:      "start query throttled and retried",
+			rateLimit: &ratelimit.Config{RetryBaseDelay: metav1.Duration{Duration: time.Millisecond}},
+			client: &mockCloudWatchLogsClient{
+				startThrottled: 2,
+				results: []*cloudwatchlogs.GetQueryResultsOutput{
+					queryResults(cloudwatchlogstypes.QueryStatusComplete, 100, "a"),
+				},
+			},
+			expectedIDs:    []string{"a"},
+			expectedStats:  &types.QueryStats{BytesScanned: 100},
+			expectedStarts: 3,
+		},
+		{
+			name: "start query error",
+			client: &mockCloudWatchLogsClient{
+				startErr: errors.New("denied"),
+			},
+			expectedErr:    "failed to query logs: failed to start query: denied",
+			expectedStarts: 1,
+		},
+		{
+			name: "get query results error",
+			client: &mockCloudWatchLogsClient{
+				resultsErr: errors.New("denied"),
+			},
+			expectedErr:    "failed to query logs: failed to get query results: denied",
+			expectedStarts: 1,
+			// The query would keep running
+			expectedStops: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &CloudWatchLogsProvider{
+				client:       tt.client,
+				logGroupName: "/aws/eks/test/cluster",
+				queryTimeout: tt.queryTimeout,
+			}
+			ctx := context.Background()
+			if tt.maxBytes > 0 {
+				ctx = provider.WithMaxBytesScanned(ctx, tt.maxBytes)
+			}
+			if tt.rateLimit != nil {
+				ctx = ratelimit.NewContext(ctx, ratelimit.New(tt.rateLimit))
+			}
+
+			result, err := p.QueryAuditLog(ctx, params)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			var ids []string
+			for _, entry := range result.Entries {
+				ids = append(ids, string(entry.AuditID))
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+			assert.Equal(t, len(tt.expectedIDs), result.Total)
+			assert.Equal(t, tt.expectedPartial, result.Partial)
+			assert.Equal(t, tt.expectedStats, result.Stats)
+			assert.Equal(t, tt.expectedStarts, tt.client.startCalls)
+			assert.Equal(t, tt.expectedStops, tt.client.stopCalls)
+		})
+	}
+}
+
+func TestCloudWatchLogsProvider_QueryAuditLog_Canceled(t *testing.T) {
+	setPollInterval(t, time.Millisecond)
+	params := types.QueryAuditLogParams{
+		StartTime: types.NewTimeParam(time.Now().Add(-1 * time.Hour)),
+		EndTime:   types.NewTimeParam(time.Now()),
+		Limit:     10,
+	}
+
+	tests := []struct {
+		name        string
+		cancelAfter int
+	}{
+		{
+			name:        "canceled while waiting",
+			cancelAfter: 0,
+		},
+		{
+			name:        "canceled during GetQueryResults",
+			cancelAfter: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			client := &mockCloudWatchLogsClient{
+				results: []*cloudwatchlogs.GetQueryResultsOutput{
+					queryResults(cloudwatchlogstypes.QueryStatusRunning, 100),
+				},
+			}
+			if tt.cancelAfter > 0 {
+				client.cancel = cancel
+				client.cancelAfter = tt.cancelAfter
+			} else {
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+			p := &CloudWatchLogsProvider{client: client, logGroupName: "/aws/eks/test/cluster"}
+
+			_, err := p.QueryAuditLog(ctx, params)
+			assert.EqualError(t, err, "failed to query logs: query was canceled: context canceled")
+			assert.Equal(t, 1, client.stopCalls)
+			assert.NoError(t, client.stopCtxErr, "StopQuery must not use the canceled context")
+		})
+	}
+}
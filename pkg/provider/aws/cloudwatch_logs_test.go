@@ -1,8 +1,12 @@
 package aws
 
 import (
-	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"errors"
+	"fmt"
 	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
 )
 
 func TestCloudWatchLogsProvider_buildQuery(t *testing.T) {
@@ -220,6 +224,14 @@ func TestCloudWatchLogsProvider_buildQuery(t *testing.T) {
 			},
 			expected: `fields @message | filter @logStream like "kube-apiserver-audit" | filter user.username = "testuser" | sort @timestamp desc | limit 10000`,
 		},
+		{
+			name: "query with audit id",
+			params: types.QueryAuditLogParams{
+				AuditID: "5b4c0a4e-7c52-4c8d-9b3f-3c1d2e0f6a7b",
+				Limit:   1,
+			},
+			expected: `fields @message | filter @logStream like "kube-apiserver-audit" | filter auditID = "5b4c0a4e-7c52-4c8d-9b3f-3c1d2e0f6a7b" | sort @timestamp desc | limit 1`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -361,3 +373,40 @@ func TestCloudWatchLogsProviderConfig_Init(t *testing.T) {
 		})
 	}
 }
+
+func TestIsThrottlingError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "concurrent queries limit",
+			err:      &smithy.GenericAPIError{Code: "LimitExceededException", Message: "Account maximum query concurrency limit of [30] reached"},
+			expected: true,
+		},
+		{
+			name:     "wrapped throttling exception",
+			err:      fmt.Errorf("operation error: %w", &smithy.GenericAPIError{Code: "ThrottlingException"}),
+			expected: true,
+		},
+		{
+			name:     "other api error",
+			err:      &smithy.GenericAPIError{Code: "ResourceNotFoundException"},
+			expected: false,
+		},
+		{
+			name:     "non api error",
+			err:      errors.New("connection refused"),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isThrottlingError(tt.err); got != tt.expected {
+				t.Errorf("isThrottlingError() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
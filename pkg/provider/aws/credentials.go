@@ -0,0 +1,100 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
+)
+
+const defaultRoleSessionName = "kube-audit-mcp"
+
+// CloudWatchLogsCredentialsConfig configures the credentials of a cluster.
+// The default credential chain is used when it is not set.
+type CloudWatchLogsCredentialsConfig struct {
+	// Profile is the name of a profile in the shared config and credentials
+	// files.
+	Profile string `yaml:"profile,omitempty" json:"profile,omitempty"`
+
+	// RoleArn is assumed with the credentials of the profile, or with the
+	// token in WebIdentityTokenFile.
+	RoleArn         string `yaml:"role_arn,omitempty" json:"role_arn,omitempty"`
+	ExternalId      string `yaml:"external_id,omitempty" json:"external_id,omitempty"`
+	RoleSessionName string `yaml:"role_session_name,omitempty" json:"role_session_name,omitempty"`
+
+	WebIdentityTokenFile string `yaml:"web_identity_token_file,omitempty" json:"web_identity_token_file,omitempty"`
+}
+
+func (c *CloudWatchLogsCredentialsConfig) Init() error {
+	if c.RoleSessionName == "" {
+		c.RoleSessionName = defaultRoleSessionName
+	}
+	if c.RoleArn == "" {
+		if c.ExternalId != "" || c.WebIdentityTokenFile != "" {
+			return errors.New("role_arn is required when external_id or web_identity_token_file is set")
+		}
+		if c.Profile == "" {
+			return errors.New("either profile or role_arn must be provided")
+		}
+	}
+	if c.WebIdentityTokenFile != "" && c.ExternalId != "" {
+		return errors.New("external_id is not supported with web_identity_token_file")
+	}
+	return nil
+}
+
+// loadConfig loads the SDK config with the credentials and the HTTP client
+// of the cluster.
+func loadConfig(ctx context.Context, config *CloudWatchLogsProviderConfig, transportConfig *transport.Config) (aws.Config, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if config.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(config.Region))
+	}
+	if transportConfig != nil {
+		if err := transportConfig.Init(); err != nil {
+			return aws.Config{}, fmt.Errorf("invalid transport config: %w", err)
+		}
+		// The SDK builds the transport so that AWS_CA_BUNDLE, which requires
+		// a buildable client, keeps working. The config is validated above.
+		httpClient := awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+			_ = transportConfig.ConfigureTransport(tr)
+		})
+		opts = append(opts, awsconfig.WithHTTPClient(httpClient))
+	}
+	creds := config.Credentials
+	if creds != nil && creds.Profile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(creds.Profile))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return cfg, fmt.Errorf("unable to load SDK config: %w", err)
+	}
+	if creds == nil || creds.RoleArn == "" {
+		return cfg, nil
+	}
+
+	client := sts.NewFromConfig(cfg)
+	if creds.WebIdentityTokenFile != "" {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(client, creds.RoleArn,
+			stscreds.IdentityTokenFile(creds.WebIdentityTokenFile),
+			func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = creds.RoleSessionName
+			}))
+		return cfg, nil
+	}
+	cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(client, creds.RoleArn,
+		func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = creds.RoleSessionName
+			if creds.ExternalId != "" {
+				o.ExternalID = aws.String(creds.ExternalId)
+			}
+		}))
+	return cfg, nil
+}
@@ -0,0 +1,160 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudWatchLogsCredentialsConfig_Init(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      CloudWatchLogsCredentialsConfig
+		expectedErr string
+	}{
+		{
+			name:   "profile",
+			config: CloudWatchLogsCredentialsConfig{Profile: "prod"},
+		},
+		{
+			name:   "assume role with external id",
+			config: CloudWatchLogsCredentialsConfig{RoleArn: "arn:aws:iam::123456789012:role/audit", ExternalId: "ext"},
+		},
+		{
+			name:   "web identity",
+			config: CloudWatchLogsCredentialsConfig{RoleArn: "arn:aws:iam::123456789012:role/audit", WebIdentityTokenFile: "/var/run/token"},
+		},
+		{
+			name:        "empty",
+			config:      CloudWatchLogsCredentialsConfig{},
+			expectedErr: "either profile or role_arn must be provided",
+		},
+		{
+			name:        "external id without role",
+			config:      CloudWatchLogsCredentialsConfig{Profile: "prod", ExternalId: "ext"},
+			expectedErr: "role_arn is required when external_id or web_identity_token_file is set",
+		},
+		{
+			name: "external id with web identity",
+			config: CloudWatchLogsCredentialsConfig{
+				RoleArn:              "arn:aws:iam::123456789012:role/audit",
+				ExternalId:           "ext",
+				WebIdentityTokenFile: "/var/run/token",
+			},
+			expectedErr: "external_id is not supported with web_identity_token_file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Init()
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, defaultRoleSessionName, tt.config.RoleSessionName)
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	assert.NoError(t, os.WriteFile(configFile, []byte(`[profile prod]
+region = eu-west-1
+`), 0600))
+	credentialsFile := filepath.Join(dir, "credentials")
+	assert.NoError(t, os.WriteFile(credentialsFile, []byte(`[default]
+aws_access_key_id = AKIADEFAULT
+aws_secret_access_key = default-secret
+
+[prod]
+aws_access_key_id = AKIAPROD
+aws_secret_access_key = prod-secret
+`), 0600))
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+
+	t.Run("named profile", func(t *testing.T) {
+		cfg, err := loadConfig(context.Background(), &CloudWatchLogsProviderConfig{
+			Credentials: &CloudWatchLogsCredentialsConfig{Profile: "prod"},
+		}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "eu-west-1", cfg.Region)
+
+		creds, err := cfg.Credentials.Retrieve(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "AKIAPROD", creds.AccessKeyID)
+		assert.Equal(t, "prod-secret", creds.SecretAccessKey)
+	})
+
+	t.Run("region overrides profile", func(t *testing.T) {
+		cfg, err := loadConfig(context.Background(), &CloudWatchLogsProviderConfig{
+			Region:      "us-east-1",
+			Credentials: &CloudWatchLogsCredentialsConfig{Profile: "prod"},
+		}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "us-east-1", cfg.Region)
+	})
+
+	t.Run("assume role", func(t *testing.T) {
+		cfg, err := loadConfig(context.Background(), &CloudWatchLogsProviderConfig{
+			Region: "us-east-1",
+			Credentials: &CloudWatchLogsCredentialsConfig{
+				RoleArn:    "arn:aws:iam::123456789012:role/audit",
+				ExternalId: "ext",
+			},
+		}, nil)
+		assert.NoError(t, err)
+		cache, ok := cfg.Credentials.(*aws.CredentialsCache)
+		assert.True(t, ok)
+		assert.True(t, cache.IsCredentialsProvider(&stscreds.AssumeRoleProvider{}))
+	})
+
+	t.Run("web identity", func(t *testing.T) {
+		cfg, err := loadConfig(context.Background(), &CloudWatchLogsProviderConfig{
+			Region: "us-east-1",
+			Credentials: &CloudWatchLogsCredentialsConfig{
+				RoleArn:              "arn:aws:iam::123456789012:role/audit",
+				WebIdentityTokenFile: filepath.Join(dir, "token"),
+			},
+		}, nil)
+		assert.NoError(t, err)
+		cache, ok := cfg.Credentials.(*aws.CredentialsCache)
+		assert.True(t, ok)
+		assert.True(t, cache.IsCredentialsProvider(&stscreds.WebIdentityRoleProvider{}))
+	})
+
+	t.Run("transport", func(t *testing.T) {
+		cfg, err := loadConfig(context.Background(), &CloudWatchLogsProviderConfig{
+			Region: "us-gov-west-1",
+		}, &transport.Config{Proxy: "http://proxy.example.com:3128"})
+		assert.NoError(t, err)
+		client, ok := cfg.HTTPClient.(*awshttp.BuildableClient)
+		assert.True(t, ok)
+		proxy, err := client.GetTransport().Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "logs.us-gov-west-1.amazonaws.com"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "http://proxy.example.com:3128", proxy.String())
+	})
+
+	t.Run("missing profile", func(t *testing.T) {
+		_, err := loadConfig(context.Background(), &CloudWatchLogsProviderConfig{
+			Credentials: &CloudWatchLogsCredentialsConfig{Profile: "missing"},
+		}, nil)
+		assert.ErrorContains(t, err, "unable to load SDK config")
+	})
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"slices"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The filters of types.QueryAuditLogParams.
+const (
+	FilterUser          = "user"
+	FilterNamespace     = "namespace"
+	FilterVerbs         = "verbs"
+	FilterResourceTypes = "resource_types"
+	FilterResourceName  = "resource_name"
+	FilterAuditID       = "audit_id"
+)
+
+// AllFilters are all the filters of types.QueryAuditLogParams.
+var AllFilters = []string{FilterUser, FilterNamespace, FilterVerbs, FilterResourceTypes, FilterResourceName, FilterAuditID}
+
+// The sources of the fields of the audit events of a provider.
+const (
+	// FieldRecorded means that the field is recorded by kube-apiserver,
+	// subject to the audit policy.
+	FieldRecorded = "recorded"
+	// FieldInferred means that the field is inferred by the provider from
+	// other fields, e.g. the response status of GKE audit logs.
+	FieldInferred = "inferred"
+	// FieldPartial means that only some events have the field, e.g. when it
+	// depends on the logging settings of the cloud.
+	FieldPartial = "partial"
+	// FieldUnavailable means that no event has the field.
+	FieldUnavailable = "unavailable"
+)
+
+// Capabilities describes the features of a provider, so that the agent
+// knows how far it can trust the results.
+type Capabilities struct {
+	// Filters are the filters that are applied by the backend.
+	Filters []string `json:"filters"`
+	// ClientSideFilters are the filters that are applied to the events
+	// returned by the backend, so a query may return fewer events than its
+	// limit. The other filters are ignored.
+	ClientSideFilters []string `json:"client_side_filters,omitempty"`
+	// ResponseStatus and Bodies are the sources of the response status,
+	// and of the request and response objects of the events.
+	ResponseStatus string `json:"response_status,omitempty"`
+	Bodies         string `json:"bodies,omitempty"`
+	// MaxTimeRange is the maximum time window of a query, 0 means no limit.
+	MaxTimeRange metav1.Duration `json:"max_time_range,omitzero"`
+	// MaxEvents is the maximum number of events a query returns, whatever
+	// its limit, 0 means no limit.
+	MaxEvents int `json:"max_events,omitempty"`
+	// Pagination is true when the provider can return the events after the
+	// first page of a query.
+	Pagination bool `json:"pagination"`
+	// Notes are other limitations of the provider.
+	Notes []string `json:"notes,omitempty"`
+}
+
+// The ways a provider applies a filter.
+const (
+	FilterAppliedByBackend  = "backend"
+	FilterAppliedClientSide = "client-side"
+	FilterIgnored           = "ignored"
+)
+
+// FilterSupport returns how the filter is applied.
+func (c Capabilities) FilterSupport(filter string) string {
+	switch {
+	case slices.Contains(c.Filters, filter):
+		return FilterAppliedByBackend
+	case slices.Contains(c.ClientSideFilters, filter):
+		return FilterAppliedClientSide
+	default:
+		return FilterIgnored
+	}
+}
+
+// RequestedFilters returns the filters that are set in params.
+func RequestedFilters(params types.QueryAuditLogParams) []string {
+	var filters []string
+	if params.User != "" && params.User != "*" {
+		filters = append(filters, FilterUser)
+	}
+	if params.Namespace != "" && params.Namespace != "*" {
+		filters = append(filters, FilterNamespace)
+	}
+	if len(params.Verbs) > 0 {
+		filters = append(filters, FilterVerbs)
+	}
+	if len(params.ResourceTypes) > 0 {
+		filters = append(filters, FilterResourceTypes)
+	}
+	if params.ResourceName != "" && params.ResourceName != "*" {
+		filters = append(filters, FilterResourceName)
+	}
+	if params.AuditID != "" {
+		filters = append(filters, FilterAuditID)
+	}
+	return filters
+}
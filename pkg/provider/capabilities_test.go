@@ -0,0 +1,30 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities_FilterSupport(t *testing.T) {
+	c := Capabilities{
+		Filters:           []string{FilterUser, FilterNamespace},
+		ClientSideFilters: []string{FilterVerbs},
+	}
+	assert.Equal(t, FilterAppliedByBackend, c.FilterSupport(FilterUser))
+	assert.Equal(t, FilterAppliedClientSide, c.FilterSupport(FilterVerbs))
+	assert.Equal(t, FilterIgnored, c.FilterSupport(FilterResourceName))
+}
+
+func TestRequestedFilters(t *testing.T) {
+	assert.Empty(t, RequestedFilters(types.QueryAuditLogParams{User: "*", Namespace: "*"}))
+	assert.Equal(t, AllFilters, RequestedFilters(types.QueryAuditLogParams{
+		User:          "alice",
+		Namespace:     "default",
+		Verbs:         []string{"get"},
+		ResourceTypes: []string{"pods"},
+		ResourceName:  "nginx",
+		AuditID:       "a1",
+	}))
+}
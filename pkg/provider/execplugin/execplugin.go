@@ -0,0 +1,324 @@
+// Package execplugin implements the exec-plugin provider, which queries the
+// audit logs of a plugin executable, see package plugin for the protocol.
+package execplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/plugin"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const ExecPluginProviderName = "exec-plugin"
+
+const defaultTimeout = time.Minute
+
+// Variables for mocking in tests
+var (
+	restartBaseDelay = 500 * time.Millisecond
+	restartMaxDelay  = 30 * time.Second
+)
+
+type ExecPluginProviderConfig struct {
+	// Command is the plugin executable, it is searched in PATH when it
+	// contains no path separator.
+	Command string   `yaml:"command" json:"command"`
+	Args    []string `yaml:"args,omitempty" json:"args,omitempty"`
+	// Env are the env vars of the plugin, in addition to the env vars of
+	// the server.
+	Env map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	// Config is sent to the plugin in the Init call.
+	Config map[string]any `yaml:"config,omitempty" json:"config,omitempty"`
+	// Timeout is the timeout of each call to the plugin, defaults to 1m.
+	Timeout metav1.Duration `yaml:"timeout,omitempty" json:"timeout,omitzero"`
+}
+
+func init() {
+	provider.Register(provider.Registration{
+		Name:         ExecPluginProviderName,
+		DecodeConfig: provider.DecodeConfig[ExecPluginProviderConfig],
+		New: func(config any, transportConfig *transport.Config) (provider.Provider, error) {
+			return NewExecPluginProvider(config.(*ExecPluginProviderConfig), transportConfig)
+		},
+	})
+}
+
+func (c *ExecPluginProviderConfig) Init() error {
+	if c.Command == "" {
+		return errors.New("command is required")
+	}
+	if c.Timeout.Duration < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	if c.Timeout.Duration == 0 {
+		c.Timeout.Duration = defaultTimeout
+	}
+	return nil
+}
+
+// ExecPluginProvider queries the audit logs of a plugin. The plugin is
+// started by NewExecPluginProvider, restarted with backoff when it exits,
+// and stopped by Close, or when the provider is garbage collected.
+type ExecPluginProvider struct {
+	s *supervisor
+}
+
+var (
+	_ provider.Provider = (*ExecPluginProvider)(nil)
+	_ io.Closer         = (*ExecPluginProvider)(nil)
+)
+
+var errClosed = errors.New("the provider is closed")
+
+// supervisor runs the plugin, it is separated from ExecPluginProvider so
+// that the provider can be garbage collected while the plugin is running.
+type supervisor struct {
+	config *ExecPluginProviderConfig
+	env    []string
+
+	mu           sync.Mutex
+	proc         *process
+	capabilities provider.Capabilities
+	restarts     int
+	startedAt    time.Time
+	// starting is closed when the plugin that is being (re)started is
+	// started, it is nil when no plugin is being started.
+	starting chan struct{}
+	closed   bool
+	// queries are the in-flight queries, which Close waits for.
+	queries sync.WaitGroup
+}
+
+func NewExecPluginProvider(config *ExecPluginProviderConfig, transportConfig *transport.Config) (*ExecPluginProvider, error) {
+	if err := config.Init(); err != nil {
+		return nil, fmt.Errorf("invalid %s provider config: %w", ExecPluginProviderName, err)
+	}
+
+	s := &supervisor{config: config}
+	for _, name := range sortedKeys(config.Env) {
+		s.env = append(s.env, name+"="+config.Env[name])
+	}
+	if transportConfig != nil && transportConfig.Proxy != "" {
+		s.env = append(s.env, "HTTP_PROXY="+transportConfig.Proxy, "HTTPS_PROXY="+transportConfig.Proxy)
+	}
+
+	if _, err := s.process(context.Background()); err != nil {
+		return nil, err
+	}
+	p := &ExecPluginProvider{s: s}
+	runtime.AddCleanup(p, func(s *supervisor) { s.stop() }, s)
+	return p, nil
+}
+
+func (e *ExecPluginProvider) QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	if err := e.s.acquire(); err != nil {
+		return types.AuditLogResult{}, err
+	}
+	defer e.s.queries.Done()
+
+	ctx, cancel := context.WithTimeout(ctx, e.s.config.Timeout.Duration)
+	defer cancel()
+
+	proc, err := e.s.process(ctx)
+	if err != nil {
+		return types.AuditLogResult{}, err
+	}
+
+	queryParams := plugin.NewQueryParams(params)
+	queryParams.MaxBytesScanned = provider.MaxBytesScanned(ctx)
+	var result plugin.QueryResult
+	if err := proc.call(ctx, plugin.MethodQueryAuditLog, queryParams, &result); err != nil {
+		return types.AuditLogResult{}, e.s.callError(ctx, err)
+	}
+	e.s.resetRestarts()
+
+	result.AuditLogResult.ProviderQuery = result.ProviderQuery
+	result.AuditLogResult.Params = params
+	if result.ProviderQuery != "" {
+		log.Printf("query: %s", result.ProviderQuery)
+	}
+	return result.AuditLogResult, nil
+}
+
+// Capabilities returns the capabilities that the plugin reported when it
+// was started.
+func (e *ExecPluginProvider) Capabilities() provider.Capabilities {
+	e.s.mu.Lock()
+	defer e.s.mu.Unlock()
+	return e.s.capabilities
+}
+
+// Close stops the plugin after the in-flight queries are done, the queries
+// after Close fail.
+func (e *ExecPluginProvider) Close() error {
+	e.s.mu.Lock()
+	e.s.closed = true
+	e.s.mu.Unlock()
+
+	e.s.queries.Wait()
+	e.s.stop()
+	return nil
+}
+
+// acquire adds a query to the in-flight queries, it fails when the provider
+// is closed.
+func (s *supervisor) acquire() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("plugin %s: %w", s.config.Command, errClosed)
+	}
+	s.queries.Add(1)
+	return nil
+}
+
+// process returns the running plugin, it (re)starts the plugin when it is
+// not running. Restarts are delayed with exponential backoff until a query
+// succeeds. The lock is not held while the plugin is (re)started, so that
+// Capabilities does not wait for the backoff, the concurrent calls wait for
+// the plugin that is being started.
+func (s *supervisor) process(ctx context.Context) (*process, error) {
+	s.mu.Lock()
+	for s.starting != nil {
+		starting := s.starting
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-starting:
+		}
+		s.mu.Lock()
+	}
+	if s.proc != nil && !s.proc.exited() {
+		proc := s.proc
+		s.mu.Unlock()
+		return proc, nil
+	}
+
+	exited := s.proc
+	var wait time.Duration
+	if exited != nil {
+		s.restarts++
+		delay := min(restartBaseDelay<<(s.restarts-1), restartMaxDelay)
+		wait = time.Until(s.startedAt.Add(delay))
+	}
+	starting := make(chan struct{})
+	s.starting = starting
+	s.mu.Unlock()
+
+	proc, err := s.restart(ctx, exited, wait)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.starting = nil
+	close(starting)
+	if err != nil {
+		return nil, err
+	}
+	if s.closed {
+		go proc.stop()
+		return nil, fmt.Errorf("plugin %s: %w", s.config.Command, errClosed)
+	}
+	s.proc = proc
+	return proc, nil
+}
+
+// restart starts the plugin after waiting for the backoff when the plugin
+// exited.
+func (s *supervisor) restart(ctx context.Context, exited *process, wait time.Duration) (*process, error) {
+	if exited != nil {
+		if wait > 0 {
+			provider.ReportStatus(ctx, provider.StatusWarning, "%v, restarting it in %s", exited.err, wait.Round(time.Millisecond))
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, fmt.Errorf("%w, not restarted: %w", exited.err, ctx.Err())
+			case <-timer.C:
+			}
+		} else {
+			provider.ReportStatus(ctx, provider.StatusWarning, "%v, restarting it", exited.err)
+		}
+	}
+
+	s.mu.Lock()
+	s.startedAt = time.Now()
+	s.mu.Unlock()
+	return s.start(ctx)
+}
+
+// start starts the plugin, and calls Init and Capabilities.
+func (s *supervisor) start(ctx context.Context) (*process, error) {
+	name := s.config.Command
+	proc, err := startProcess(name, s.config.Command, s.config.Args, s.env)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout.Duration)
+	defer cancel()
+	var initResult plugin.InitResult
+	err = proc.call(ctx, plugin.MethodInit, plugin.InitParams{
+		ProtocolVersion: plugin.ProtocolVersion,
+		Config:          s.config.Config,
+	}, &initResult)
+	if err == nil && initResult.ProtocolVersion != plugin.ProtocolVersion {
+		err = fmt.Errorf("unsupported protocol version %d, expected %d", initResult.ProtocolVersion, plugin.ProtocolVersion)
+	}
+	var capabilities provider.Capabilities
+	if err == nil {
+		err = proc.call(ctx, plugin.MethodCapabilities, nil, &capabilities)
+	}
+	if err != nil {
+		go proc.stop()
+		return nil, fmt.Errorf("init plugin %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	s.capabilities = capabilities
+	s.mu.Unlock()
+	return proc, nil
+}
+
+// callError adds the timeout to the error of a call that timed out.
+func (s *supervisor) callError(ctx context.Context, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("plugin %s did not respond in %s: %w", s.config.Command, s.config.Timeout.Duration, err)
+	}
+	return err
+}
+
+func (s *supervisor) resetRestarts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restarts = 0
+}
+
+func (s *supervisor) stop() {
+	s.mu.Lock()
+	proc := s.proc
+	s.mu.Unlock()
+	if proc != nil {
+		proc.stop()
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
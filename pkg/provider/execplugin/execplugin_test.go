@@ -0,0 +1,305 @@
+package execplugin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/plugin"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testPluginEnv makes the test binary serve testPlugin instead of running
+// the tests, so that the tests can use a plugin that crashes or hangs.
+const testPluginEnv = "EXECPLUGIN_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testPluginEnv) != "" {
+		if err := plugin.Serve(&testPlugin{}); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testPlugin returns an event for each query, and crashes, sleeps or hangs
+// when the user of the query is crash, sleep or hang. It reports progress
+// and a status message when the user is progress.
+type testPlugin struct{}
+
+func (testPlugin) Init(_ context.Context, config map[string]any) error {
+	if config["fail"] == true {
+		return errors.New("invalid config")
+	}
+	return nil
+}
+
+func (testPlugin) Capabilities() provider.Capabilities {
+	return provider.Capabilities{Filters: []string{provider.FilterUser}}
+}
+
+func (testPlugin) QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	switch params.User {
+	case "crash":
+		os.Exit(2)
+	case "sleep":
+		<-ctx.Done()
+		return types.AuditLogResult{}, ctx.Err()
+	case "hang":
+		select {}
+	case "progress":
+		provider.ReportProgress(ctx, provider.Progress{Message: "scanning", RecordsScanned: 100, RecordsMatched: 1})
+		provider.ReportStatus(ctx, provider.StatusWarning, "throttled")
+	}
+	return types.AuditLogResult{Entries: []types.AuditLogEntry{{AuditID: "1"}}, Total: 1}, nil
+}
+
+func newTestProvider(t *testing.T, config map[string]any) *ExecPluginProvider {
+	t.Helper()
+	p, err := NewExecPluginProvider(&ExecPluginProviderConfig{
+		Command: os.Args[0],
+		Env:     map[string]string{testPluginEnv: "1"},
+		Config:  config,
+		Timeout: metav1.Duration{Duration: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.s.stop)
+	return p
+}
+
+func buildFilePlugin(t *testing.T) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "file-plugin")
+	out, err := exec.Command("go", "build", "-o", bin, "github.com/mozillazg/kube-audit-mcp/examples/file-plugin").CombinedOutput()
+	if err != nil {
+		t.Fatalf("build file-plugin: %v: %s", err, out)
+	}
+	return bin
+}
+
+func TestExecPluginProvider_FilePlugin(t *testing.T) {
+	bin := buildFilePlugin(t)
+	file, err := filepath.Abs("testdata/audit.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewExecPluginProvider(&ExecPluginProviderConfig{
+		Command: bin,
+		Config:  map[string]any{"file": file},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.s.stop)
+	assert.Equal(t, provider.AllFilters, p.Capabilities().Filters)
+
+	start := time.Date(2025, 9, 20, 0, 0, 0, 0, time.UTC)
+	params := types.QueryAuditLogParams{
+		StartTime: types.NewTimeParam(start),
+		EndTime:   types.NewTimeParam(start.Add(24 * time.Hour)),
+		User:      "alice",
+		Limit:     10,
+	}
+	result, err := p.QueryAuditLog(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Len(t, result.Entries, 2) {
+		return
+	}
+	assert.Equal(t, "a3", string(result.Entries[0].AuditID))
+	assert.Equal(t, "kube-system", result.Entries[0].ObjectRef.Namespace)
+	assert.Equal(t, "a1", string(result.Entries[1].AuditID))
+	assert.Equal(t, "scan "+file, result.ProviderQuery)
+	assert.Equal(t, int64(3), result.Stats.RecordsScanned)
+
+	params.User = ""
+	params.ResourceTypes = []string{"secrets"}
+	result, err = p.QueryAuditLog(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Len(t, result.Entries, 1) {
+		return
+	}
+	assert.Equal(t, "db", result.Entries[0].ObjectRef.Name)
+
+	_, err = p.QueryAuditLog(provider.WithMaxBytesScanned(context.Background(), 100), params)
+	var scanErr *provider.ScanLimitError
+	if !assert.ErrorAs(t, err, &scanErr) {
+		return
+	}
+	assert.Equal(t, int64(100), scanErr.Limit)
+
+	_, err = NewExecPluginProvider(&ExecPluginProviderConfig{
+		Command: bin,
+		Config:  map[string]any{"file": filepath.Join(t.TempDir(), "missing.log")},
+	}, nil)
+	assert.ErrorContains(t, err, "init plugin "+bin)
+	assert.ErrorContains(t, err, "no such file or directory")
+}
+
+type recordingReporter struct {
+	progress []provider.Progress
+	status   []string
+}
+
+func (r *recordingReporter) Progress(progress provider.Progress) {
+	r.progress = append(r.progress, progress)
+}
+
+func (r *recordingReporter) Status(level, message string) {
+	r.status = append(r.status, level+": "+message)
+}
+
+func TestExecPluginProvider_Progress(t *testing.T) {
+	p := newTestProvider(t, nil)
+
+	r := &recordingReporter{}
+	_, err := p.QueryAuditLog(provider.WithReporter(context.Background(), r), types.QueryAuditLogParams{User: "progress"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []provider.Progress{{Message: "scanning", RecordsScanned: 100, RecordsMatched: 1}}, r.progress)
+	assert.Equal(t, []string{"warning: plugin " + os.Args[0] + ": throttled"}, r.status)
+}
+
+func TestExecPluginProvider_Restart(t *testing.T) {
+	defer mockVar(&restartBaseDelay, 10*time.Millisecond)()
+
+	p := newTestProvider(t, nil)
+	pid := p.s.proc.cmd.Process.Pid
+
+	_, err := p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{User: "crash"})
+	assert.ErrorContains(t, err, "exited: exit status 2")
+
+	result, err := p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, result.Total)
+	assert.NotEqual(t, pid, p.s.proc.cmd.Process.Pid)
+	assert.Equal(t, []string{provider.FilterUser}, p.Capabilities().Filters)
+	assert.Equal(t, 0, p.s.restarts)
+}
+
+func TestExecPluginProvider_RestartBackoff(t *testing.T) {
+	defer mockVar(&restartBaseDelay, 500*time.Millisecond)()
+
+	p := newTestProvider(t, nil)
+	_, err := p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{User: "crash"})
+	assert.ErrorContains(t, err, "exited: exit status 2")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+		done <- err
+	}()
+	// Capabilities does not wait for the backoff of the restart.
+	time.Sleep(50 * time.Millisecond)
+	capabilities := make(chan provider.Capabilities, 1)
+	go func() { capabilities <- p.Capabilities() }()
+	select {
+	case c := <-capabilities:
+		assert.Equal(t, []string{provider.FilterUser}, c.Filters)
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("expected Capabilities not to wait for the restart")
+	}
+	// A concurrent query waits for the plugin that is being restarted.
+	_, err = p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+	assert.NoError(t, err)
+	assert.NoError(t, <-done)
+	assert.Equal(t, 0, p.s.restarts)
+}
+
+func TestExecPluginProvider_Close(t *testing.T) {
+	p := newTestProvider(t, nil)
+	proc := p.s.proc
+
+	assert.NoError(t, p.Close())
+	select {
+	case <-proc.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the plugin to be stopped")
+	}
+	_, err := p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+	assert.ErrorIs(t, err, errClosed)
+	assert.NoError(t, provider.Close(p))
+}
+
+func TestExecPluginProvider_Timeout(t *testing.T) {
+	defer mockVar(&cancelGracePeriod, 50*time.Millisecond)()
+	defer mockVar(&restartBaseDelay, 10*time.Millisecond)()
+
+	p := newTestProvider(t, nil)
+	p.s.config.Timeout.Duration = 100 * time.Millisecond
+	pid := p.s.proc.cmd.Process.Pid
+
+	// The plugin answers the canceled request, so it keeps running.
+	_, err := p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{User: "sleep"})
+	assert.ErrorContains(t, err, "did not respond in 100ms")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assertNoPendingCalls(t, p.s.proc)
+	_, err = p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, pid, p.s.proc.cmd.Process.Pid)
+
+	// The plugin ignores the canceled request, so it is killed and restarted.
+	_, err = p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{User: "hang"})
+	assert.ErrorContains(t, err, "did not respond in 100ms")
+	select {
+	case <-p.s.proc.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the plugin to be killed")
+	}
+	assertNoPendingCalls(t, p.s.proc)
+	_, err = p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, pid, p.s.proc.cmd.Process.Pid)
+}
+
+func assertNoPendingCalls(t *testing.T, proc *process) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		proc.mu.Lock()
+		defer proc.mu.Unlock()
+		return len(proc.pending) == 0
+	}, 5*time.Second, 10*time.Millisecond, "expected the canceled call to be removed from the pending calls")
+}
+
+func TestNewExecPluginProvider_Errors(t *testing.T) {
+	_, err := NewExecPluginProvider(&ExecPluginProviderConfig{}, nil)
+	assert.ErrorContains(t, err, "command is required")
+
+	_, err = NewExecPluginProvider(&ExecPluginProviderConfig{Command: filepath.Join(t.TempDir(), "missing")}, nil)
+	assert.ErrorContains(t, err, "start plugin")
+
+	_, err = NewExecPluginProvider(&ExecPluginProviderConfig{
+		Command: os.Args[0],
+		Env:     map[string]string{testPluginEnv: "1"},
+		Config:  map[string]any{"fail": true},
+	}, nil)
+	assert.ErrorContains(t, err, "init plugin")
+	assert.ErrorContains(t, err, "invalid config")
+}
+
+func mockVar[T any](v *T, value T) func() {
+	old := *v
+	*v = value
+	return func() { *v = old }
+}
@@ -0,0 +1,279 @@
+package execplugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/plugin"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+)
+
+// Variables for mocking in tests
+var (
+	// cancelGracePeriod is how long a plugin has to answer a canceled
+	// request before it is killed.
+	cancelGracePeriod = 5 * time.Second
+	// stopGracePeriod is how long a plugin has to exit after its stdin is
+	// closed before it is killed.
+	stopGracePeriod = 5 * time.Second
+)
+
+// process is a running plugin and the JSON-RPC client of it.
+type process struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+	mu      sync.Mutex
+	pending map[int64]*pendingCall
+
+	// done is closed when the process exited, err is the reason.
+	done chan struct{}
+	err  error
+
+	// The grace periods are read when the process starts, so that the
+	// goroutines of the process do not read the package variables.
+	cancelGracePeriod time.Duration
+	stopGracePeriod   time.Duration
+}
+
+func startProcess(name string, command string, args []string, env []string) (*process, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start plugin %s: %w", name, err)
+	}
+
+	p := &process{
+		name:    name,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: map[int64]*pendingCall{},
+		done:    make(chan struct{}),
+
+		cancelGracePeriod: cancelGracePeriod,
+		stopGracePeriod:   stopGracePeriod,
+	}
+	go p.readResponses(stdout)
+	return p, nil
+}
+
+// pendingCall is a request that is waiting for its response, ctx is the
+// context of the call, the notifications of the request are reported to it.
+type pendingCall struct {
+	ctx context.Context
+	ch  chan plugin.Response
+}
+
+// message is a response or a notification of the plugin.
+type message struct {
+	plugin.Response
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+func (p *process) readResponses(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var msg message
+			if err := json.Unmarshal(line, &msg); err != nil || (msg.ID == nil && msg.Method == "") {
+				log.Printf("plugin %s: invalid response %q", p.name, line)
+			} else if msg.Method != "" {
+				p.handleNotification(msg.Method, msg.Params)
+			} else {
+				p.mu.Lock()
+				call, ok := p.pending[*msg.ID]
+				delete(p.pending, *msg.ID)
+				p.mu.Unlock()
+				if ok {
+					call.ch <- msg.Response
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// Unblock the plugin if it is still writing, and reap it.
+	_, _ = io.Copy(io.Discard, stdout)
+	err := p.cmd.Wait()
+	if err == nil {
+		err = errors.New("exit status 0")
+	}
+	p.err = fmt.Errorf("plugin %s exited: %w", p.name, err)
+	close(p.done)
+}
+
+// handleNotification reports the progress and the status notifications of
+// the plugin to the context of their request. Notifications of requests
+// that are no longer pending and unknown notifications are ignored.
+func (p *process) handleNotification(method string, params json.RawMessage) {
+	switch method {
+	case plugin.MethodProgress:
+		var progress plugin.ProgressParams
+		if err := json.Unmarshal(params, &progress); err != nil {
+			return
+		}
+		if ctx := p.pendingContext(progress.ID); ctx != nil {
+			provider.ReportProgress(ctx, progress.Progress)
+		}
+	case plugin.MethodStatus:
+		var status plugin.StatusParams
+		if err := json.Unmarshal(params, &status); err != nil {
+			return
+		}
+		if ctx := p.pendingContext(status.ID); ctx != nil {
+			provider.ReportStatus(ctx, status.Level, "plugin %s: %s", p.name, status.Message)
+		}
+	}
+}
+
+func (p *process) pendingContext(id int64) context.Context {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if call, ok := p.pending[id]; ok {
+		return call.ctx
+	}
+	return nil
+}
+
+// exited returns whether the process exited.
+func (p *process) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// call calls the method of the plugin and decodes the result to result.
+// When ctx is done, the request is canceled, and the plugin is killed if it
+// does not answer the canceled request in cancelGracePeriod.
+func (p *process) call(ctx context.Context, method string, params, result any) error {
+	id := p.nextID.Add(1)
+	ch := make(chan plugin.Response, 1)
+	p.mu.Lock()
+	p.pending[id] = &pendingCall{ctx: ctx, ch: ch}
+	p.mu.Unlock()
+
+	if err := p.send(plugin.Request{ID: &id, Method: method}, params); err != nil {
+		p.forget(id)
+		if p.exited() {
+			return p.err
+		}
+		return fmt.Errorf("send request to plugin %s: %w", p.name, err)
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return responseError(resp.Error)
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("invalid %s result of plugin %s: %w", method, p.name, err)
+		}
+		return nil
+	case <-p.done:
+		p.forget(id)
+		return p.err
+	case <-ctx.Done():
+		_ = p.send(plugin.Request{Method: plugin.MethodCancel}, plugin.CancelParams{ID: id})
+		go p.awaitCanceled(id, ch)
+		return ctx.Err()
+	}
+}
+
+// awaitCanceled waits for the response of the canceled request, which is
+// pending until then, so that a late response does not kill the plugin.
+func (p *process) awaitCanceled(id int64, ch chan plugin.Response) {
+	defer p.forget(id)
+	timer := time.NewTimer(p.cancelGracePeriod)
+	defer timer.Stop()
+	select {
+	case <-ch:
+	case <-p.done:
+	case <-timer.C:
+		log.Printf("plugin %s did not answer a canceled request in %s, killing it", p.name, p.cancelGracePeriod)
+		_ = p.cmd.Process.Kill()
+	}
+}
+
+// forget removes the request from the pending requests, the response of
+// the request is ignored if it arrives later.
+func (p *process) forget(id int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, id)
+}
+
+func (p *process) send(req plugin.Request, params any) error {
+	req.JSONRPC = "2.0"
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = data
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, err = p.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// stop closes the stdin of the plugin, and kills it when it does not exit
+// in stopGracePeriod.
+func (p *process) stop() {
+	_ = p.stdin.Close()
+	timer := time.NewTimer(p.stopGracePeriod)
+	defer timer.Stop()
+	select {
+	case <-p.done:
+	case <-timer.C:
+		_ = p.cmd.Process.Kill()
+	}
+}
+
+func responseError(rpcErr *plugin.Error) error {
+	if rpcErr.Code == plugin.CodeScanLimit {
+		var data plugin.ScanLimitData
+		if err := json.Unmarshal(rpcErr.Data, &data); err == nil {
+			return &provider.ScanLimitError{Limit: data.Limit, Scanned: data.Scanned, Estimated: data.Estimated}
+		}
+	}
+	return rpcErr
+}
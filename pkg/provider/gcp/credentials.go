@@ -0,0 +1,130 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/logging"
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+// cloudPlatformScope is the scope of the credentials that impersonate the
+// service account.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// CloudLoggingCredentialsConfig configures the credentials of a cluster.
+// Application Default Credentials are used when it is not set.
+type CloudLoggingCredentialsConfig struct {
+	// CredentialsFile is the path of a service account key file.
+	CredentialsFile string `yaml:"credentials_file,omitempty" json:"credentials_file,omitempty"`
+
+	// ImpersonateServiceAccount is the email of the service account that is
+	// impersonated with the credentials of CredentialsFile or ADC.
+	ImpersonateServiceAccount string   `yaml:"impersonate_service_account,omitempty" json:"impersonate_service_account,omitempty"`
+	Delegates                 []string `yaml:"delegates,omitempty" json:"delegates,omitempty"`
+}
+
+func (c *CloudLoggingCredentialsConfig) Init() error {
+	if c.CredentialsFile == "" && c.ImpersonateServiceAccount == "" {
+		return errors.New("either credentials_file or impersonate_service_account must be provided")
+	}
+	if c.ImpersonateServiceAccount != "" && !strings.Contains(c.ImpersonateServiceAccount, "@") {
+		return fmt.Errorf("impersonate_service_account %q must be the email of a service account",
+			c.ImpersonateServiceAccount)
+	}
+	if len(c.Delegates) > 0 && c.ImpersonateServiceAccount == "" {
+		return errors.New("impersonate_service_account is required when delegates is set")
+	}
+	return nil
+}
+
+// clientOptions returns the options of the logadmin client that
+// authenticate with the credentials of the cluster. The tokens are fetched
+// through the proxy and with the CA of the transport config, which only
+// applies to the gRPC connection of the logadmin client otherwise.
+func (c *CloudLoggingCredentialsConfig) clientOptions(ctx context.Context, transportConfig *transport.Config) ([]option.ClientOption, error) {
+	httpClient, err := transportConfig.HTTPClient()
+	if err != nil {
+		return nil, fmt.Errorf("invalid transport config: %w", err)
+	}
+	if httpClient != nil {
+		return c.httpClientOptions(ctx, httpClient)
+	}
+	if c == nil {
+		return nil, nil
+	}
+
+	var opts []option.ClientOption
+	if c.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(c.CredentialsFile))
+	}
+	if c.ImpersonateServiceAccount == "" {
+		return opts, nil
+	}
+	return c.impersonate(ctx, opts...)
+}
+
+// httpClientOptions is clientOptions for a custom HTTP client. The token
+// source of the credentials is created here instead of by the SDK, because
+// the SDK does not authenticate the requests of a client passed with
+// option.WithHTTPClient.
+func (c *CloudLoggingCredentialsConfig) httpClientOptions(ctx context.Context, httpClient *http.Client) ([]option.ClientOption, error) {
+	if c == nil || c.ImpersonateServiceAccount == "" {
+		ts, err := c.tokenSource(ctx, httpClient, logging.ReadScope)
+		if err != nil {
+			return nil, err
+		}
+		return []option.ClientOption{option.WithTokenSource(ts)}, nil
+	}
+
+	ts, err := c.tokenSource(ctx, httpClient, cloudPlatformScope)
+	if err != nil {
+		return nil, err
+	}
+	return c.impersonate(ctx, option.WithHTTPClient(&http.Client{
+		Transport: &oauth2.Transport{Source: ts, Base: httpClient.Transport},
+	}))
+}
+
+// tokenSource returns the token source of CredentialsFile or ADC that
+// fetches the tokens with httpClient.
+func (c *CloudLoggingCredentialsConfig) tokenSource(ctx context.Context, httpClient *http.Client, scope string) (oauth2.TokenSource, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+	if c == nil || c.CredentialsFile == "" {
+		creds, err := google.FindDefaultCredentials(ctx, scope)
+		if err != nil {
+			return nil, fmt.Errorf("find default credentials: %w", err)
+		}
+		return creds.TokenSource, nil
+	}
+
+	data, err := os.ReadFile(c.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("read credentials_file: %w", err)
+	}
+	creds, err := google.CredentialsFromJSON(ctx, data, scope)
+	if err != nil {
+		return nil, fmt.Errorf("load credentials_file %s: %w", c.CredentialsFile, err)
+	}
+	return creds.TokenSource, nil
+}
+
+func (c *CloudLoggingCredentialsConfig) impersonate(ctx context.Context, opts ...option.ClientOption) ([]option.ClientOption, error) {
+	ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: c.ImpersonateServiceAccount,
+		Delegates:       c.Delegates,
+		Scopes:          []string{logging.ReadScope},
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("impersonate service account %s: %w", c.ImpersonateServiceAccount, err)
+	}
+	return []option.ClientOption{option.WithTokenSource(ts)}, nil
+}
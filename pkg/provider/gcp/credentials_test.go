@@ -0,0 +1,122 @@
+package gcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudLoggingCredentialsConfig_Init(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      CloudLoggingCredentialsConfig
+		expectedErr string
+	}{
+		{
+			name:   "service account key file",
+			config: CloudLoggingCredentialsConfig{CredentialsFile: "/etc/gcp/key.json"},
+		},
+		{
+			name: "impersonated service account",
+			config: CloudLoggingCredentialsConfig{
+				ImpersonateServiceAccount: "audit@prod.iam.gserviceaccount.com",
+				Delegates:                 []string{"hop@prod.iam.gserviceaccount.com"},
+			},
+		},
+		{
+			name:        "empty",
+			config:      CloudLoggingCredentialsConfig{},
+			expectedErr: "either credentials_file or impersonate_service_account must be provided",
+		},
+		{
+			name:        "invalid service account",
+			config:      CloudLoggingCredentialsConfig{ImpersonateServiceAccount: "audit"},
+			expectedErr: `impersonate_service_account "audit" must be the email of a service account`,
+		},
+		{
+			name: "delegates without impersonation",
+			config: CloudLoggingCredentialsConfig{
+				CredentialsFile: "/etc/gcp/key.json",
+				Delegates:       []string{"hop@prod.iam.gserviceaccount.com"},
+			},
+			expectedErr: "impersonate_service_account is required when delegates is set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Init()
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCloudLoggingCredentialsConfig_clientOptions(t *testing.T) {
+	var config *CloudLoggingCredentialsConfig
+	opts, err := config.clientOptions(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, opts)
+
+	config = &CloudLoggingCredentialsConfig{CredentialsFile: "/etc/gcp/key.json"}
+	opts, err = config.clientOptions(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Len(t, opts, 1)
+}
+
+func TestCloudLoggingCredentialsConfig_tokenSource(t *testing.T) {
+	// The token endpoint is only trusted with the CA of the transport config
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/token", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"token-1","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: server.Certificate().Raw,
+	}), 0600))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	keyFile, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "audit@prod.iam.gserviceaccount.com",
+		"private_key": string(pem.EncodeToMemory(&pem.Block{
+			Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})),
+		"token_uri": server.URL + "/token",
+	})
+	assert.NoError(t, err)
+	credentialsFile := filepath.Join(dir, "key.json")
+	assert.NoError(t, os.WriteFile(credentialsFile, keyFile, 0600))
+
+	httpClient, err := (&transport.Config{CAFile: caFile}).HTTPClient()
+	assert.NoError(t, err)
+	config := &CloudLoggingCredentialsConfig{CredentialsFile: credentialsFile}
+	ts, err := config.tokenSource(context.Background(), httpClient, cloudPlatformScope)
+	assert.NoError(t, err)
+	token, err := ts.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token.AccessToken)
+
+	opts, err := config.clientOptions(context.Background(), &transport.Config{CAFile: caFile})
+	assert.NoError(t, err)
+	assert.Len(t, opts, 1)
+}
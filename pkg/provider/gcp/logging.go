@@ -11,9 +11,13 @@ import (
 	"cloud.google.com/go/logging"
 	"cloud.google.com/go/logging/logadmin"
 	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/ratelimit"
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
 	"github.com/mozillazg/kube-audit-mcp/pkg/types"
 	"google.golang.org/api/iterator"
 	"google.golang.org/genproto/googleapis/cloud/audit"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/structpb"
 	k8sauth "k8s.io/api/authentication/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -24,6 +28,9 @@ import (
 
 const CloudLoggingProviderName = "gcp-cloud-logging"
 
+// progressInterval is the number of entries read between progress reports.
+const progressInterval = 100
+
 type CloudLoggingProvider struct {
 	client cloudLoggingProviderClientInterface
 
@@ -38,17 +45,54 @@ type cloudLoggingProviderClientInterface interface {
 type CloudLoggingProviderConfig struct {
 	ProjectId   string `json:"project_id" yaml:"project_id"`
 	ClusterName string `json:"cluster_name" yaml:"cluster_name"`
+
+	Credentials *CloudLoggingCredentialsConfig `json:"credentials,omitempty" yaml:"credentials,omitempty"`
 }
 
 var _ provider.Provider = (*CloudLoggingProvider)(nil)
 
-func NewCloudLoggingProvider(config *CloudLoggingProviderConfig) (*CloudLoggingProvider, error) {
+var cloudLoggingCapabilities = provider.Capabilities{
+	Filters:        provider.AllFilters,
+	ResponseStatus: provider.FieldInferred,
+	Bodies:         provider.FieldPartial,
+	Notes: []string{
+		"The user filter matches a substring of the principal email, user groups are not logged.",
+		"The response status is inferred from the verb, e.g. \"OK (inferred)\", so failed requests are not distinguishable.",
+		"Request and response objects are only logged when Data Access audit logs are enabled.",
+	},
+}
+
+func init() {
+	provider.Register(provider.Registration{
+		Name:         CloudLoggingProviderName,
+		ConfigKey:    "gcp_cloud_logging",
+		DecodeConfig: provider.DecodeConfig[CloudLoggingProviderConfig],
+		New: func(config any, transportConfig *transport.Config) (provider.Provider, error) {
+			return NewCloudLoggingProvider(config.(*CloudLoggingProviderConfig), transportConfig)
+		},
+		SampleConfig: &CloudLoggingProviderConfig{
+			ProjectId: "test-233xxx",
+		},
+		SampleCluster: "test",
+	})
+}
+
+func NewCloudLoggingProvider(config *CloudLoggingProviderConfig, transportConfig *transport.Config) (*CloudLoggingProvider, error) {
 	if err := config.Init(); err != nil {
 		return nil, fmt.Errorf("invalid %s provider config: %w", CloudLoggingProviderName, err)
 	}
 
+	opts, err := config.Credentials.clientOptions(context.TODO(), transportConfig)
+	if err != nil {
+		return nil, err
+	}
+	transportOpts, err := transportClientOptions(transportConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transport config: %w", err)
+	}
+	opts = append(opts, transportOpts...)
 	client, err := logadmin.NewClient(context.TODO(),
-		fmt.Sprintf("projects/%s", config.ProjectId))
+		fmt.Sprintf("projects/%s", config.ProjectId), opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cloud logging client: %w", err)
 	}
@@ -60,6 +104,10 @@ func NewCloudLoggingProvider(config *CloudLoggingProviderConfig) (*CloudLoggingP
 	}, nil
 }
 
+func (c *CloudLoggingProvider) Capabilities() provider.Capabilities {
+	return cloudLoggingCapabilities
+}
+
 func (c *CloudLoggingProvider) QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
 	var result types.AuditLogResult
 	query := c.buildQuery(params)
@@ -132,12 +180,29 @@ func (c *CloudLoggingProvider) buildQuery(params types.QueryAuditLogParams) stri
 		query += fmt.Sprintf(" AND protoPayload.resourceName =~ %q", keyword)
 	}
 
+	// The audit ID of the events is the insert ID of the log entries.
+	if params.AuditID != "" {
+		query += fmt.Sprintf(" AND insertId=%q", params.AuditID)
+	}
+
 	return query
 }
 
+// queryLogs reads the entries of the query, the iterator cannot be resumed
+// after an error, so the whole query is retried when it is throttled.
 func (c *CloudLoggingProvider) queryLogs(ctx context.Context, params types.QueryAuditLogParams, query string) ([]*logging.Entry, error) {
+	var entries []*logging.Entry
+	err := ratelimit.Do(ctx, isThrottlingError, func() (err error) {
+		entries, err = c.readEntries(ctx, params, query)
+		return err
+	})
+	return entries, err
+}
+
+func (c *CloudLoggingProvider) readEntries(ctx context.Context, params types.QueryAuditLogParams, query string) ([]*logging.Entry, error) {
 	var entries = make([]*logging.Entry, 0, params.Limit)
 	iter := c.client.Entries(ctx, logadmin.Filter(query), logadmin.NewestFirst())
+	provider.ReportProgress(ctx, provider.Progress{Message: "reading log entries"})
 
 	for len(entries) < params.Limit {
 		entry, err := iter.Next()
@@ -148,10 +213,26 @@ func (c *CloudLoggingProvider) queryLogs(ctx context.Context, params types.Query
 			return nil, err
 		}
 		entries = append(entries, entry)
+		if len(entries)%progressInterval == 0 {
+			provider.ReportProgress(ctx, provider.Progress{
+				Message:        "reading log entries",
+				RecordsMatched: int64(len(entries)),
+			})
+		}
 	}
 	return entries, nil
 }
 
+// isThrottlingError reports whether the request was rejected because of the
+// read quota of the Logging API, or because the service is unavailable.
+func isThrottlingError(err error) bool {
+	switch status.Code(err) {
+	case codes.ResourceExhausted, codes.Unavailable:
+		return true
+	}
+	return false
+}
+
 func (c *CloudLoggingProvider) convertLogToK8sAudit(logEntry logging.Entry) (k8saudit.Event, error) {
 	var event k8saudit.Event
 	var err error
@@ -227,6 +308,11 @@ func (c *CloudLoggingProviderConfig) Init() error {
 	if c.ProjectId == "" {
 		return errors.New("project_id is required")
 	}
+	if c.Credentials != nil {
+		if err := c.Credentials.Init(); err != nil {
+			return fmt.Errorf("invalid credentials: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -1,9 +1,13 @@
 package gcp
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/structpb"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8saudit "k8s.io/apiserver/pkg/apis/audit"
@@ -172,6 +176,16 @@ func TestCloudLoggingProvider_buildQuery(t *testing.T) {
 			},
 			want: `resource.type="k8s_cluster" AND logName="projects/test-project/logs/cloudaudit.googleapis.com%2Factivity"`,
 		},
+		{
+			name: "query with audit id should match the insert id",
+			fields: fields{
+				projectId: "test-project",
+			},
+			params: types.QueryAuditLogParams{
+				AuditID: "abc123",
+			},
+			want: `resource.type="k8s_cluster" AND logName="projects/test-project/logs/cloudaudit.googleapis.com%2Factivity" AND insertId="abc123"`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -653,3 +667,40 @@ func TestCloudLoggingProvider_getObjectReference(t *testing.T) {
 		})
 	}
 }
+
+func TestIsThrottlingError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "quota exceeded",
+			err:      status.Error(codes.ResourceExhausted, "Quota exceeded for quota metric 'Read requests'"),
+			expected: true,
+		},
+		{
+			name:     "wrapped unavailable",
+			err:      fmt.Errorf("list entries: %w", status.Error(codes.Unavailable, "unavailable")),
+			expected: true,
+		},
+		{
+			name:     "permission denied",
+			err:      status.Error(codes.PermissionDenied, "denied"),
+			expected: false,
+		},
+		{
+			name:     "non grpc error",
+			err:      errors.New("connection refused"),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isThrottlingError(tt.err); got != tt.expected {
+				t.Errorf("isThrottlingError() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package gcp
+
+import (
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// transportClientOptions returns the gRPC options of the logadmin client
+// that connect to the endpoint through the proxy with the TLS settings of
+// the config.
+func transportClientOptions(config *transport.Config) ([]option.ClientOption, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	var opts []option.ClientOption
+	if config.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(config.Endpoint))
+	}
+	dialer, err := config.ProxyDialer()
+	if err != nil {
+		return nil, err
+	}
+	if dialer != nil {
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithContextDialer(dialer)))
+	}
+	tlsConfig, err := config.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, option.WithGRPCDialOption(
+			grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))))
+	}
+	return opts, nil
+}
@@ -0,0 +1,53 @@
+package gcp
+
+import (
+	"testing"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportClientOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       *transport.Config
+		expectedOpts int
+		expectedErr  string
+	}{
+		{
+			name:         "nil",
+			expectedOpts: 0,
+		},
+		{
+			name:         "private service connect endpoint",
+			config:       &transport.Config{Endpoint: "logging-psc.p.googleapis.com:443"},
+			expectedOpts: 1,
+		},
+		{
+			name: "proxy and insecure skip verify",
+			config: &transport.Config{
+				Endpoint:           "logging-psc.p.googleapis.com:443",
+				Proxy:              "http://proxy.example.com:3128",
+				InsecureSkipVerify: true,
+			},
+			expectedOpts: 3,
+		},
+		{
+			name:        "invalid proxy",
+			config:      &transport.Config{Proxy: "socks5://proxy.example.com:1080"},
+			expectedErr: "scheme must be http or https",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := transportClientOptions(tt.config)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, opts, tt.expectedOpts)
+		})
+	}
+}
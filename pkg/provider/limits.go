@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+)
+
+type maxBytesScannedKey struct{}
+
+// WithMaxBytesScanned returns a context that asks the provider to abort
+// queries that scan more than maxBytes bytes.
+func WithMaxBytesScanned(ctx context.Context, maxBytes int64) context.Context {
+	return context.WithValue(ctx, maxBytesScannedKey{}, maxBytes)
+}
+
+// MaxBytesScanned returns the scan limit of the context, 0 means no limit.
+func MaxBytesScanned(ctx context.Context) int64 {
+	maxBytes, _ := ctx.Value(maxBytesScannedKey{}).(int64)
+	return maxBytes
+}
+
+// ScanLimitError is returned by providers when a query scanned, or is
+// estimated to scan, more bytes than allowed by MaxBytesScanned.
+type ScanLimitError struct {
+	Limit     int64
+	Scanned   int64
+	Estimated bool
+}
+
+func (e *ScanLimitError) Error() string {
+	verb := "scanned"
+	if e.Estimated {
+		verb = "is estimated to scan"
+	}
+	return fmt.Sprintf("query %s %d bytes, which exceeds the limit of %d bytes", verb, e.Scanned, e.Limit)
+}
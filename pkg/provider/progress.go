@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Progress is the progress of a query, the counts are the totals so far.
+type Progress struct {
+	Message        string `json:"message,omitempty"`
+	RecordsScanned int64  `json:"records_scanned,omitempty"`
+	RecordsMatched int64  `json:"records_matched,omitempty"`
+}
+
+// The levels of status messages, they are levels of MCP log messages.
+const (
+	StatusInfo    = "info"
+	StatusWarning = "warning"
+)
+
+// Reporter receives the progress and the status messages of the queries of
+// a request, e.g. to notify the MCP client.
+type Reporter interface {
+	Progress(Progress)
+	Status(level, message string)
+}
+
+type reporterKey struct{}
+
+// WithReporter returns a context that sends the progress and the status
+// messages of the queries to r.
+func WithReporter(ctx context.Context, r Reporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, r)
+}
+
+// ReportProgress sends the progress to the reporter of the context, if any.
+func ReportProgress(ctx context.Context, progress Progress) {
+	if r, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+		r.Progress(progress)
+	}
+}
+
+// ReportStatus logs the status message, and sends it to the reporter of the
+// context, if any. Status messages must not contain the provider query,
+// which may contain values that are pseudonymized for the client.
+func ReportStatus(ctx context.Context, level string, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	log.Print(message)
+	if r, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+		r.Status(level, message)
+	}
+}
@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingReporter struct {
+	progress []Progress
+	status   []string
+}
+
+func (r *recordingReporter) Progress(progress Progress) {
+	r.progress = append(r.progress, progress)
+}
+
+func (r *recordingReporter) Status(level, message string) {
+	r.status = append(r.status, level+": "+message)
+}
+
+func TestReportProgress(t *testing.T) {
+	// Without a reporter the reports are dropped.
+	ReportProgress(context.Background(), Progress{Message: "started"})
+	ReportStatus(context.Background(), StatusInfo, "started")
+
+	r := &recordingReporter{}
+	ctx := WithReporter(context.Background(), r)
+	ReportProgress(ctx, Progress{Message: "running", RecordsScanned: 10, RecordsMatched: 2})
+	ReportStatus(ctx, StatusWarning, "request was throttled, retrying in %s", "1s")
+
+	assert.Equal(t, []Progress{{Message: "running", RecordsScanned: 10, RecordsMatched: 2}}, r.progress)
+	assert.Equal(t, []string{"warning: request was throttled, retrying in 1s"}, r.status)
+}
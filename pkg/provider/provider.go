@@ -2,9 +2,30 @@ package provider
 
 import (
 	"context"
+	"io"
+
 	"github.com/mozillazg/kube-audit-mcp/pkg/types"
 )
 
 type Provider interface {
 	QueryAuditLog(context.Context, types.QueryAuditLogParams) (types.AuditLogResult, error)
+	// Capabilities returns the features of the provider. Decorators return
+	// the capabilities of the wrapped provider, adjusted to their changes.
+	Capabilities() Capabilities
+}
+
+// Close closes the provider when it implements io.Closer, e.g. the
+// exec-plugin provider stops its plugin. Decorators close the wrapped
+// provider.
+func Close(p Provider) error {
+	if closer, ok := p.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// CredentialsChecker is implemented by providers that can check whether
+// their credentials are valid without querying the logs.
+type CredentialsChecker interface {
+	CheckCredentials(ctx context.Context) error
 }
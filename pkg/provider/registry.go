@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
+)
+
+// Registration describes a provider for the registry.
+type Registration struct {
+	// Name is the name of the provider in the config, e.g. alibaba-sls.
+	Name string
+	// ConfigKey is the key of the dedicated field of the provider in the
+	// provider config, e.g. alibaba_sls. Providers without a dedicated
+	// field are configured with options.
+	ConfigKey string
+	// DecodeConfig decodes the options of the provider config to the
+	// config of the provider, see DecodeConfig.
+	DecodeConfig func(options map[string]any) (any, error)
+	// New creates the provider with the config returned by DecodeConfig.
+	New func(config any, transportConfig *transport.Config) (Provider, error)
+	// SampleConfig is the config of the provider in the sample config, and
+	// SampleCluster is the name of its cluster there. Providers that only
+	// work with real options, e.g. the path of a binary, have no
+	// SampleConfig and are left out of the sample config.
+	SampleConfig  any
+	SampleCluster string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Registration{}
+)
+
+// Register adds the provider to the registry, it is usually called in the
+// init function of the provider package. It panics when the registration is
+// incomplete or the name is already registered.
+func Register(r Registration) {
+	if r.Name == "" || r.DecodeConfig == nil || r.New == nil {
+		panic("provider: Register requires Name, DecodeConfig and New")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[r.Name]; ok {
+		panic(fmt.Sprintf("provider: Register called twice for provider %s", r.Name))
+	}
+	registry[r.Name] = r
+}
+
+// Lookup returns the registration of the provider.
+func Lookup(name string) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := registry[name]
+	return r, ok
+}
+
+// Registrations returns the registered providers sorted by name.
+func Registrations() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	rs := make([]Registration, 0, len(registry))
+	for _, r := range registry {
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].Name < rs[j].Name })
+	return rs
+}
+
+// Names returns the names of the registered providers.
+func Names() []string {
+	var names []string
+	for _, r := range Registrations() {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// DecodeConfig decodes the options to a new *T by their JSON names, unknown
+// options are rejected. It is the DecodeConfig of most providers, e.g.
+// DecodeConfig[SLSProviderConfig].
+func DecodeConfig[T any](options map[string]any) (any, error) {
+	data, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	config := new(T)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfig struct {
+	Table string `json:"table"`
+	Limit int    `json:"limit"`
+}
+
+func TestRegister(t *testing.T) {
+	r := Registration{
+		Name:         "test-registry",
+		DecodeConfig: DecodeConfig[testConfig],
+		New: func(any, *transport.Config) (Provider, error) {
+			return nil, nil
+		},
+	}
+	Register(r)
+
+	got, ok := Lookup("test-registry")
+	assert.True(t, ok)
+	assert.Equal(t, "test-registry", got.Name)
+	assert.Contains(t, Names(), "test-registry")
+
+	_, ok = Lookup("missing")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { Register(r) })
+	assert.Panics(t, func() { Register(Registration{Name: "incomplete"}) })
+}
+
+func TestDecodeConfig(t *testing.T) {
+	config, err := DecodeConfig[testConfig](map[string]any{"table": "audit", "limit": 10})
+	assert.NoError(t, err)
+	assert.Equal(t, &testConfig{Table: "audit", Limit: 10}, config)
+
+	config, err = DecodeConfig[testConfig](nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &testConfig{}, config)
+
+	_, err = DecodeConfig[testConfig](map[string]any{"tabel": "audit"})
+	assert.ErrorContains(t, err, `unknown field "tabel"`)
+}
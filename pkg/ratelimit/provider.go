@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+)
+
+// Provider limits the number of concurrent queries of the wrapped provider,
+// and passes the limiter to it so that its API requests are rate limited.
+type Provider struct {
+	next    provider.Provider
+	limiter *Limiter
+}
+
+var _ provider.Provider = (*Provider)(nil)
+
+func NewProvider(next provider.Provider, limiter *Limiter) *Provider {
+	return &Provider{
+		next:    next,
+		limiter: limiter,
+	}
+}
+
+// Capabilities returns the capabilities of the wrapped provider.
+func (p *Provider) Capabilities() provider.Capabilities {
+	return p.next.Capabilities()
+}
+
+// Close closes the wrapped provider.
+func (p *Provider) Close() error {
+	return provider.Close(p.next)
+}
+
+func (p *Provider) QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	if err := p.limiter.Acquire(ctx); err != nil {
+		return types.AuditLogResult{}, fmt.Errorf("waiting for a query slot: %w", err)
+	}
+	defer p.limiter.Release()
+
+	return p.next.QueryAuditLog(NewContext(ctx, p.limiter), params)
+}
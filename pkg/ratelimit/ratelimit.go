@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultMaxRetries     = 3
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 10 * time.Second
+)
+
+type Config struct {
+	// QPS is the maximum number of API requests per second sent to the
+	// backend, 0 means no limit.
+	QPS   float64 `yaml:"qps,omitempty" json:"qps,omitempty"`
+	Burst int     `yaml:"burst,omitempty" json:"burst,omitempty"`
+	// MaxConcurrentQueries is the maximum number of queries running at the
+	// same time, 0 means no limit.
+	MaxConcurrentQueries int `yaml:"max_concurrent_queries,omitempty" json:"max_concurrent_queries,omitempty"`
+
+	// MaxRetries is the maximum number of retries of API requests that were
+	// throttled by the backend. Defaults to 3, -1 disables retries.
+	MaxRetries     int             `yaml:"max_retries,omitempty" json:"max_retries,omitempty"`
+	RetryBaseDelay metav1.Duration `yaml:"retry_base_delay,omitempty" json:"retry_base_delay,omitempty"`
+	RetryMaxDelay  metav1.Duration `yaml:"retry_max_delay,omitempty" json:"retry_max_delay,omitempty"`
+}
+
+type Limiter struct {
+	limiter   *rate.Limiter
+	semaphore chan struct{}
+
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+type limiterKey struct{}
+
+// defaultLimiter retries throttled requests of clusters without rate_limit
+// config with the default backoff, without limiting the requests.
+var defaultLimiter = New(&Config{})
+
+func New(config *Config) *Limiter {
+	l := &Limiter{
+		maxRetries: config.MaxRetries,
+		baseDelay:  config.RetryBaseDelay.Duration,
+		maxDelay:   config.RetryMaxDelay.Duration,
+	}
+	if config.QPS > 0 {
+		burst := config.Burst
+		if burst <= 0 {
+			burst = max(1, int(config.QPS))
+		}
+		l.limiter = rate.NewLimiter(rate.Limit(config.QPS), burst)
+	}
+	if config.MaxConcurrentQueries > 0 {
+		l.semaphore = make(chan struct{}, config.MaxConcurrentQueries)
+	}
+	if l.maxRetries == 0 {
+		l.maxRetries = defaultMaxRetries
+	} else if l.maxRetries < 0 {
+		l.maxRetries = 0
+	}
+	if l.baseDelay <= 0 {
+		l.baseDelay = defaultRetryBaseDelay
+	}
+	if l.maxDelay <= 0 {
+		l.maxDelay = defaultRetryMaxDelay
+	}
+
+	return l
+}
+
+// NewContext returns a context that carries the limiter, it is used by Do
+// to rate limit and retry the API requests of providers.
+func NewContext(ctx context.Context, l *Limiter) context.Context {
+	return context.WithValue(ctx, limiterKey{}, l)
+}
+
+func FromContext(ctx context.Context) *Limiter {
+	l, _ := ctx.Value(limiterKey{}).(*Limiter)
+	return l
+}
+
+// Acquire blocks until a query slot is available or ctx is done.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	if l.semaphore == nil {
+		return nil
+	}
+	select {
+	case l.semaphore <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Limiter) Release() {
+	if l.semaphore == nil {
+		return
+	}
+	<-l.semaphore
+}
+
+// Do calls fn, waiting for the rate limiter of ctx before each attempt, and
+// retries it with jittered exponential backoff as long as isThrottled
+// reports the error as a throttling error. Without a limiter in ctx the
+// default retries are used.
+func Do(ctx context.Context, isThrottled func(error) bool, fn func() error) error {
+	l := FromContext(ctx)
+	if l == nil {
+		l = defaultLimiter
+	}
+
+	for attempt := 0; ; attempt++ {
+		if l.limiter != nil {
+			if err := l.limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		err := fn()
+		if err == nil || !isThrottled(err) || attempt >= l.maxRetries {
+			return err
+		}
+
+		delay := l.backoff(attempt)
+		provider.ReportStatus(ctx, provider.StatusWarning, "request was throttled, retrying in %s: %v", delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a random delay between 0 and min(maxDelay, baseDelay*2^attempt).
+func (l *Limiter) backoff(attempt int) time.Duration {
+	delay := l.maxDelay
+	if attempt < 30 {
+		delay = min(l.maxDelay, l.baseDelay<<attempt)
+	}
+	return rand.N(delay) + 1
+}
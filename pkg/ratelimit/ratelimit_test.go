@@ -0,0 +1,203 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var errThrottled = errors.New("throttled")
+
+func isThrottled(err error) bool {
+	return errors.Is(err, errThrottled)
+}
+
+type mockProvider struct {
+	running atomic.Int32
+	peak    atomic.Int32
+	limited atomic.Bool
+}
+
+func (m *mockProvider) QueryAuditLog(ctx context.Context, _ types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	m.limited.Store(FromContext(ctx) != nil)
+	n := m.running.Add(1)
+	defer m.running.Add(-1)
+	for {
+		peak := m.peak.Load()
+		if n <= peak || m.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return types.AuditLogResult{}, nil
+}
+
+func (m *mockProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{}
+}
+
+func TestDo(t *testing.T) {
+	fastRetry := metav1.Duration{Duration: time.Millisecond}
+	orig := defaultLimiter
+	defaultLimiter = New(&Config{RetryBaseDelay: fastRetry})
+	t.Cleanup(func() { defaultLimiter = orig })
+
+	tests := []struct {
+		name          string
+		config        *Config
+		failures      int
+		err           error
+		expectedErr   error
+		expectedCalls int
+	}{
+		{
+			name:          "no limiter uses the default retries",
+			failures:      2,
+			err:           errThrottled,
+			expectedCalls: 3,
+		},
+		{
+			name:          "no limiter gives up after the default retries",
+			failures:      5,
+			err:           errThrottled,
+			expectedErr:   errThrottled,
+			expectedCalls: 4,
+		},
+		{
+			name:          "succeeds after retries",
+			config:        &Config{RetryBaseDelay: fastRetry},
+			failures:      2,
+			err:           errThrottled,
+			expectedCalls: 3,
+		},
+		{
+			name:          "gives up after max retries",
+			config:        &Config{MaxRetries: 2, RetryBaseDelay: fastRetry},
+			failures:      5,
+			err:           errThrottled,
+			expectedErr:   errThrottled,
+			expectedCalls: 3,
+		},
+		{
+			name:          "retries disabled",
+			config:        &Config{MaxRetries: -1},
+			failures:      5,
+			err:           errThrottled,
+			expectedErr:   errThrottled,
+			expectedCalls: 1,
+		},
+		{
+			name:          "other errors are not retried",
+			config:        &Config{RetryBaseDelay: fastRetry},
+			failures:      5,
+			err:           errors.ErrUnsupported,
+			expectedErr:   errors.ErrUnsupported,
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.config != nil {
+				ctx = NewContext(ctx, New(tt.config))
+			}
+
+			calls := 0
+			err := Do(ctx, isThrottled, func() error {
+				calls++
+				if calls <= tt.failures {
+					return tt.err
+				}
+				return nil
+			})
+			assert.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr == nil {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedCalls, calls)
+		})
+	}
+}
+
+func TestDo_Canceled(t *testing.T) {
+	l := New(&Config{RetryBaseDelay: metav1.Duration{Duration: time.Hour}})
+	ctx, cancel := context.WithTimeout(NewContext(context.Background(), l), 10*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	err := Do(ctx, isThrottled, func() error {
+		calls++
+		return errThrottled
+	})
+	assert.ErrorIs(t, err, errThrottled)
+	assert.LessOrEqual(t, calls, 2)
+}
+
+func TestDo_QPS(t *testing.T) {
+	ctx := NewContext(context.Background(), New(&Config{QPS: 50, Burst: 1}))
+
+	start := time.Now()
+	for range 6 {
+		assert.NoError(t, Do(ctx, isThrottled, func() error { return nil }))
+	}
+	// The first request uses the burst, the other five wait 20ms each
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestLimiter_backoff(t *testing.T) {
+	l := New(&Config{
+		RetryBaseDelay: metav1.Duration{Duration: 100 * time.Millisecond},
+		RetryMaxDelay:  metav1.Duration{Duration: time.Second},
+	})
+
+	for attempt, ceiling := range []time.Duration{
+		100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond,
+		800 * time.Millisecond, time.Second, time.Second,
+	} {
+		for range 20 {
+			delay := l.backoff(attempt)
+			assert.Greater(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, ceiling)
+		}
+	}
+	assert.LessOrEqual(t, l.backoff(100), time.Second)
+}
+
+func TestProvider_QueryAuditLog(t *testing.T) {
+	next := &mockProvider{}
+	p := NewProvider(next, New(&Config{MaxConcurrentQueries: 2}))
+
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.QueryAuditLog(context.Background(), types.QueryAuditLogParams{})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), next.peak.Load())
+	assert.True(t, next.limited.Load())
+}
+
+func TestProvider_QueryAuditLog_Canceled(t *testing.T) {
+	l := New(&Config{MaxConcurrentQueries: 1})
+	assert.NoError(t, l.Acquire(context.Background()))
+	defer l.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := NewProvider(&mockProvider{}, l).QueryAuditLog(ctx, types.QueryAuditLogParams{})
+	assert.EqualError(t, err, "waiting for a query slot: context deadline exceeded")
+}
@@ -0,0 +1,148 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type segmentKind int
+
+const (
+	segmentKey segmentKind = iota
+	segmentIndex
+	segmentWildcard
+)
+
+type segment struct {
+	kind  segmentKind
+	key   string
+	index int
+}
+
+// path is a parsed JSONPath expression. Only a small subset of JSONPath is
+// supported: `$`, `.key`, `['key']`, `[n]`, `.*` and `[*]`.
+type path []segment
+
+func parsePath(expr string) (path, error) {
+	s := strings.TrimSpace(expr)
+	if s == "" {
+		return nil, errors.New("empty path")
+	}
+	s = strings.TrimPrefix(s, "$")
+	if s != "" && s[0] != '.' && s[0] != '[' {
+		s = "." + s
+	}
+
+	var p path
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			key := s[:end]
+			s = s[end:]
+			switch key {
+			case "":
+				return nil, fmt.Errorf("invalid path %q: empty key", expr)
+			case "*":
+				p = append(p, segment{kind: segmentWildcard})
+			default:
+				p = append(p, segment{kind: segmentKey, key: key})
+			}
+		case '[':
+			end := strings.Index(s, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", expr)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case inner == "*":
+				p = append(p, segment{kind: segmentWildcard})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				p = append(p, segment{kind: segmentKey, key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid path %q: bad index %q", expr, inner)
+				}
+				p = append(p, segment{kind: segmentIndex, index: n})
+			}
+		default:
+			return nil, fmt.Errorf("invalid path %q: unexpected %q", expr, s[0])
+		}
+	}
+
+	return p, nil
+}
+
+// walk calls fn for every node matched by p. The callback receives the
+// location of the matched node and a setter that replaces it.
+func (p path) walk(node any, location string, fn func(location string, value any, set func(any))) {
+	p.walkFrom(node, location, nil, fn)
+}
+
+func (p path) walkFrom(node any, location string, set func(any), fn func(string, any, func(any))) {
+	if len(p) == 0 {
+		if set != nil {
+			fn(location, node, set)
+		}
+		return
+	}
+
+	seg, rest := p[0], p[1:]
+	switch v := node.(type) {
+	case map[string]any:
+		switch seg.kind {
+		case segmentKey:
+			child, ok := v[seg.key]
+			if !ok {
+				return
+			}
+			rest.walkFrom(child, joinKey(location, seg.key), mapSetter(v, seg.key), fn)
+		case segmentWildcard:
+			for key, child := range v {
+				rest.walkFrom(child, joinKey(location, key), mapSetter(v, key), fn)
+			}
+		}
+	case []any:
+		switch seg.kind {
+		case segmentIndex:
+			if seg.index >= len(v) {
+				return
+			}
+			rest.walkFrom(v[seg.index], joinIndex(location, seg.index), sliceSetter(v, seg.index), fn)
+		case segmentWildcard:
+			for i, child := range v {
+				rest.walkFrom(child, joinIndex(location, i), sliceSetter(v, i), fn)
+			}
+		}
+	}
+}
+
+func mapSetter(m map[string]any, key string) func(any) {
+	return func(v any) { m[key] = v }
+}
+
+func sliceSetter(s []any, i int) func(any) {
+	return func(v any) { s[i] = v }
+}
+
+func joinKey(location, key string) string {
+	if strings.ContainsAny(key, ".[]'") {
+		return fmt.Sprintf("%s['%s']", location, key)
+	}
+	if location == "" {
+		return key
+	}
+	return location + "." + key
+}
+
+func joinIndex(location string, i int) string {
+	return fmt.Sprintf("%s[%d]", location, i)
+}
@@ -0,0 +1,150 @@
+package redact
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		name        string
+		expr        string
+		expected    path
+		expectedErr string
+	}{
+		{
+			name: "dot notation",
+			expr: "$.requestObject.data",
+			expected: path{
+				{kind: segmentKey, key: "requestObject"},
+				{kind: segmentKey, key: "data"},
+			},
+		},
+		{
+			name: "without root",
+			expr: "requestObject.data",
+			expected: path{
+				{kind: segmentKey, key: "requestObject"},
+				{kind: segmentKey, key: "data"},
+			},
+		},
+		{
+			name: "bracket notation and wildcards",
+			expr: "$.responseObject.items[*].metadata.annotations['a.b/c'].*",
+			expected: path{
+				{kind: segmentKey, key: "responseObject"},
+				{kind: segmentKey, key: "items"},
+				{kind: segmentWildcard},
+				{kind: segmentKey, key: "metadata"},
+				{kind: segmentKey, key: "annotations"},
+				{kind: segmentKey, key: "a.b/c"},
+				{kind: segmentWildcard},
+			},
+		},
+		{
+			name: "index",
+			expr: `$.requestObject.spec.containers[1]["env"]`,
+			expected: path{
+				{kind: segmentKey, key: "requestObject"},
+				{kind: segmentKey, key: "spec"},
+				{kind: segmentKey, key: "containers"},
+				{kind: segmentIndex, index: 1},
+				{kind: segmentKey, key: "env"},
+			},
+		},
+		{
+			name:        "empty",
+			expr:        " ",
+			expectedErr: "empty path",
+		},
+		{
+			name:        "missing bracket",
+			expr:        "$.a[0",
+			expectedErr: "missing ]",
+		},
+		{
+			name:        "bad index",
+			expr:        "$.a[x]",
+			expectedErr: `bad index "x"`,
+		},
+		{
+			name:        "empty key",
+			expr:        "$.a..b",
+			expectedErr: "empty key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePath(tt.expr)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, p)
+		})
+	}
+}
+
+func TestPath_walk(t *testing.T) {
+	var doc any
+	err := json.Unmarshal([]byte(`{
+  "items": [
+    {"data": {"a": "1"}},
+    {"data": {"b": "2"}},
+    {"other": true}
+  ],
+  "annotations": {"kubectl.kubernetes.io/last-applied-configuration": "{}"}
+}`), &doc)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		expr     string
+		expected []string
+	}{
+		{
+			name:     "wildcard in array",
+			expr:     "$.items[*].data",
+			expected: []string{"items[0].data", "items[1].data"},
+		},
+		{
+			name:     "index",
+			expr:     "$.items[1].data.b",
+			expected: []string{"items[1].data.b"},
+		},
+		{
+			name:     "index out of range",
+			expr:     "$.items[5].data",
+			expected: nil,
+		},
+		{
+			name:     "quoted key",
+			expr:     "$.annotations['kubectl.kubernetes.io/last-applied-configuration']",
+			expected: []string{"annotations['kubectl.kubernetes.io/last-applied-configuration']"},
+		},
+		{
+			name:     "not found",
+			expr:     "$.spec.token",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePath(tt.expr)
+			assert.NoError(t, err)
+
+			var locations []string
+			p.walk(doc, "", func(location string, _ any, _ func(any)) {
+				locations = append(locations, location)
+			})
+			sort.Strings(locations)
+			assert.Equal(t, tt.expected, locations)
+		})
+	}
+}
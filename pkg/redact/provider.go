@@ -0,0 +1,43 @@
+package redact
+
+import (
+	"context"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+)
+
+// Provider redacts sensitive content from the results of the wrapped provider
+// before they are returned to the model.
+type Provider struct {
+	next     provider.Provider
+	redactor *Redactor
+}
+
+var _ provider.Provider = (*Provider)(nil)
+
+func NewProvider(next provider.Provider, redactor *Redactor) *Provider {
+	return &Provider{
+		next:     next,
+		redactor: redactor,
+	}
+}
+
+// Capabilities returns the capabilities of the wrapped provider.
+func (p *Provider) Capabilities() provider.Capabilities {
+	return p.next.Capabilities()
+}
+
+// Close closes the wrapped provider.
+func (p *Provider) Close() error {
+	return provider.Close(p.next)
+}
+
+func (p *Provider) QueryAuditLog(ctx context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	result, err := p.next.QueryAuditLog(ctx, params)
+	if err != nil {
+		return result, err
+	}
+	p.redactor.RedactResult(&result)
+	return result, nil
+}
@@ -0,0 +1,341 @@
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/mozillazg/kube-audit-mcp/pkg/utils"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const Placeholder = "[REDACTED]"
+
+type Config struct {
+	// Disabled turns off redaction entirely, including the built-in rules.
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	// DisableBuiltinRules keeps only the rules and patterns configured below.
+	DisableBuiltinRules bool `yaml:"disable_builtin_rules,omitempty" json:"disable_builtin_rules,omitempty"`
+
+	Rules    []Rule   `yaml:"rules,omitempty" json:"rules,omitempty"`
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+}
+
+// Rule redacts the fields matched by a JSONPath expression. The path is
+// evaluated against an audit event with the `requestObject`, `responseObject`
+// and `annotations` fields, e.g. `$.requestObject.spec.token`.
+type Rule struct {
+	Path string `yaml:"path" json:"path"`
+	// Resources limits the rule to the given resources, e.g. `secrets` or
+	// `serviceaccounts/token`. Empty means all resources.
+	Resources []string `yaml:"resources,omitempty" json:"resources,omitempty"`
+}
+
+type Redactor struct {
+	rules    []compiledRule
+	patterns []*regexp.Regexp
+	keys     bool
+}
+
+type compiledRule struct {
+	path      path
+	resources []string
+}
+
+var builtinRules = []Rule{
+	{Path: "$.requestObject.data", Resources: []string{"secrets"}},
+	{Path: "$.requestObject.stringData", Resources: []string{"secrets"}},
+	{Path: "$.requestObject.metadata.annotations['kubectl.kubernetes.io/last-applied-configuration']", Resources: []string{"secrets"}},
+	{Path: "$.responseObject.data", Resources: []string{"secrets"}},
+	{Path: "$.responseObject.stringData", Resources: []string{"secrets"}},
+	{Path: "$.responseObject.metadata.annotations['kubectl.kubernetes.io/last-applied-configuration']", Resources: []string{"secrets"}},
+	{Path: "$.responseObject.items[*].data", Resources: []string{"secrets"}},
+	{Path: "$.responseObject.items[*].stringData", Resources: []string{"secrets"}},
+	{Path: "$.responseObject.items[*].metadata.annotations['kubectl.kubernetes.io/last-applied-configuration']", Resources: []string{"secrets"}},
+	{Path: "$.responseObject.status.token", Resources: []string{"serviceaccounts/token"}},
+	{Path: "$.requestObject.spec.token", Resources: []string{"tokenreviews"}},
+	{Path: "$.responseObject.spec.token", Resources: []string{"tokenreviews"}},
+}
+
+var builtinPatterns = []string{
+	// Authorization header values
+	`(?i)\b(bearer|basic)\s+[a-z0-9\-._~+/]{8,}=*`,
+	// JSON Web Tokens, e.g. service account tokens
+	`\beyJ[a-zA-Z0-9_-]{5,}\.eyJ[a-zA-Z0-9_-]{5,}\.[a-zA-Z0-9_-]*`,
+	// AWS access key IDs
+	`\b(AKIA|ASIA)[0-9A-Z]{16}\b`,
+	// Alibaba Cloud AccessKey IDs
+	`\bLTAI[0-9a-zA-Z]{12,28}\b`,
+	// PEM encoded private keys
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+}
+
+// sensitiveKeyPattern matches object keys (and env var names) whose string
+// values are treated as credentials.
+var sensitiveKeyPattern = regexp.MustCompile(
+	`(?i)^(.*[-_.])?(password|passwd|pwd|secret|token|api[-_]?key|access[-_]?key([-_]?id)?|` +
+		`secret[-_]?access[-_]?key|private[-_]?key|client[-_]?secret|authorization|credentials?)$`)
+
+func New(config *Config) (*Redactor, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	if config.Disabled {
+		return nil, nil
+	}
+
+	r := &Redactor{}
+	rules := config.Rules
+	patterns := config.Patterns
+	if !config.DisableBuiltinRules {
+		rules = append(append([]Rule{}, builtinRules...), rules...)
+		patterns = append(append([]string{}, builtinPatterns...), patterns...)
+		r.keys = true
+	}
+
+	for _, rule := range rules {
+		p, err := parsePath(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule: %w", err)
+		}
+		r.rules = append(r.rules, compiledRule{path: p, resources: rule.Resources})
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	return r, nil
+}
+
+// RedactResult redacts all entries of the result in place and records the
+// redacted fields in result.Redactions.
+func (r *Redactor) RedactResult(result *types.AuditLogResult) {
+	if r == nil {
+		return
+	}
+	for i := range result.Entries {
+		fields := r.RedactEntry(&result.Entries[i])
+		if len(fields) == 0 {
+			continue
+		}
+		result.Redactions = append(result.Redactions, types.Redaction{
+			AuditID: string(result.Entries[i].AuditID),
+			Fields:  fields,
+		})
+	}
+}
+
+// RedactEntry redacts sensitive content of the entry in place and returns
+// the locations of the redacted fields.
+func (r *Redactor) RedactEntry(entry *types.AuditLogEntry) []string {
+	if r == nil {
+		return nil
+	}
+
+	var fields []string
+	record := func(location string) {
+		fields = append(fields, location)
+	}
+
+	doc := map[string]any{}
+	if obj := decodeObject(entry.RequestObject, "requestObject", record); obj != nil {
+		doc["requestObject"] = obj
+	}
+	if obj := decodeObject(entry.ResponseObject, "responseObject", record); obj != nil {
+		doc["responseObject"] = obj
+	}
+	if len(entry.Annotations) > 0 {
+		annotations := make(map[string]any, len(entry.Annotations))
+		for k, v := range entry.Annotations {
+			annotations[k] = v
+		}
+		doc["annotations"] = annotations
+	}
+
+	resource := entryResource(entry)
+	for _, rule := range r.rules {
+		if !rule.matches(resource) {
+			continue
+		}
+		rule.path.walk(doc, "", func(location string, value any, set func(any)) {
+			set(redactAll(value, location, record))
+		})
+	}
+	if r.keys {
+		redactSensitiveKeys(doc, "", record)
+	}
+	if len(r.patterns) > 0 {
+		r.redactPatterns(doc, "", record)
+		if s, ok := r.redactString(entry.RequestURI); ok {
+			entry.RequestURI = s
+			record("requestURI")
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	if obj, ok := doc["requestObject"]; ok {
+		encodeObject(entry.RequestObject, obj)
+	}
+	if obj, ok := doc["responseObject"]; ok {
+		encodeObject(entry.ResponseObject, obj)
+	}
+	if annotations, ok := doc["annotations"].(map[string]any); ok {
+		for k, v := range annotations {
+			if s, ok := v.(string); ok {
+				entry.Annotations[k] = s
+			}
+		}
+	}
+
+	fields = utils.RemoveDuplicates(fields)
+	sort.Strings(fields)
+	return fields
+}
+
+func (c compiledRule) matches(resource string) bool {
+	if len(c.resources) == 0 {
+		return true
+	}
+	return utils.Contains(c.resources, resource)
+}
+
+func (r *Redactor) redactPatterns(node any, location string, record func(string)) {
+	switch v := node.(type) {
+	case map[string]any:
+		for key, child := range v {
+			childLocation := joinKey(location, key)
+			if s, ok := child.(string); ok {
+				if redacted, ok := r.redactString(s); ok {
+					v[key] = redacted
+					record(childLocation)
+				}
+				continue
+			}
+			r.redactPatterns(child, childLocation, record)
+		}
+	case []any:
+		for i, child := range v {
+			childLocation := joinIndex(location, i)
+			if s, ok := child.(string); ok {
+				if redacted, ok := r.redactString(s); ok {
+					v[i] = redacted
+					record(childLocation)
+				}
+				continue
+			}
+			r.redactPatterns(child, childLocation, record)
+		}
+	}
+}
+
+func (r *Redactor) redactString(s string) (string, bool) {
+	if s == "" {
+		return s, false
+	}
+	redacted := s
+	for _, re := range r.patterns {
+		redacted = re.ReplaceAllString(redacted, Placeholder)
+	}
+	return redacted, redacted != s
+}
+
+// redactSensitiveKeys redacts string values stored under credential-like
+// keys, and the values of env vars with credential-like names.
+func redactSensitiveKeys(node any, location string, record func(string)) {
+	switch v := node.(type) {
+	case map[string]any:
+		if name, ok := v["name"].(string); ok && sensitiveKeyPattern.MatchString(name) {
+			if value, ok := v["value"].(string); ok && value != "" && value != Placeholder {
+				v["value"] = Placeholder
+				record(joinKey(location, "value"))
+			}
+		}
+		for key, child := range v {
+			childLocation := joinKey(location, key)
+			if s, ok := child.(string); ok {
+				if s != "" && s != Placeholder && sensitiveKeyPattern.MatchString(key) {
+					v[key] = Placeholder
+					record(childLocation)
+				}
+				continue
+			}
+			redactSensitiveKeys(child, childLocation, record)
+		}
+	case []any:
+		for i, child := range v {
+			redactSensitiveKeys(child, joinIndex(location, i), record)
+		}
+	}
+}
+
+// redactAll replaces every scalar value below node with the placeholder,
+// keeping the keys of objects so that it is still visible what was changed.
+func redactAll(node any, location string, record func(string)) any {
+	switch v := node.(type) {
+	case map[string]any:
+		for key, child := range v {
+			v[key] = redactAll(child, joinKey(location, key), record)
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = redactAll(child, joinIndex(location, i), record)
+		}
+		return v
+	case nil:
+		return nil
+	case string:
+		if v == "" || v == Placeholder {
+			return v
+		}
+	}
+	record(location)
+	return Placeholder
+}
+
+func entryResource(entry *types.AuditLogEntry) string {
+	if entry.ObjectRef == nil {
+		return ""
+	}
+	if entry.ObjectRef.Subresource != "" {
+		return entry.ObjectRef.Resource + "/" + entry.ObjectRef.Subresource
+	}
+	return entry.ObjectRef.Resource
+}
+
+// decodeObject decodes the object for redaction. An object that can't be
+// decoded can't be inspected either, so it is replaced with the placeholder
+// as a whole instead of being returned unchanged.
+func decodeObject(obj *runtime.Unknown, location string, record func(string)) any {
+	if obj == nil || len(obj.Raw) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(obj.Raw, &v); err != nil {
+		log.Printf("failed to decode %s for redaction, redacting it: %v", location, err)
+		obj.Raw = []byte(`"` + Placeholder + `"`)
+		record(location)
+		return nil
+	}
+	return v
+}
+
+func encodeObject(obj *runtime.Unknown, v any) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to encode redacted object: %v", err)
+		obj.Raw = []byte(`"` + Placeholder + `"`)
+		return
+	}
+	obj.Raw = raw
+}
@@ -0,0 +1,215 @@
+package tools
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/mozillazg/kube-audit-mcp/pkg/guardrail"
+)
+
+const (
+	maxCompletionValues = 100
+
+	// The recent values of a cluster are the distinct namespaces and users
+	// of the latest recentValuesLimit events of the last recentValuesWindow,
+	// narrowed to the maximum time range of the cluster, they are cached
+	// for recentValuesTTL.
+	recentValuesWindow  = 24 * time.Hour
+	recentValuesLimit   = 500
+	recentValuesTTL     = 5 * time.Minute
+	recentValuesTimeout = 10 * time.Second
+)
+
+// Completer completes the arguments of the prompts and the resource
+// templates. Cluster names and resource types come from the config and the
+// built-in resource types, namespaces and users from the recent events of
+// the cluster.
+type Completer struct {
+	mu     sync.Mutex
+	cfg    *config.Config
+	recent map[string]*recentValues
+}
+
+type recentValues struct {
+	mu         sync.Mutex
+	fetchedAt  time.Time
+	namespaces []string
+	users      []string
+}
+
+var (
+	_ server.PromptCompletionProvider   = (*Completer)(nil)
+	_ server.ResourceCompletionProvider = (*Completer)(nil)
+)
+
+func NewCompleter(cfg *config.Config) *Completer {
+	return &Completer{cfg: cfg, recent: map[string]*recentValues{}}
+}
+
+// SetConfig replaces the config when it is reloaded, and drops the cached
+// recent values.
+func (c *Completer) SetConfig(cfg *config.Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg = cfg
+	c.recent = map[string]*recentValues{}
+}
+
+func (c *Completer) CompletePromptArgument(ctx context.Context, _ string, argument mcp.CompleteArgument, completeContext mcp.CompleteContext) (*mcp.Completion, error) {
+	return c.complete(ctx, argument, completeContext), nil
+}
+
+func (c *Completer) CompleteResourceArgument(ctx context.Context, _ string, argument mcp.CompleteArgument, completeContext mcp.CompleteContext) (*mcp.Completion, error) {
+	return c.complete(ctx, argument, completeContext), nil
+}
+
+func (c *Completer) complete(ctx context.Context, argument mcp.CompleteArgument, completeContext mcp.CompleteContext) *mcp.Completion {
+	c.mu.Lock()
+	cfg := c.cfg
+	c.mu.Unlock()
+
+	var candidates []string
+	switch argument.Name {
+	case "cluster", "cluster_name":
+		candidates = cfg.AvailableClusterNames()
+	case "resource_type", "resource_types", "resource":
+		candidates = resourceTypeNames()
+	case "namespace":
+		candidates, _ = c.recentValues(ctx, cfg, contextCluster(cfg, completeContext))
+	case "user":
+		_, candidates = c.recentValues(ctx, cfg, contextCluster(cfg, completeContext))
+	case "service_account":
+		_, users := c.recentValues(ctx, cfg, contextCluster(cfg, completeContext))
+		candidates = serviceAccounts(users, completeContext.Arguments["namespace"])
+	}
+	return completion(candidates, argument.Value)
+}
+
+// contextCluster returns the cluster of the resolved arguments, or the
+// default cluster.
+func contextCluster(cfg *config.Config, completeContext mcp.CompleteContext) string {
+	for _, name := range []string{"cluster", "cluster_name"} {
+		if cluster := completeContext.Arguments[name]; cluster != "" {
+			return cluster
+		}
+	}
+	return cfg.DefaultCluster
+}
+
+// recentValues returns the cached recent namespaces and users of the
+// cluster, they are fetched again when they expire. The queries do not
+// count against the daily query budget of the cluster. Errors are logged
+// and yield no values, as do the names that are not enabled clusters, so
+// that the names sent by clients do not grow the cache.
+func (c *Completer) recentValues(ctx context.Context, cfg *config.Config, cluster string) (namespaces, users []string) {
+	if !slices.Contains(cfg.AvailableClusterNames(), cluster) {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	values, ok := c.recent[cluster]
+	if !ok {
+		values = &recentValues{}
+		c.recent[cluster] = values
+	}
+	c.mu.Unlock()
+
+	values.mu.Lock()
+	defer values.mu.Unlock()
+	if time.Since(values.fetchedAt) < recentValuesTTL {
+		return values.namespaces, values.users
+	}
+	// Failures are cached too, so that a broken cluster is not queried on
+	// every keystroke.
+	values.fetchedAt = time.Now()
+	values.namespaces, values.users = nil, nil
+
+	p, err := cfg.GetProviderByName(cluster)
+	if err != nil {
+		log.Printf("complete recent values of cluster %s: %v", cluster, err)
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(guardrail.WithoutBudget(ctx), recentValuesTimeout)
+	defer cancel()
+	params := queryWindow(p, cluster, recentValuesWindow)
+	params.Limit = recentValuesLimit
+	result, err := p.QueryAuditLog(ctx, params)
+	if err != nil {
+		log.Printf("complete recent values of cluster %s: %v", cluster, err)
+		return nil, nil
+	}
+
+	for _, e := range result.Entries {
+		if e.ObjectRef != nil && e.ObjectRef.Namespace != "" {
+			values.namespaces = append(values.namespaces, e.ObjectRef.Namespace)
+		}
+		if e.User.Username != "" {
+			values.users = append(values.users, e.User.Username)
+		}
+	}
+	values.namespaces = sortedUnique(values.namespaces)
+	values.users = sortedUnique(values.users)
+	return values.namespaces, values.users
+}
+
+// resourceTypeNames returns the common resource types and their short names.
+func resourceTypeNames() []string {
+	var names []string
+	for _, resourceTypes := range commonResourceTypes {
+		names = append(names, resourceTypes...)
+	}
+	for name := range resourceMapping {
+		names = append(names, name)
+	}
+	return sortedUnique(names)
+}
+
+// serviceAccounts returns the names of the service accounts of the users,
+// limited to the namespace when it is set.
+func serviceAccounts(users []string, namespace string) []string {
+	var names []string
+	for _, user := range users {
+		rest, ok := strings.CutPrefix(user, "system:serviceaccount:")
+		if !ok {
+			continue
+		}
+		ns, name, ok := strings.Cut(rest, ":")
+		if !ok {
+			continue
+		}
+		if namespace == "" || ns == namespace {
+			names = append(names, name)
+		}
+	}
+	return sortedUnique(names)
+}
+
+// completion returns the candidates that start with the value, case
+// insensitively, at most maxCompletionValues of them.
+func completion(candidates []string, value string) *mcp.Completion {
+	prefix := strings.ToLower(value)
+	values := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), prefix) {
+			values = append(values, candidate)
+		}
+	}
+	result := &mcp.Completion{Values: values, Total: len(values)}
+	if len(values) > maxCompletionValues {
+		result.Values = values[:maxCompletionValues]
+		result.HasMore = true
+	}
+	return result
+}
+
+func sortedUnique(values []string) []string {
+	slices.Sort(values)
+	return slices.Compact(values)
+}
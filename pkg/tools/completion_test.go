@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	k8sauth "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8saudit "k8s.io/apiserver/pkg/apis/audit"
+)
+
+func TestCompleter_recentValues(t *testing.T) {
+	entries := []types.AuditLogEntry{
+		{User: k8sauth.UserInfo{Username: "system:serviceaccount:kube-system:coredns"}, ObjectRef: &k8saudit.ObjectReference{Namespace: "kube-system"}},
+		{User: k8sauth.UserInfo{Username: "alice"}, ObjectRef: &k8saudit.ObjectReference{Namespace: "default"}},
+		{User: k8sauth.UserInfo{Username: "alice"}, ObjectRef: &k8saudit.ObjectReference{}},
+	}
+	prod := &mockProvider{result: types.AuditLogResult{Entries: entries}}
+	dev := &mockProvider{
+		result:       types.AuditLogResult{Entries: entries},
+		capabilities: provider.Capabilities{MaxTimeRange: metav1.Duration{Duration: time.Hour}},
+	}
+	c := NewCompleter(newTestConfig(t, []string{"prod", "dev"}, prod, dev))
+
+	namespaces, users := c.recentValues(context.Background(), c.cfg, "prod")
+	assert.Equal(t, []string{"default", "kube-system"}, namespaces)
+	assert.Equal(t, []string{"alice", "system:serviceaccount:kube-system:coredns"}, users)
+	// The values are cached.
+	c.recentValues(context.Background(), c.cfg, "prod")
+	queries := prod.Queries()
+	if assert.Len(t, queries, 1) {
+		assert.Equal(t, recentValuesWindow, queries[0].EndTime.Sub(queries[0].StartTime.Time))
+		assert.Equal(t, recentValuesLimit, queries[0].Limit)
+	}
+
+	// The window is narrowed to the maximum time range of the cluster.
+	c.recentValues(context.Background(), c.cfg, "dev")
+	queries = dev.Queries()
+	if assert.Len(t, queries, 1) {
+		assert.Equal(t, time.Hour, queries[0].EndTime.Sub(queries[0].StartTime.Time))
+	}
+}
+
+func TestCompleter_recentValues_UnknownCluster(t *testing.T) {
+	p := &mockProvider{}
+	c := NewCompleter(newTestConfig(t, []string{"prod"}, p))
+
+	for _, cluster := range []string{"staging", "", "prod-2"} {
+		namespaces, users := c.recentValues(context.Background(), c.cfg, cluster)
+		assert.Nil(t, namespaces)
+		assert.Nil(t, users)
+	}
+	assert.Empty(t, c.recent)
+	assert.Empty(t, p.Queries())
+}
+
+func TestContextCluster(t *testing.T) {
+	cfg := newTestConfig(t, []string{"prod", "dev"}, &mockProvider{}, &mockProvider{})
+
+	tests := []struct {
+		name      string
+		arguments map[string]string
+		expected  string
+	}{
+		{name: "default cluster", expected: "prod"},
+		{name: "cluster", arguments: map[string]string{"cluster": "dev"}, expected: "dev"},
+		{name: "cluster_name", arguments: map[string]string{"cluster_name": "dev"}, expected: "dev"},
+		{name: "empty cluster", arguments: map[string]string{"cluster": ""}, expected: "prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, contextCluster(cfg, mcp.CompleteContext{Arguments: tt.arguments}))
+		})
+	}
+}
+
+func TestCompleter_complete(t *testing.T) {
+	p := &mockProvider{result: types.AuditLogResult{Entries: []types.AuditLogEntry{
+		{User: k8sauth.UserInfo{Username: "system:serviceaccount:kube-system:coredns"}, ObjectRef: &k8saudit.ObjectReference{Namespace: "kube-system"}},
+		{User: k8sauth.UserInfo{Username: "Alice"}, ObjectRef: &k8saudit.ObjectReference{Namespace: "default"}},
+	}}}
+	c := NewCompleter(newTestConfig(t, []string{"prod", "dev"}, p, &mockProvider{}))
+
+	tests := []struct {
+		name      string
+		argument  mcp.CompleteArgument
+		arguments map[string]string
+		expected  []string
+	}{
+		{name: "cluster", argument: mcp.CompleteArgument{Name: "cluster", Value: "d"}, expected: []string{"dev"}},
+		{name: "resource type", argument: mcp.CompleteArgument{Name: "resource_type", Value: "deploy"}, expected: []string{"deploy", "deployment", "deployments"}},
+		{name: "namespace", argument: mcp.CompleteArgument{Name: "namespace", Value: "kube"}, expected: []string{"kube-system"}},
+		{name: "user", argument: mcp.CompleteArgument{Name: "user", Value: "al"}, expected: []string{"Alice"}},
+		{name: "service account", argument: mcp.CompleteArgument{Name: "service_account"}, expected: []string{"coredns"}},
+		{
+			name:      "namespace of another cluster",
+			argument:  mcp.CompleteArgument{Name: "namespace"},
+			arguments: map[string]string{"cluster": "dev"},
+			expected:  []string{},
+		},
+		{
+			name:      "namespace of an unknown cluster",
+			argument:  mcp.CompleteArgument{Name: "namespace"},
+			arguments: map[string]string{"cluster": "staging"},
+			expected:  []string{},
+		},
+		{name: "unknown argument", argument: mcp.CompleteArgument{Name: "since"}, expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := c.complete(context.Background(), tt.argument, mcp.CompleteContext{Arguments: tt.arguments})
+			assert.Equal(t, tt.expected, result.Values)
+		})
+	}
+}
+
+func TestServiceAccounts(t *testing.T) {
+	users := []string{
+		"system:serviceaccount:kube-system:coredns",
+		"system:serviceaccount:default:builder",
+		"system:serviceaccount:kube-system:coredns",
+		"system:serviceaccount:invalid",
+		"system:node:node-1",
+		"alice",
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		expected  []string
+	}{
+		{name: "all namespaces", expected: []string{"builder", "coredns"}},
+		{name: "namespace", namespace: "kube-system", expected: []string{"coredns"}},
+		{name: "namespace without service accounts", namespace: "monitoring", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, serviceAccounts(users, tt.namespace))
+		})
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	candidates := []string{"default", "Dev", "kube-system", "kube-public"}
+
+	tests := []struct {
+		name     string
+		value    string
+		expected *mcp.Completion
+	}{
+		{name: "all", value: "", expected: &mcp.Completion{Values: candidates, Total: 4}},
+		{name: "prefix", value: "kube-", expected: &mcp.Completion{Values: []string{"kube-system", "kube-public"}, Total: 2}},
+		{name: "case insensitive", value: "de", expected: &mcp.Completion{Values: []string{"default", "Dev"}, Total: 2}},
+		{name: "no match", value: "system", expected: &mcp.Completion{Values: []string{}, Total: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, completion(candidates, tt.value))
+		})
+	}
+
+	var many []string
+	for i := range maxCompletionValues + 1 {
+		many = append(many, fmt.Sprintf("ns-%03d", i))
+	}
+	result := completion(many, "ns-")
+	assert.Len(t, result.Values, maxCompletionValues)
+	assert.Equal(t, maxCompletionValues+1, result.Total)
+	assert.True(t, result.HasMore)
+}
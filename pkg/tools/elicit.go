@@ -0,0 +1,303 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/mozillazg/kube-audit-mcp/pkg/utils"
+)
+
+// AmbiguousParameterError is the error of the AmbiguousParameter results.
+const AmbiguousParameterError = "ambiguous_parameter"
+
+// elicitationTimeout is how long the user has to answer an elicitation.
+const elicitationTimeout = 5 * time.Minute
+
+// AmbiguousParameter is the content of the error result of a query with an
+// ambiguous parameter, when the user could not be asked to choose. The
+// query should be repeated with one of the candidates.
+type AmbiguousParameter struct {
+	Error      string   `json:"error"`
+	Parameter  string   `json:"parameter"`
+	Value      string   `json:"value"`
+	Message    string   `json:"message"`
+	Candidates []string `json:"candidates"`
+}
+
+// ambiguity is a parameter that the user is asked to choose.
+type ambiguity struct {
+	parameter string
+	value     string
+	message   string
+	// candidates are the choices, the first one is the default.
+	candidates []string
+}
+
+// errDeclined is the error of an elicitation that the user declined or
+// cancelled.
+var errDeclined = errors.New("the user declined to choose")
+
+// resolveParams resolves the ambiguous cluster and resource types of the
+// params, by asking the user to choose when the client supports
+// elicitation. It returns an error result when a parameter stays ambiguous
+// or the user declined to choose.
+func (t *QueryAuditLogTool) resolveParams(ctx context.Context, params types.QueryAuditLogParams) (types.QueryAuditLogParams, *mcp.CallToolResult) {
+	if a := t.clusterAmbiguity(ctx, params.ClusterName); a != nil {
+		cluster, result := elicitChoice(ctx, *a)
+		if result != nil {
+			return params, result
+		}
+		if params.ClusterName == "" {
+			t.rememberCluster(ctx, cluster)
+		}
+		params.ClusterName = cluster
+	} else if params.ClusterName == "" {
+		params.ClusterName = t.rememberedCluster(ctx)
+	}
+
+	for i, resourceType := range params.ResourceTypes {
+		a := resourceTypeAmbiguity(resourceType)
+		if a == nil {
+			continue
+		}
+		choice, result := elicitChoice(ctx, *a)
+		if result != nil {
+			return params, result
+		}
+		params.ResourceTypes[i] = choice
+	}
+	return params, nil
+}
+
+// clusterAmbiguity returns the ambiguity of the cluster name. A name that is
+// not a name or an alias of an enabled cluster is ambiguous, the candidates
+// are the similar names, or all of them when none is similar. An omitted
+// name is ambiguous when there are several clusters and the client supports
+// elicitation, until the user chose one in the session, otherwise the
+// default cluster is queried.
+func (t *QueryAuditLogTool) clusterAmbiguity(ctx context.Context, name string) *ambiguity {
+	names := t.cfg.AvailableClusterNames()
+	if name == "" {
+		clusters := t.cfg.EnabledClusterNames()
+		if len(clusters) < 2 || !supportsElicitation(ctx) || t.rememberedCluster(ctx) != "" {
+			return nil
+		}
+		// The default cluster is the default choice.
+		slices.SortStableFunc(clusters, func(a, b string) int {
+			if a == t.cfg.DefaultCluster {
+				return -1
+			}
+			if b == t.cfg.DefaultCluster {
+				return 1
+			}
+			return 0
+		})
+		return &ambiguity{
+			parameter:  "cluster_name",
+			message:    "The cluster to query was not specified, which cluster should be queried?",
+			candidates: clusters,
+		}
+	}
+	if t.cfg.HasCluster(name) {
+		// Disabled clusters are reported by the provider lookup.
+		return nil
+	}
+
+	candidates := utils.Similar(name, names)
+	for _, n := range names {
+		if strings.Contains(strings.ToLower(n), strings.ToLower(name)) && !slices.Contains(candidates, n) {
+			candidates = append(candidates, n)
+		}
+	}
+	message := fmt.Sprintf("Cluster %q matches several clusters, which cluster should be queried?", name)
+	switch len(candidates) {
+	case 0:
+		candidates = t.cfg.EnabledClusterNames()
+		message = fmt.Sprintf("Cluster %q was not found, which cluster should be queried?", name)
+	case 1:
+		message = fmt.Sprintf("Cluster %q was not found, did you mean %s?", name, candidates[0])
+	}
+	return &ambiguity{parameter: "cluster_name", value: name, message: message, candidates: candidates}
+}
+
+// rememberedCluster returns the cluster that the user chose in the session
+// when the cluster was omitted, unless it was removed from the config.
+func (t *QueryAuditLogTool) rememberedCluster(ctx context.Context) string {
+	cluster := t.sessions.get(ctx)
+	if cluster == "" || !slices.Contains(t.cfg.AvailableClusterNames(), cluster) {
+		return ""
+	}
+	return cluster
+}
+
+func (t *QueryAuditLogTool) rememberCluster(ctx context.Context, cluster string) {
+	t.sessions.set(ctx, cluster)
+}
+
+// SessionClusters are the clusters that the users chose when the cluster
+// was omitted, by session ID. They are kept when the config is reloaded,
+// and removed when the session ends.
+type SessionClusters struct {
+	mu       sync.Mutex
+	clusters map[string]string
+}
+
+func NewSessionClusters() *SessionClusters {
+	return &SessionClusters{clusters: map[string]string{}}
+}
+
+func (s *SessionClusters) get(ctx context.Context) string {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clusters[session.SessionID()]
+}
+
+func (s *SessionClusters) set(ctx context.Context, cluster string) {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clusters[session.SessionID()] = cluster
+}
+
+// OnUnregisterSession is the server.OnUnregisterSessionHookFunc that
+// removes the cluster of the session that ended.
+func (s *SessionClusters) OnUnregisterSession(_ context.Context, session server.ClientSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clusters, session.SessionID())
+}
+
+// resourceTypeAmbiguity returns the ambiguity of a resource type that is not
+// a known resource type but is within a few edits of one, e.g. a typo or a
+// singular name. Other unknown resource types, e.g. custom resources and
+// subresources, are queried as they are. The resource type itself is the
+// last candidate.
+func resourceTypeAmbiguity(resourceType string) *ambiguity {
+	rt := strings.ToLower(resourceType)
+	if mapped, ok := resourceMapping[rt]; ok {
+		rt = mapped
+	}
+	known := knownResourceTypes()
+	if rt == "" || strings.Contains(rt, "/") || slices.Contains(known, rt) {
+		return nil
+	}
+
+	var candidates []string
+	for _, candidate := range known {
+		if utils.Levenshtein(rt, candidate) <= max(1, len(candidate)/4) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return &ambiguity{
+		parameter: "resource_types",
+		value:     resourceType,
+		message: fmt.Sprintf("Resource type %q is not a known resource type, did you mean %s?",
+			resourceType, strings.Join(candidates, " or ")),
+		candidates: append(candidates, resourceType),
+	}
+}
+
+// knownResourceTypes returns the common resource types, without the short
+// names.
+func knownResourceTypes() []string {
+	var names []string
+	for _, resourceTypes := range commonResourceTypes {
+		names = append(names, resourceTypes...)
+	}
+	return sortedUnique(names)
+}
+
+// elicitChoice asks the user to choose a candidate. It returns an
+// AmbiguousParameter error result when the client does not support
+// elicitation or the elicitation failed, and an error result when the user
+// declined to choose.
+func elicitChoice(ctx context.Context, a ambiguity) (string, *mcp.CallToolResult) {
+	choice, err := elicit(ctx, a)
+	switch {
+	case err == nil:
+		return choice, nil
+	case errors.Is(err, errDeclined):
+		return "", mcp.NewToolResultErrorf("The user declined to choose the %s parameter, the query was not run.", a.parameter)
+	}
+	if !errors.Is(err, errElicitationUnsupported) {
+		log.Printf("elicit %s: %v", a.parameter, err)
+	}
+
+	data, err := json.Marshal(AmbiguousParameter{
+		Error:      AmbiguousParameterError,
+		Parameter:  a.parameter,
+		Value:      a.value,
+		Message:    a.message + " Ask the user to choose one of the candidates, and repeat the query with it.",
+		Candidates: a.candidates,
+	})
+	if err != nil {
+		return "", mcp.NewToolResultError(a.message)
+	}
+	return "", mcp.NewToolResultError(string(data))
+}
+
+var errElicitationUnsupported = errors.New("the client does not support elicitation")
+
+func supportsElicitation(ctx context.Context) bool {
+	session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo)
+	return server.ServerFromContext(ctx) != nil && ok && session.GetClientCapabilities().Elicitation != nil
+}
+
+// elicit asks the user to choose one of the candidates.
+func elicit(ctx context.Context, a ambiguity) (string, error) {
+	if !supportsElicitation(ctx) {
+		return "", errElicitationUnsupported
+	}
+	ctx, cancel := context.WithTimeout(ctx, elicitationTimeout)
+	defer cancel()
+	result, err := server.ServerFromContext(ctx).RequestElicitation(ctx, mcp.ElicitationRequest{
+		Params: mcp.ElicitationParams{
+			Message: a.message,
+			RequestedSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"value": map[string]any{
+						"type":    "string",
+						"title":   a.parameter,
+						"enum":    a.candidates,
+						"default": a.candidates[0],
+					},
+				},
+				"required": []string{"value"},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if result.Action != mcp.ElicitationResponseActionAccept {
+		return "", errDeclined
+	}
+
+	content, _ := result.Content.(map[string]any)
+	choice, _ := content["value"].(string)
+	if !slices.Contains(a.candidates, choice) {
+		return "", fmt.Errorf("invalid choice %q", choice)
+	}
+	return choice, nil
+}
@@ -0,0 +1,170 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// newElicitTestConfig returns a config with the clusters prod-us (the
+// default, alias production), prod-eu, staging and the disabled legacy.
+func newElicitTestConfig(t *testing.T) *config.Config {
+	cfg := newTestConfig(t, []string{"prod-us", "prod-eu", "staging", "legacy"},
+		&mockProvider{}, &mockProvider{}, &mockProvider{}, &mockProvider{})
+	cfg.Clusters[0].Alias = []string{"production"}
+	cfg.Clusters[3].Disabled = true
+	return cfg
+}
+
+// withSession calls fn with the context of a tool call of the session.
+func withSession(t *testing.T, session *server.InProcessSession, fn func(ctx context.Context)) {
+	t.Helper()
+	s := server.NewMCPServer("test", "1.0.0", server.WithElicitation())
+	s.AddTool(mcp.NewTool("probe"), func(ctx context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		fn(ctx)
+		return mcp.NewToolResultText(""), nil
+	})
+	ctx := s.WithContext(context.Background(), session)
+	resp := s.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"probe"}}`))
+	if _, ok := resp.(mcp.JSONRPCResponse); !ok {
+		t.Fatalf("unexpected response: %#v", resp)
+	}
+}
+
+func newElicitationSession(id string) *server.InProcessSession {
+	session := server.NewInProcessSession(id, nil)
+	session.Initialize()
+	session.SetClientCapabilities(mcp.ClientCapabilities{Elicitation: &mcp.ElicitationCapability{}})
+	return session
+}
+
+func TestQueryAuditLogTool_clusterAmbiguity(t *testing.T) {
+	tool := NewQueryAuditLogTool(newElicitTestConfig(t), NewSessionClusters())
+
+	tests := []struct {
+		name     string
+		cluster  string
+		expected *ambiguity
+	}{
+		{name: "omitted without elicitation", cluster: "", expected: nil},
+		{name: "name", cluster: "prod-eu", expected: nil},
+		{name: "alias", cluster: "production", expected: nil},
+		{name: "disabled cluster", cluster: "legacy", expected: nil},
+		{
+			name:    "typo",
+			cluster: "stagin",
+			expected: &ambiguity{
+				parameter:  "cluster_name",
+				value:      "stagin",
+				message:    `Cluster "stagin" was not found, did you mean staging?`,
+				candidates: []string{"staging"},
+			},
+		},
+		{
+			name:    "several similar clusters",
+			cluster: "prod",
+			expected: &ambiguity{
+				parameter:  "cluster_name",
+				value:      "prod",
+				message:    `Cluster "prod" matches several clusters, which cluster should be queried?`,
+				candidates: []string{"prod-eu", "prod-us", "production"},
+			},
+		},
+		{
+			name:    "no similar cluster",
+			cluster: "qa",
+			expected: &ambiguity{
+				parameter:  "cluster_name",
+				value:      "qa",
+				message:    `Cluster "qa" was not found, which cluster should be queried?`,
+				candidates: []string{"prod-us", "prod-eu", "staging"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tool.clusterAmbiguity(context.Background(), tt.cluster))
+		})
+	}
+}
+
+func TestQueryAuditLogTool_clusterAmbiguity_Omitted(t *testing.T) {
+	sessions := NewSessionClusters()
+	tool := NewQueryAuditLogTool(newElicitTestConfig(t), sessions)
+	session := newElicitationSession("session-1")
+
+	withSession(t, session, func(ctx context.Context) {
+		assert.Equal(t, &ambiguity{
+			parameter:  "cluster_name",
+			message:    "The cluster to query was not specified, which cluster should be queried?",
+			candidates: []string{"prod-us", "prod-eu", "staging"},
+		}, tool.clusterAmbiguity(ctx, ""))
+
+		// The cluster that the user chose is remembered.
+		tool.rememberCluster(ctx, "staging")
+		assert.Nil(t, tool.clusterAmbiguity(ctx, ""))
+		assert.Equal(t, "staging", tool.rememberedCluster(ctx))
+	})
+
+	// The clusters are kept when the tools are rebuilt on reload, unless
+	// the cluster was removed.
+	withSession(t, session, func(ctx context.Context) {
+		tool := NewQueryAuditLogTool(newElicitTestConfig(t), sessions)
+		assert.Equal(t, "staging", tool.rememberedCluster(ctx))
+
+		cfg := newTestConfig(t, []string{"prod-us", "prod-eu"}, &mockProvider{}, &mockProvider{})
+		tool = NewQueryAuditLogTool(cfg, sessions)
+		assert.Equal(t, "", tool.rememberedCluster(ctx))
+		assert.NotNil(t, tool.clusterAmbiguity(ctx, ""))
+	})
+
+	// The cluster is removed when the session ends.
+	sessions.OnUnregisterSession(context.Background(), session)
+	assert.Empty(t, sessions.clusters)
+}
+
+func TestResourceTypeAmbiguity(t *testing.T) {
+	tests := []struct {
+		name         string
+		resourceType string
+		expected     *ambiguity
+	}{
+		{name: "resource type", resourceType: "deployments", expected: nil},
+		{name: "short name", resourceType: "deploy", expected: nil},
+		{name: "singular name", resourceType: "Pod", expected: nil},
+		{name: "unknown resource type", resourceType: "certificates", expected: nil},
+		{name: "subresource", resourceType: "pods/exec", expected: nil},
+		{name: "empty", resourceType: "", expected: nil},
+		{
+			name:         "typo",
+			resourceType: "secrts",
+			expected: &ambiguity{
+				parameter:  "resource_types",
+				value:      "secrts",
+				message:    `Resource type "secrts" is not a known resource type, did you mean secrets?`,
+				candidates: []string{"secrets", "secrts"},
+			},
+		},
+		{
+			name:         "several similar resource types",
+			resourceType: "podes",
+			expected: &ambiguity{
+				parameter:  "resource_types",
+				value:      "podes",
+				message:    `Resource type "podes" is not a known resource type, did you mean nodes or pods?`,
+				candidates: []string{"nodes", "pods", "podes"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, resourceTypeAmbiguity(tt.resourceType))
+		})
+	}
+}
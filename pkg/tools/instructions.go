@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+)
+
+// maxDescribedClusters is the number of clusters that are described in the
+// description of query_audit_log, only the names of more clusters are
+// listed.
+const maxDescribedClusters = 20
+
+// Instructions sets the MCP instructions of the server to the instructions
+// of the config when a client initializes a session, so that the
+// instructions of a reloaded config apply to the new sessions.
+type Instructions struct {
+	mu  sync.Mutex
+	cfg *config.Config
+}
+
+func NewInstructions(cfg *config.Config) *Instructions {
+	return &Instructions{cfg: cfg}
+}
+
+// SetConfig replaces the config when it is reloaded.
+func (i *Instructions) SetConfig(cfg *config.Config) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.cfg = cfg
+}
+
+// AfterInitialize is the server.OnAfterInitializeFunc that sets the
+// instructions of the initialize result.
+func (i *Instructions) AfterInitialize(_ context.Context, _ any, _ *mcp.InitializeRequest, result *mcp.InitializeResult) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	result.Instructions = strings.TrimSpace(i.cfg.Instructions)
+}
+
+// clustersDescription describes the enabled clusters for the description of
+// query_audit_log: their aliases, descriptions, providers and the
+// limitations of the providers, e.g. the filters that are not supported.
+func clustersDescription(cfg *config.Config) string {
+	var clusters []ClusterInfo
+	for _, info := range NewListClustersTool(cfg).result().Clusters {
+		if !info.Disabled {
+			clusters = append(clusters, info)
+		}
+	}
+	if len(clusters) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("Clusters:\n")
+	for i, info := range clusters {
+		if i == maxDescribedClusters {
+			var names []string
+			for _, info := range clusters[i:] {
+				names = append(names, info.Name)
+			}
+			fmt.Fprintf(&b, "- %s, call 'list_clusters()' for their descriptions.\n", strings.Join(names, ", "))
+			break
+		}
+
+		var attributes []string
+		if info.Name == cfg.DefaultCluster {
+			attributes = append(attributes, "default")
+		}
+		if len(info.Alias) > 0 {
+			attributes = append(attributes, "alias: "+strings.Join(info.Alias, ", "))
+		}
+		line := "- " + info.Name
+		if len(attributes) > 0 {
+			line += " (" + strings.Join(attributes, "; ") + ")"
+		}
+		line += ":"
+		if description := strings.TrimSpace(info.Description); description != "" {
+			line += " " + strings.Join(strings.Fields(description), " ")
+			if !strings.HasSuffix(line, ".") {
+				line += "."
+			}
+		}
+		line += fmt.Sprintf(" Provider: %s.", info.Provider)
+		if info.Capabilities != nil {
+			if limitations := capabilitiesText(*info.Capabilities); limitations != "" {
+				line += " " + limitations
+			}
+		}
+		b.WriteString(line + "\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// capabilitiesText describes the limitations of the capabilities in a few
+// sentences, it is empty when the provider supports everything.
+func capabilitiesText(c provider.Capabilities) string {
+	var ignored []string
+	for _, filter := range provider.AllFilters {
+		if c.FilterSupport(filter) == provider.FilterIgnored {
+			ignored = append(ignored, filter)
+		}
+	}
+
+	var sentences []string
+	if len(ignored) > 0 {
+		sentences = append(sentences, fmt.Sprintf("Unsupported filters: %s.", strings.Join(ignored, ", ")))
+	}
+	if len(c.ClientSideFilters) > 0 {
+		sentences = append(sentences, fmt.Sprintf("Filters applied client-side: %s.", strings.Join(c.ClientSideFilters, ", ")))
+	}
+	if c.ResponseStatus != "" && c.ResponseStatus != provider.FieldRecorded {
+		sentences = append(sentences, fmt.Sprintf("Response status: %s.", c.ResponseStatus))
+	}
+	if c.Bodies != "" && c.Bodies != provider.FieldRecorded {
+		sentences = append(sentences, fmt.Sprintf("Request and response objects: %s.", c.Bodies))
+	}
+	if c.MaxTimeRange.Duration > 0 {
+		sentences = append(sentences, fmt.Sprintf("Maximum time range of a query: %s.", c.MaxTimeRange.Duration))
+	}
+	if c.MaxEvents > 0 {
+		sentences = append(sentences, fmt.Sprintf("Maximum events of a query: %d.", c.MaxEvents))
+	}
+	sentences = append(sentences, c.Notes...)
+	return strings.Join(sentences, " ")
+}
@@ -2,9 +2,12 @@ package tools
 
 import (
 	"context"
+	"slices"
+
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
 )
 
 type ListClustersTool struct {
@@ -24,6 +27,9 @@ type ClusterInfo struct {
 	Alias       []string `json:"alias,omitempty"`
 	Disabled    bool     `json:"disabled"`
 	Provider    string   `json:"provider"`
+	// Capabilities are the features of the provider of the cluster, they
+	// are omitted for disabled clusters.
+	Capabilities *provider.Capabilities `json:"capabilities,omitempty"`
 }
 
 func NewListClustersTool(cfg *config.Config) *ListClustersTool {
@@ -48,18 +54,49 @@ func NewListClustersTool(cfg *config.Config) *ListClustersTool {
 }
 
 func (t *ListClustersTool) Register(s *server.MCPServer) {
-	s.AddTool(t.newTool(), t.handle)
+	s.AddTools(t.ServerTool())
+}
+
+func (t *ListClustersTool) ServerTool() server.ServerTool {
+	return server.ServerTool{Tool: t.newTool(), Handler: t.handle}
 }
 
 func (t *ListClustersTool) handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	result := t.clusters
+	result := t.result()
+	return mcp.NewToolResultStructured(result, clustersResultText(result)), nil
+}
 
-	return mcp.NewToolResultStructuredOnly(result), nil
+// result returns the clusters, with the capabilities of the providers of
+// the enabled clusters.
+func (t *ListClustersTool) result() ClustersResult {
+	result := t.clusters
+	result.Clusters = slices.Clone(result.Clusters)
+	for i, info := range result.Clusters {
+		if info.Disabled {
+			continue
+		}
+		p, err := t.cfg.GetProviderByName(info.Name)
+		if err != nil {
+			continue
+		}
+		capabilities := p.Capabilities()
+		result.Clusters[i].Capabilities = &capabilities
+	}
+	return result
 }
 
 func (t *ListClustersTool) newTool() mcp.Tool {
 	return mcp.NewTool("list_clusters",
 		mcp.WithDescription(
-			`List all configured clusters in the MCP server.`),
+			`List all configured clusters in the MCP server.
+The capabilities of each cluster describe which filters are supported by its provider,
+whether the response status and the request/response objects of the audit events are
+recorded or inferred, the maximum time range of a query and other limitations.`),
+		mcp.WithTitleAnnotation("List clusters"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithRawOutputSchema(clustersResultSchema),
 	)
 }
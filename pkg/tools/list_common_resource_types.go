@@ -38,7 +38,11 @@ var commonResourceTypes = map[string][]string{
 }
 
 func (t *ListCommonResourceTypesTool) Register(s *server.MCPServer) {
-	s.AddTool(t.newTool(), t.handle)
+	s.AddTools(t.ServerTool())
+}
+
+func (t *ListCommonResourceTypesTool) ServerTool() server.ServerTool {
+	return server.ServerTool{Tool: t.newTool(), Handler: t.handle}
 }
 
 func (t *ListCommonResourceTypesTool) handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -47,7 +51,7 @@ func (t *ListCommonResourceTypesTool) handle(ctx context.Context, req mcp.CallTo
 		resourceTypes[category] = types
 	}
 
-	return mcp.NewToolResultStructuredOnly(resourceTypes), nil
+	return mcp.NewToolResultStructured(resourceTypes, resourceTypesText(resourceTypes)), nil
 }
 
 func (t *ListCommonResourceTypesTool) newTool() mcp.Tool {
@@ -56,5 +60,11 @@ func (t *ListCommonResourceTypesTool) newTool() mcp.Tool {
 			`List common Kubernetes resource types to help select the correct resource_type parameter.
 
 Return a list of common K8s resource types grouped by category.`),
+		mcp.WithTitleAnnotation("List common resource types"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithRawOutputSchema(resourceTypesSchema),
 	)
 }
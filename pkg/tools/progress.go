@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+)
+
+// logger is the logger of the MCP log messages.
+const logger = "kube-audit-mcp"
+
+// clientReporter sends the progress of the queries of a tool call to the
+// client as progress notifications, when the client asked for them with a
+// progress token, and the status messages as log messages.
+type clientReporter struct {
+	ctx   context.Context
+	s     *server.MCPServer
+	token mcp.ProgressToken
+
+	mu sync.Mutex
+	// progress is the progress of the last notification, it increases with
+	// each notification.
+	progress float64
+}
+
+var _ provider.Reporter = (*clientReporter)(nil)
+
+// withClientReporter returns a context that reports the progress and the
+// status messages of the queries of the request to the client.
+func withClientReporter(ctx context.Context, req mcp.CallToolRequest) (context.Context, *clientReporter) {
+	s := server.ServerFromContext(ctx)
+	if s == nil {
+		return ctx, nil
+	}
+	r := &clientReporter{ctx: ctx, s: s}
+	if req.Params.Meta != nil {
+		r.token = req.Params.Meta.ProgressToken
+	}
+	return provider.WithReporter(ctx, r), r
+}
+
+func (r *clientReporter) Progress(progress provider.Progress) {
+	message := progress.Message
+	if progress.RecordsScanned > 0 {
+		message += fmt.Sprintf(", scanned %d records", progress.RecordsScanned)
+	}
+	if progress.RecordsMatched > 0 {
+		message += fmt.Sprintf(", matched %d records so far", progress.RecordsMatched)
+	}
+	r.notifyProgress(message)
+}
+
+func (r *clientReporter) Status(level, message string) {
+	notification := mcp.NewLoggingMessageNotification(mcp.LoggingLevel(level), logger, message)
+	if err := r.s.SendLogMessageToClient(r.ctx, notification); err != nil {
+		log.Printf("send log message: %v", err)
+	}
+	r.notifyProgress(message)
+}
+
+// notifyProgress sends a progress notification with the message, if the
+// client asked for them.
+func (r *clientReporter) notifyProgress(message string) {
+	if r.token == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.progress++
+	notification := mcp.NewProgressNotification(r.token, r.progress, nil, &message)
+	err := r.s.SendNotificationToClient(r.ctx, notification.Method, map[string]any{
+		"progressToken": notification.Params.ProgressToken,
+		"progress":      notification.Params.Progress,
+		"message":       notification.Params.Message,
+	})
+	if err != nil {
+		log.Printf("send progress notification: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/mozillazg/kube-audit-mcp/pkg/prompts"
+)
+
+// NewServerPrompts returns the prompts of the prompt catalog of cfg.
+func NewServerPrompts(cfg *config.Config) []server.ServerPrompt {
+	catalog := cfg.PromptCatalog()
+	serverPrompts := make([]server.ServerPrompt, 0, len(catalog))
+	for _, p := range catalog {
+		serverPrompts = append(serverPrompts, newServerPrompt(p))
+	}
+	return serverPrompts
+}
+
+func newServerPrompt(p *prompts.Prompt) server.ServerPrompt {
+	opts := []mcp.PromptOption{mcp.WithPromptDescription(p.Description)}
+	for _, arg := range p.Arguments {
+		argOpts := []mcp.ArgumentOption{mcp.ArgumentDescription(arg.Description)}
+		if arg.Required {
+			argOpts = append(argOpts, mcp.RequiredArgument())
+		}
+		opts = append(opts, mcp.WithArgument(arg.Name, argOpts...))
+	}
+
+	return server.ServerPrompt{
+		Prompt: mcp.NewPrompt(p.Name, opts...),
+		Handler: func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			text, err := p.Render(req.Params.Arguments)
+			if err != nil {
+				return nil, err
+			}
+			return mcp.NewGetPromptResult(p.Description, []mcp.PromptMessage{
+				mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text)),
+			}), nil
+		},
+	}
+}
@@ -10,12 +10,14 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
 	"github.com/mozillazg/kube-audit-mcp/pkg/types"
 )
 
 type QueryAuditLogTool struct {
-	params types.QueryAuditLogParams
-	cfg    *config.Config
+	params   types.QueryAuditLogParams
+	cfg      *config.Config
+	sessions *SessionClusters
 }
 
 var resourceMapping = map[string]string{
@@ -52,12 +54,20 @@ const auditLogResultNote = `Notes:
   Therefore, to audit the true actor, you must refer to the 'ImpersonatedUser' field, if it is present in the log entry.
 `
 
-func NewQueryAuditLogTool(cfg *config.Config) *QueryAuditLogTool {
-	return &QueryAuditLogTool{cfg: cfg}
+const partialResultNote = `- The query did not complete within the query timeout, the entries are partial results.
+  Narrow the time range or add filters to get complete results.
+`
+
+func NewQueryAuditLogTool(cfg *config.Config, sessions *SessionClusters) *QueryAuditLogTool {
+	return &QueryAuditLogTool{cfg: cfg, sessions: sessions}
 }
 
 func (t *QueryAuditLogTool) Register(s *server.MCPServer) {
-	s.AddTool(t.newTool(), t.handle)
+	s.AddTools(t.ServerTool())
+}
+
+func (t *QueryAuditLogTool) ServerTool() server.ServerTool {
+	return server.ServerTool{Tool: t.newTool(), Handler: t.handle}
 }
 
 func (t *QueryAuditLogTool) handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -66,22 +76,81 @@ func (t *QueryAuditLogTool) handle(ctx context.Context, req mcp.CallToolRequest)
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
+	input, errResult := t.resolveParams(ctx, input)
+	if errResult != nil {
+		return errResult, nil
+	}
 	input = t.normalizeParams(input)
+	summarizeEvents := req.GetBool("summarize", false)
 	p, err := t.cfg.GetProviderByName(input.ClusterName)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	result, err := p.QueryAuditLog(ctx, input)
+	ctx, reporter := withClientReporter(ctx, req)
+	if reporter != nil {
+		reporter.notifyProgress(fmt.Sprintf("querying the audit logs of cluster %s", input.ClusterName))
+	}
+	query := input
+	if summarizeEvents {
+		query.Limit = summarizeLimit
+	}
+	result, err := p.QueryAuditLog(ctx, query)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 	result.Params = input
+	if summarizeEvents {
+		maxEvents := query.Limit
+		if n := p.Capabilities().MaxEvents; n > 0 && n < maxEvents {
+			maxEvents = n
+		}
+		result = summarize(ctx, result, input.Limit, maxEvents)
+	}
 	if len(result.Entries) > 0 {
 		result.Note = auditLogResultNote
 	}
+	if result.Partial {
+		if result.Note == "" {
+			result.Note = "Notes:\n"
+		}
+		result.Note += partialResultNote
+	}
+	if note := filterNote(p.Capabilities(), input); note != "" {
+		if result.Note == "" {
+			result.Note = "Notes:\n"
+		}
+		result.Note += note
+	}
 
-	return mcp.NewToolResultStructuredOnly(result), nil
+	return mcp.NewToolResultStructured(result, auditLogResultText(result)), nil
+}
+
+// filterNote warns about the requested filters that the provider does not
+// apply in the query of the backend.
+func filterNote(capabilities provider.Capabilities, params types.QueryAuditLogParams) string {
+	var clientSide, ignored []string
+	for _, filter := range provider.RequestedFilters(params) {
+		switch capabilities.FilterSupport(filter) {
+		case provider.FilterAppliedClientSide:
+			clientSide = append(clientSide, filter)
+		case provider.FilterIgnored:
+			ignored = append(ignored, filter)
+		}
+	}
+
+	var note string
+	if len(clientSide) > 0 {
+		note += fmt.Sprintf("- The filters %s were applied client-side to the events returned by the provider,\n"+
+			"  so fewer events than the limit may be returned even though more events match.\n",
+			strings.Join(clientSide, ", "))
+	}
+	if len(ignored) > 0 {
+		note += fmt.Sprintf("- The filters %s are not supported by the provider of cluster %s and were ignored,\n"+
+			"  the entries are not filtered by them.\n",
+			strings.Join(ignored, ", "), params.ClusterName)
+	}
+	return note
 }
 
 func (t *QueryAuditLogTool) normalizeParams(params types.QueryAuditLogParams) types.QueryAuditLogParams {
@@ -126,9 +195,21 @@ func (t *QueryAuditLogTool) normalizeParams(params types.QueryAuditLogParams) ty
 	return params
 }
 
+// newTool returns the tool, its description describes the clusters of the
+// config and the limitations of their providers.
 func (t *QueryAuditLogTool) newTool() mcp.Tool {
+	description := `Query Kubernetes (k8s) audit logs.`
+	if clusters := clustersDescription(t.cfg); clusters != "" {
+		description += "\n\n" + clusters
+	}
 	return mcp.NewTool("query_audit_log",
-		mcp.WithDescription(`Query Kubernetes (k8s) audit logs.`),
+		mcp.WithDescription(description),
+		mcp.WithTitleAnnotation("Query audit logs"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(true),
+		mcp.WithRawOutputSchema(auditLogResultSchema),
 		mcp.WithString("namespace",
 			mcp.Description(`(Optional) Match by namespace. 
 
@@ -207,6 +288,14 @@ Supported formats:
 			mcp.Max(20),
 			mcp.DefaultNumber(10),
 		),
+		mcp.WithBoolean("summarize",
+			mcp.Description(fmt.Sprintf(`(Optional) Summarize the matching events instead of returning them.
+
+Fetches up to %d of the latest matching events, groups them by user, verb, resource, namespace and response code,
+and summarizes them. The entries of the result are the representative events of the summary, at most the limit.
+Use it to get an overview of a busy time range before querying the details.`, summarizeLimit)),
+			mcp.DefaultBool(false),
+		),
 		mcp.WithString("cluster_name",
 			mcp.Description(fmt.Sprintf(`(Optional) The name of the cluster to query audit logs from.
 
@@ -0,0 +1,27 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryAuditLogTool_IgnoresAuditID(t *testing.T) {
+	p := &mockProvider{}
+	tool := NewQueryAuditLogTool(newTestConfig(t, []string{"prod"}, p), NewSessionClusters())
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "query_audit_log"
+	req.Params.Arguments = map[string]any{"audit_id": "a1", "user": "alice"}
+
+	result, err := tool.handle(context.Background(), req)
+	assert.NoError(t, err)
+	assert.False(t, result.IsError)
+
+	queries := p.Queries()
+	if assert.Len(t, queries, 1) {
+		assert.Equal(t, "alice", queries[0].User)
+		assert.Empty(t, queries[0].AuditID, "audit_id is not an argument of the tool")
+	}
+}
@@ -0,0 +1,291 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	k8saudit "k8s.io/apiserver/pkg/apis/audit"
+)
+
+const (
+	ClustersResourceURI     = "kube-audit://clusters"
+	EventResourceTemplate   = "kube-audit://{cluster}/events/{auditID}"
+	HistoryResourceTemplate = "kube-audit://{cluster}/objects/{group}/{resource}/{namespace}/{name}/history"
+
+	// CoreGroup is the group of the history URI of the objects of the core
+	// API group, e.g. pods.
+	CoreGroup = "core"
+	// ClusterScopedNamespace is the namespace of the history URI of
+	// cluster-scoped objects, e.g. nodes.
+	ClusterScopedNamespace = "-"
+)
+
+const (
+	// resourceLookback is the time window of the queries of the resources,
+	// it is narrowed to the maximum time range of the cluster.
+	resourceLookback = 7 * 24 * time.Hour
+	// eventQueryLimit is the limit of the query of an event, an event may be
+	// logged once per stage.
+	eventQueryLimit = 10
+	historyLimit    = 20
+)
+
+var historyVerbs = []string{"create", "update", "patch", "delete"}
+
+// EventResult is the content of an event resource.
+type EventResult struct {
+	Cluster    string              `json:"cluster"`
+	Event      types.AuditLogEntry `json:"event"`
+	Redactions []types.Redaction   `json:"redactions,omitempty"`
+	// HistoryURI is the history resource of the object of the event.
+	HistoryURI string `json:"history_uri,omitempty"`
+}
+
+// HistoryResult is the content of a history resource.
+type HistoryResult struct {
+	Cluster   string    `json:"cluster"`
+	Group     string    `json:"group"`
+	Resource  string    `json:"resource"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	// Events are the changes of the object, oldest first.
+	Events  []HistoryEvent `json:"events"`
+	Partial bool           `json:"partial,omitempty"`
+	Note    string         `json:"note,omitempty"`
+}
+
+type HistoryEvent struct {
+	URI     string    `json:"uri"`
+	AuditID string    `json:"audit_id"`
+	Time    time.Time `json:"time"`
+	Verb    string    `json:"verb"`
+	User    string    `json:"user"`
+	Code    int32     `json:"code,omitempty"`
+}
+
+// NewServerResources returns all resources of the MCP server.
+func NewServerResources(cfg *config.Config) []server.ServerResource {
+	clusters := NewListClustersTool(cfg)
+	return []server.ServerResource{
+		{
+			Resource: mcp.NewResource(ClustersResourceURI, "clusters",
+				mcp.WithResourceDescription("The configured clusters and the capabilities of their providers."),
+				mcp.WithMIMEType("application/json"),
+			),
+			Handler: func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+				return jsonContents(req.Params.URI, clusters.result())
+			},
+		},
+	}
+}
+
+// NewServerResourceTemplates returns all resource templates of the MCP
+// server.
+func NewServerResourceTemplates(cfg *config.Config) []server.ServerResourceTemplate {
+	r := &auditResources{cfg: cfg}
+	return []server.ServerResourceTemplate{
+		{
+			Template: mcp.NewResourceTemplate(EventResourceTemplate, "audit-event",
+				mcp.WithTemplateDescription(fmt.Sprintf(
+					`An audit event of a cluster by its audit ID, looked up in the last %s.`, formatLookback())),
+				mcp.WithTemplateMIMEType("application/json"),
+			),
+			Handler: r.handleEvent,
+		},
+		{
+			Template: mcp.NewResourceTemplate(HistoryResourceTemplate, "object-history",
+				mcp.WithTemplateDescription(fmt.Sprintf(
+					`The changes (create, update, patch and delete) of an object in the last %s, oldest first.
+The group of the core API group is %q, and the namespace of cluster-scoped objects is %q,
+e.g. kube-audit://prod/objects/core/pods/default/nginx/history.`,
+					formatLookback(), CoreGroup, ClusterScopedNamespace)),
+				mcp.WithTemplateMIMEType("application/json"),
+			),
+			Handler: r.handleHistory,
+		},
+	}
+}
+
+// EventURI returns the URI of the event resource.
+func EventURI(cluster, auditID string) string {
+	return fmt.Sprintf("kube-audit://%s/events/%s", cluster, auditID)
+}
+
+// HistoryURI returns the URI of the history resource of the object, or ""
+// when the reference does not name an object.
+func HistoryURI(cluster string, ref *k8saudit.ObjectReference) string {
+	if ref == nil || ref.Resource == "" || ref.Name == "" {
+		return ""
+	}
+	group, namespace := ref.APIGroup, ref.Namespace
+	if group == "" {
+		group = CoreGroup
+	}
+	if namespace == "" {
+		namespace = ClusterScopedNamespace
+	}
+	return fmt.Sprintf("kube-audit://%s/objects/%s/%s/%s/%s/history",
+		cluster, group, ref.Resource, namespace, ref.Name)
+}
+
+type auditResources struct {
+	cfg *config.Config
+}
+
+func (r *auditResources) handleEvent(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	cluster, auditID := templateArg(req, "cluster"), templateArg(req, "auditID")
+	p, err := r.cfg.GetProviderByName(cluster)
+	if err != nil {
+		return nil, err
+	}
+	if p.Capabilities().FilterSupport(provider.FilterAuditID) == provider.FilterIgnored {
+		return nil, fmt.Errorf("the provider of cluster %s does not support looking up events by audit ID", cluster)
+	}
+
+	params := queryWindow(p, cluster, resourceLookback)
+	params.AuditID = auditID
+	params.Limit = eventQueryLimit
+	result, err := p.QueryAuditLog(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// The entries are newest first, so the first one is the latest stage.
+	i := slices.IndexFunc(result.Entries, func(e types.AuditLogEntry) bool {
+		return string(e.AuditID) == auditID
+	})
+	if i < 0 {
+		return nil, fmt.Errorf("audit event %s not found in cluster %s in the last %s",
+			auditID, cluster, params.EndTime.Sub(params.StartTime.Time))
+	}
+	event := result.Entries[i]
+	out := EventResult{
+		Cluster:    cluster,
+		Event:      event,
+		HistoryURI: HistoryURI(cluster, event.ObjectRef),
+	}
+	for _, redaction := range result.Redactions {
+		if redaction.AuditID == auditID {
+			out.Redactions = append(out.Redactions, redaction)
+		}
+	}
+	return jsonContents(req.Params.URI, out)
+}
+
+func (r *auditResources) handleHistory(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	cluster := templateArg(req, "cluster")
+	out := HistoryResult{
+		Cluster:   cluster,
+		Group:     templateArg(req, "group"),
+		Resource:  templateArg(req, "resource"),
+		Namespace: templateArg(req, "namespace"),
+		Name:      templateArg(req, "name"),
+	}
+	group, namespace := coreGroupAsEmpty(out.Group), out.Namespace
+	if namespace == ClusterScopedNamespace {
+		namespace = ""
+		out.Namespace = ""
+	}
+
+	p, err := r.cfg.GetProviderByName(cluster)
+	if err != nil {
+		return nil, err
+	}
+	params := queryWindow(p, cluster, resourceLookback)
+	params.Namespace = namespace
+	params.ResourceTypes = []string{out.Resource}
+	params.ResourceName = out.Name
+	params.Verbs = historyVerbs
+	params.Limit = historyLimit
+	result, err := p.QueryAuditLog(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	out.StartTime, out.EndTime = params.StartTime.Time, params.EndTime.Time
+	out.Partial = result.Partial
+	out.Events = []HistoryEvent{}
+	for _, e := range result.Entries {
+		ref := e.ObjectRef
+		if ref == nil || coreGroupAsEmpty(ref.APIGroup) != group || ref.Namespace != namespace || ref.Name != out.Name {
+			continue
+		}
+		event := HistoryEvent{
+			URI:     EventURI(cluster, string(e.AuditID)),
+			AuditID: string(e.AuditID),
+			Time:    e.RequestReceivedTimestamp.Time,
+			Verb:    e.Verb,
+			User:    e.User.Username,
+		}
+		if e.ResponseStatus != nil {
+			event.Code = e.ResponseStatus.Code
+		}
+		out.Events = append(out.Events, event)
+	}
+	slices.Reverse(out.Events)
+	if len(result.Entries) >= historyLimit {
+		out.Note = fmt.Sprintf("Only the latest %d changes are listed, use the query_audit_log tool "+
+			"with a narrower time range to find the earlier changes.", historyLimit)
+	}
+	return jsonContents(req.Params.URI, out)
+}
+
+// coreGroupAsEmpty returns "" for the core API group, which is "" in the
+// audit events of kube-apiserver and "core" in the audit logs of GKE.
+func coreGroupAsEmpty(group string) string {
+	if group == CoreGroup {
+		return ""
+	}
+	return group
+}
+
+// queryWindow returns the params of a query of the last lookback, narrowed
+// to the maximum time range of the provider.
+func queryWindow(p provider.Provider, cluster string, lookback time.Duration) types.QueryAuditLogParams {
+	if maxRange := p.Capabilities().MaxTimeRange.Duration; maxRange > 0 && maxRange < lookback {
+		lookback = maxRange
+	}
+	end := time.Now().UTC()
+	return types.QueryAuditLogParams{
+		ClusterName: cluster,
+		StartTime:   types.NewTimeParam(end.Add(-lookback)),
+		EndTime:     types.NewTimeParam(end),
+	}
+}
+
+// templateArg returns the value of the variable of the URI template.
+func templateArg(req mcp.ReadResourceRequest, name string) string {
+	switch v := req.Params.Arguments[name].(type) {
+	case []string:
+		if len(v) > 0 {
+			return v[0]
+		}
+	case string:
+		return v
+	}
+	return ""
+}
+
+func jsonContents(uri string, v any) ([]mcp.ResourceContents, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{URI: uri, MIMEType: "application/json", Text: string(data)},
+	}, nil
+}
+
+func formatLookback() string {
+	return fmt.Sprintf("%d days", int(resourceLookback/(24*time.Hour)))
+}
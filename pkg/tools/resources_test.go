@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	k8sauth "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	k8saudit "k8s.io/apiserver/pkg/apis/audit"
+)
+
+func TestAuditResources_handleHistory(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := func(auditID, verb string, ref k8saudit.ObjectReference) types.AuditLogEntry {
+		return types.AuditLogEntry{
+			AuditID:                  k8stypes.UID(auditID),
+			Verb:                     verb,
+			User:                     k8sauth.UserInfo{Username: "alice"},
+			ObjectRef:                &ref,
+			RequestReceivedTimestamp: metav1.NewMicroTime(ts),
+		}
+	}
+
+	tests := []struct {
+		name     string
+		group    string
+		entries  []types.AuditLogEntry
+		expected []string
+	}{
+		{
+			name:  "kube-apiserver core group",
+			group: CoreGroup,
+			entries: []types.AuditLogEntry{
+				entry("a2", "patch", k8saudit.ObjectReference{Resource: "pods", Namespace: "default", Name: "nginx"}),
+				entry("a1", "create", k8saudit.ObjectReference{Resource: "pods", Namespace: "default", Name: "nginx"}),
+			},
+			expected: []string{"a1", "a2"},
+		},
+		{
+			name:  "gke core group",
+			group: CoreGroup,
+			entries: []types.AuditLogEntry{
+				entry("a2", "patch", k8saudit.ObjectReference{APIGroup: "core", APIVersion: "v1", Resource: "pods", Namespace: "default", Name: "nginx"}),
+				entry("a1", "create", k8saudit.ObjectReference{APIGroup: "core", APIVersion: "v1", Resource: "pods", Namespace: "default", Name: "nginx"}),
+			},
+			expected: []string{"a1", "a2"},
+		},
+		{
+			name:  "other objects",
+			group: CoreGroup,
+			entries: []types.AuditLogEntry{
+				entry("a3", "patch", k8saudit.ObjectReference{APIGroup: "apps", Resource: "pods", Namespace: "default", Name: "nginx"}),
+				entry("a2", "patch", k8saudit.ObjectReference{Resource: "pods", Namespace: "kube-system", Name: "nginx"}),
+				entry("a1", "patch", k8saudit.ObjectReference{Resource: "pods", Namespace: "default", Name: "nginx-2"}),
+			},
+			expected: []string{},
+		},
+		{
+			name:  "named group",
+			group: "apps",
+			entries: []types.AuditLogEntry{
+				entry("a2", "patch", k8saudit.ObjectReference{Resource: "pods", Namespace: "default", Name: "nginx"}),
+				entry("a1", "patch", k8saudit.ObjectReference{APIGroup: "apps", Resource: "pods", Namespace: "default", Name: "nginx"}),
+			},
+			expected: []string{"a1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &mockProvider{result: types.AuditLogResult{Entries: tt.entries}}
+			r := &auditResources{cfg: newTestConfig(t, []string{"prod"}, p)}
+			req := mcp.ReadResourceRequest{Params: mcp.ReadResourceParams{
+				URI: "kube-audit://prod/objects/" + tt.group + "/pods/default/nginx/history",
+				Arguments: map[string]any{
+					"cluster":   []string{"prod"},
+					"group":     []string{tt.group},
+					"resource":  []string{"pods"},
+					"namespace": []string{"default"},
+					"name":      []string{"nginx"},
+				},
+			}}
+
+			contents, err := r.handleHistory(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out HistoryResult
+			if err := json.Unmarshal([]byte(contents[0].(mcp.TextResourceContents).Text), &out); err != nil {
+				t.Fatal(err)
+			}
+			auditIDs := []string{}
+			for _, e := range out.Events {
+				auditIDs = append(auditIDs, e.AuditID)
+			}
+			assert.Equal(t, tt.expected, auditIDs)
+
+			queries := p.Queries()
+			if assert.Len(t, queries, 1) {
+				assert.Equal(t, "default", queries[0].Namespace)
+				assert.Equal(t, []string{"pods"}, queries[0].ResourceTypes)
+				assert.Equal(t, "nginx", queries[0].ResourceName)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/mozillazg/kube-audit-mcp/pkg/toolset"
+)
+
+// SavedQueryTool is a saved query of the config. It calls query_audit_log
+// with the params of the saved query and the arguments of the call.
+type SavedQueryTool struct {
+	query *QueryAuditLogTool
+	saved toolset.SavedQuery
+}
+
+func NewSavedQueryTool(query *QueryAuditLogTool, saved toolset.SavedQuery) *SavedQueryTool {
+	return &SavedQueryTool{query: query, saved: saved}
+}
+
+func (t *SavedQueryTool) Register(s *server.MCPServer) {
+	s.AddTools(t.ServerTool())
+}
+
+func (t *SavedQueryTool) ServerTool() server.ServerTool {
+	return server.ServerTool{Tool: t.newTool(), Handler: t.handle}
+}
+
+// handle calls query_audit_log with the params of the saved query, merged
+// with the arguments of the call. Arguments that are not declared by the
+// saved query are rejected, so that the fixed params are not overridden.
+func (t *SavedQueryTool) handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var declared []string
+	for _, arg := range t.saved.Arguments {
+		declared = append(declared, arg.Name)
+	}
+	for _, name := range slices.Sorted(maps.Keys(req.GetArguments())) {
+		if req.GetArguments()[name] != nil && !slices.Contains(declared, name) {
+			return mcp.NewToolResultErrorf("unknown argument %s of saved query %s, the arguments are %v",
+				name, t.saved.Name, declared), nil
+		}
+	}
+
+	req.Params.Name = toolset.QueryAuditLog
+	req.Params.Arguments = t.saved.QueryArguments(req.GetArguments())
+	return t.query.handle(ctx, req)
+}
+
+// newTool returns the tool of the saved query. Its arguments are the
+// arguments of query_audit_log, with the params of the saved query as their
+// defaults, and the description includes the params.
+func (t *SavedQueryTool) newTool() mcp.Tool {
+	description := t.saved.Description
+	if len(t.saved.Params) > 0 {
+		params, err := json.Marshal(t.saved.Params)
+		if err == nil {
+			description += "\n\nIt queries the Kubernetes audit logs with 'query_audit_log' and the parameters " + string(params)
+			if len(t.saved.Arguments) > 0 {
+				description += ", the arguments override them."
+			} else {
+				description += "."
+			}
+		}
+	}
+
+	base := t.query.newTool()
+	tool := mcp.NewTool(t.saved.Name,
+		mcp.WithDescription(description),
+		mcp.WithTitleAnnotation(t.saved.Name),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(true),
+		mcp.WithRawOutputSchema(auditLogResultSchema),
+	)
+	for _, arg := range t.saved.Arguments {
+		property, _ := base.InputSchema.Properties[arg.Name].(map[string]any)
+		property = maps.Clone(property)
+		if property == nil {
+			property = map[string]any{}
+		}
+		if arg.Description != "" {
+			property["description"] = arg.Description
+		} else if description, ok := property["description"].(string); ok && arg.Required {
+			property["description"] = strings.TrimPrefix(description, "(Optional) ")
+		}
+		if value, ok := t.saved.Params[arg.Name]; ok {
+			property["default"] = value
+		}
+		tool.InputSchema.Properties[arg.Name] = property
+		if arg.Required && !slices.Contains(tool.InputSchema.Required, arg.Name) {
+			tool.InputSchema.Required = append(tool.InputSchema.Required, arg.Name)
+		}
+	}
+	return tool
+}
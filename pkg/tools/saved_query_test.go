@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/mozillazg/kube-audit-mcp/pkg/toolset"
+	"github.com/stretchr/testify/assert"
+)
+
+var rbacChanges = toolset.SavedQuery{
+	Name:        "rbac_changes",
+	Description: "Changes of the RBAC roles.",
+	Params: map[string]any{
+		"resource_types": []any{"roles", "rolebindings"},
+		"verbs":          []any{"create", "delete"},
+		"namespace":      "kube-system",
+		"limit":          5,
+	},
+	Arguments: []toolset.Argument{
+		{Name: "namespace"},
+		{Name: "user"},
+	},
+}
+
+func callSavedQuery(t *testing.T, tool server.ServerTool, args map[string]any) *mcp.CallToolResult {
+	t.Helper()
+	req := mcp.CallToolRequest{}
+	req.Params.Name = tool.Tool.Name
+	req.Params.Arguments = args
+	result, err := tool.Handler(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestSavedQueryTool_handle(t *testing.T) {
+	tests := []struct {
+		name              string
+		args              map[string]any
+		expectedNamespace string
+		expectedUser      string
+	}{
+		{name: "params", args: nil, expectedNamespace: "kube-system"},
+		{
+			name:              "arguments override the params",
+			args:              map[string]any{"namespace": "default", "user": "alice"},
+			expectedNamespace: "default",
+			expectedUser:      "alice",
+		},
+		{name: "null arguments", args: map[string]any{"namespace": nil}, expectedNamespace: "kube-system"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &mockProvider{}
+			cfg := newTestConfig(t, []string{"prod"}, p)
+			tool := NewSavedQueryTool(NewQueryAuditLogTool(cfg, NewSessionClusters()), rbacChanges).ServerTool()
+
+			result := callSavedQuery(t, tool, tt.args)
+			assert.False(t, result.IsError)
+			queries := p.Queries()
+			if !assert.Len(t, queries, 1) {
+				return
+			}
+			assert.Equal(t, "prod", queries[0].ClusterName)
+			assert.Equal(t, []string{"roles", "rolebindings"}, queries[0].ResourceTypes)
+			assert.Equal(t, []string{"create", "delete"}, queries[0].Verbs)
+			assert.Equal(t, 5, queries[0].Limit)
+			assert.Equal(t, tt.expectedNamespace, queries[0].Namespace)
+			assert.Equal(t, tt.expectedUser, queries[0].User)
+		})
+	}
+}
+
+func TestSavedQueryTool_handle_UndeclaredArgument(t *testing.T) {
+	p := &mockProvider{}
+	cfg := newTestConfig(t, []string{"prod"}, p)
+	tool := NewSavedQueryTool(NewQueryAuditLogTool(cfg, NewSessionClusters()), rbacChanges).ServerTool()
+
+	result := callSavedQuery(t, tool, map[string]any{"namespace": "default", "verbs": []any{"get"}})
+	assert.True(t, result.IsError)
+	assert.Equal(t, "unknown argument verbs of saved query rbac_changes, the arguments are [namespace user]",
+		result.Content[0].(mcp.TextContent).Text)
+	assert.Empty(t, p.Queries())
+}
+
+func TestNewServerTools_QueryAuditLogDisabled(t *testing.T) {
+	p := &mockProvider{}
+	cfg := newTestConfig(t, []string{"prod"}, p)
+	cfg.Tools = &toolset.Config{
+		DisableBuiltinTools: []string{toolset.QueryAuditLog},
+		SavedQueries:        []toolset.SavedQuery{rbacChanges},
+	}
+
+	tools := map[string]server.ServerTool{}
+	for _, tool := range NewServerTools(cfg, NewSessionClusters()) {
+		tools[tool.Tool.Name] = tool
+	}
+	assert.NotContains(t, tools, toolset.QueryAuditLog)
+	if !assert.Contains(t, tools, "rbac_changes") {
+		return
+	}
+
+	result := callSavedQuery(t, tools["rbac_changes"], map[string]any{"namespace": "default"})
+	assert.False(t, result.IsError)
+	queries := p.Queries()
+	if assert.Len(t, queries, 1) {
+		assert.Equal(t, "default", queries[0].Namespace)
+		assert.Equal(t, []string{"roles", "rolebindings"}, queries[0].ResourceTypes)
+	}
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/invopop/jsonschema"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The output schemas of the tools.
+var (
+	auditLogResultSchema = outputSchema(types.AuditLogResult{})
+	clustersResultSchema = outputSchema(ClustersResult{})
+	resourceTypesSchema  = outputSchema(map[string][]string{})
+)
+
+var (
+	timeType       = reflect.TypeOf(metav1.Time{})
+	microTimeType  = reflect.TypeOf(metav1.MicroTime{})
+	durationType   = reflect.TypeOf(metav1.Duration{})
+	rawObjectType  = reflect.TypeOf(runtime.Unknown{})
+	dateTimeSchema = &jsonschema.Schema{Type: "string", Format: "date-time"}
+)
+
+// outputSchema returns the JSON Schema of the structured content of a tool
+// that returns v. The Kubernetes types are described by their JSON form,
+// e.g. the request objects of the audit events are any JSON. No property is
+// required, and the objects and the arrays may be null, because the
+// audit events have no JSON tags and nil fields are encoded as null.
+func outputSchema(v any) json.RawMessage {
+	r := &jsonschema.Reflector{
+		DoNotReference:             true,
+		Anonymous:                  true,
+		AllowAdditionalProperties:  true,
+		RequiredFromJSONSchemaTags: true,
+		Mapper: func(t reflect.Type) *jsonschema.Schema {
+			switch t {
+			case timeType, microTimeType:
+				return dateTimeSchema
+			case durationType:
+				return &jsonschema.Schema{Type: "string"}
+			case rawObjectType:
+				return &jsonschema.Schema{}
+			}
+			return nil
+		},
+	}
+	schema := allowNullProperties(r.Reflect(v))
+	schema.Version = ""
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// allowNullProperties allows null for the objects and the arrays of the
+// properties of the schema, recursively.
+func allowNullProperties(s *jsonschema.Schema) *jsonschema.Schema {
+	if s.Properties != nil {
+		for pair := s.Properties.Oldest(); pair != nil; pair = pair.Next() {
+			pair.Value = allowNull(allowNullProperties(pair.Value))
+		}
+	}
+	if s.Items != nil {
+		s.Items = allowNullProperties(s.Items)
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties != jsonschema.TrueSchema &&
+		s.AdditionalProperties != jsonschema.FalseSchema {
+		s.AdditionalProperties = allowNull(allowNullProperties(s.AdditionalProperties))
+	}
+	return s
+}
+
+func allowNull(s *jsonschema.Schema) *jsonschema.Schema {
+	if s.Type != "object" && s.Type != "array" {
+		return s
+	}
+	return &jsonschema.Schema{AnyOf: []*jsonschema.Schema{s, {Type: "null"}}}
+}
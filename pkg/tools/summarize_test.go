@@ -0,0 +1,230 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	k8sauth "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	k8saudit "k8s.io/apiserver/pkg/apis/audit"
+)
+
+var summaryTime = time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+
+func newSummaryEntry(auditID, user, verb, resource, namespace string, code int32, minute int) types.AuditLogEntry {
+	e := types.AuditLogEntry{
+		AuditID:                  k8stypes.UID(auditID),
+		Verb:                     verb,
+		User:                     k8sauth.UserInfo{Username: user},
+		RequestReceivedTimestamp: metav1.NewMicroTime(summaryTime.Add(time.Duration(minute) * time.Minute)),
+	}
+	if resource != "" {
+		e.ObjectRef = &k8saudit.ObjectReference{Resource: resource, Namespace: namespace}
+	}
+	if code != 0 {
+		e.ResponseStatus = &metav1.Status{Code: code}
+	}
+	return e
+}
+
+func TestGroupEvents(t *testing.T) {
+	subresource := newSummaryEntry("a6", "alice", "create", "pods", "default", 201, 1)
+	subresource.ObjectRef.Subresource = "exec"
+	entries := []types.AuditLogEntry{
+		newSummaryEntry("a9", "bob", "get", "secrets", "default", 403, 9),
+		newSummaryEntry("a8", "alice", "patch", "deployments", "default", 200, 8),
+		newSummaryEntry("a7", "alice", "patch", "deployments", "default", 200, 7),
+		subresource,
+		newSummaryEntry("a5", "alice", "patch", "deployments", "default", 200, 5),
+		newSummaryEntry("a4", "alice", "patch", "deployments", "default", 200, 4),
+		newSummaryEntry("a3", "alice", "patch", "deployments", "kube-system", 200, 3),
+		newSummaryEntry("a2", "bob", "get", "secrets", "default", 403, 2),
+		newSummaryEntry("a1", "carol", "list", "", "", 0, 0),
+	}
+
+	groups := groupEvents(entries)
+	expected := []types.EventGroup{
+		{
+			User: "alice", Verb: "patch", Resource: "deployments", Namespace: "default", Code: 200, Count: 4,
+			FirstTime: summaryTime.Add(4 * time.Minute), LastTime: summaryTime.Add(8 * time.Minute),
+			AuditIDs: []string{"a8", "a7", "a5"},
+		},
+		{
+			User: "bob", Verb: "get", Resource: "secrets", Namespace: "default", Code: 403, Count: 2,
+			FirstTime: summaryTime.Add(2 * time.Minute), LastTime: summaryTime.Add(9 * time.Minute),
+			AuditIDs: []string{"a9", "a2"},
+		},
+		{
+			User: "alice", Verb: "create", Resource: "pods/exec", Namespace: "default", Code: 201, Count: 1,
+			FirstTime: summaryTime.Add(time.Minute), LastTime: summaryTime.Add(time.Minute),
+			AuditIDs: []string{"a6"},
+		},
+		{
+			User: "alice", Verb: "patch", Resource: "deployments", Namespace: "kube-system", Code: 200, Count: 1,
+			FirstTime: summaryTime.Add(3 * time.Minute), LastTime: summaryTime.Add(3 * time.Minute),
+			AuditIDs: []string{"a3"},
+		},
+		{
+			User: "carol", Verb: "list", Count: 1,
+			FirstTime: summaryTime, LastTime: summaryTime,
+			AuditIDs: []string{"a1"},
+		},
+	}
+	assert.Equal(t, expected, groups)
+	assert.Empty(t, groupEvents(nil))
+}
+
+func TestCitedAuditIDs(t *testing.T) {
+	groups := []types.EventGroup{
+		{AuditIDs: []string{"a8", "a7", "a5"}},
+		{AuditIDs: []string{"a9", "a2"}},
+		{AuditIDs: []string{"a1"}},
+	}
+
+	tests := []struct {
+		name     string
+		text     string
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "cited in the order of the groups",
+			text:     "- bob was denied access to secrets [a2]\n- alice patched deployments [a7, a8]",
+			limit:    10,
+			expected: []string{"a8", "a7", "a2"},
+		},
+		{
+			name:     "at most limit",
+			text:     "[a1] [a2] [a5] [a7] [a8] [a9]",
+			limit:    2,
+			expected: []string{"a8", "a7"},
+		},
+		{
+			name:     "unknown audit IDs are ignored",
+			text:     "alice did something [x1]",
+			limit:    10,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, citedAuditIDs(tt.text, groups, tt.limit))
+		})
+	}
+}
+
+func TestGroupsSummary(t *testing.T) {
+	start, end := summaryTime, summaryTime.Add(time.Hour)
+	groups := []types.EventGroup{
+		{
+			User: "alice", Verb: "patch", Resource: "deployments", Namespace: "default", Code: 200, Count: 4,
+			FirstTime: summaryTime.Add(4 * time.Minute), LastTime: summaryTime.Add(8 * time.Minute),
+			AuditIDs: []string{"a8", "a7", "a5"},
+		},
+		{
+			User: "bob", Verb: "get", Resource: "secrets", Namespace: "default", Code: 403, Count: 2,
+			FirstTime: summaryTime.Add(2 * time.Minute), LastTime: summaryTime.Add(2 * time.Minute),
+			AuditIDs: []string{"a2"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		summary  types.AuditLogSummary
+		expected string
+	}{
+		{
+			name:     "no events",
+			summary:  types.AuditLogSummary{StartTime: start, EndTime: end},
+			expected: "0 events from 2026-01-02T03:00:00Z to 2026-01-02T04:00:00Z.",
+		},
+		{
+			name: "groups",
+			summary: types.AuditLogSummary{
+				EventsSummarized: 7, StartTime: start, EndTime: end, Groups: groups, OtherEvents: 1,
+			},
+			expected: `7 events from 2026-01-02T03:00:00Z to 2026-01-02T04:00:00Z.
+The largest 2 groups of events with the same user, verb, resource, namespace and response code (2 events of them failed):
+- 4 x alice patch deployments in default (code 200), from 2026-01-02T03:04:00Z to 2026-01-02T03:08:00Z, e.g. [a8, a7, a5]
+- 2 x bob get secrets in default (code 403), at 2026-01-02T03:02:00Z, e.g. [a2]
+1 other events are in smaller groups.`,
+		},
+		{
+			name: "truncated",
+			summary: types.AuditLogSummary{
+				EventsSummarized: 4, Truncated: true, StartTime: start, EndTime: end, Groups: groups[:1],
+			},
+			expected: `4 events from 2026-01-02T03:00:00Z to 2026-01-02T04:00:00Z, the latest events of the time range, more events may match.
+The largest 1 groups of events with the same user, verb, resource, namespace and response code:
+- 4 x alice patch deployments in default (code 200), from 2026-01-02T03:04:00Z to 2026-01-02T03:08:00Z, e.g. [a8, a7, a5]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, groupsSummary(&tt.summary))
+		})
+	}
+}
+
+func TestQueryAuditLogTool_Summarize_Truncated(t *testing.T) {
+	entries := func(n int) []types.AuditLogEntry {
+		var entries []types.AuditLogEntry
+		for i := range n {
+			entries = append(entries, newSummaryEntry(fmt.Sprintf("a%d", i), "alice", "get", "pods", "default", 200, i))
+		}
+		return entries
+	}
+
+	tests := []struct {
+		name      string
+		maxEvents int
+		entries   int
+		partial   bool
+		expected  bool
+	}{
+		{name: "fewer events than the query limit", entries: 10, expected: false},
+		{name: "events of the query limit", entries: summarizeLimit, expected: true},
+		{name: "fewer events than the provider limit", maxEvents: 100, entries: 99, expected: false},
+		{name: "events of the provider limit", maxEvents: 100, entries: 100, expected: true},
+		{name: "partial results", entries: 10, partial: true, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &mockProvider{
+				result:       types.AuditLogResult{Entries: entries(tt.entries), Partial: tt.partial},
+				capabilities: provider.Capabilities{Filters: provider.AllFilters, MaxEvents: tt.maxEvents},
+			}
+			tool := NewQueryAuditLogTool(newTestConfig(t, []string{"prod"}, p), NewSessionClusters())
+			req := mcp.CallToolRequest{}
+			req.Params.Name = "query_audit_log"
+			req.Params.Arguments = map[string]any{"summarize": true}
+
+			result, err := tool.handle(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !assert.False(t, result.IsError) {
+				return
+			}
+			var out types.AuditLogResult
+			data, _ := json.Marshal(result.StructuredContent)
+			if err := json.Unmarshal(data, &out); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.entries, out.Summary.EventsSummarized)
+			assert.Equal(t, tt.expected, out.Summary.Truncated)
+			assert.Equal(t, summarizeLimit, p.Queries()[0].Limit)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+)
+
+// The text of the tool results is a short rendering of the structured
+// content, for the clients that only show text.
+
+// auditLogResultText renders the summary, if any, and the events as a
+// markdown table.
+func auditLogResultText(result types.AuditLogResult) string {
+	var b strings.Builder
+	if summary := result.Summary; summary != nil {
+		fmt.Fprintf(&b, "Summary of %d audit events of cluster %s:\n\n%s\n\n",
+			summary.EventsSummarized, result.Params.ClusterName, summary.Summary)
+	}
+	switch {
+	case len(result.Entries) == 0:
+		fmt.Fprintf(&b, "No audit events found in cluster %s.\n", result.Params.ClusterName)
+	case result.Summary != nil:
+		b.WriteString("Representative events, newest first:\n\n")
+	default:
+		fmt.Fprintf(&b, "%d audit events of cluster %s, newest first:\n\n", len(result.Entries), result.Params.ClusterName)
+	}
+	if len(result.Entries) > 0 {
+		b.WriteString("| Time | Verb | User | Object | Code | Audit ID |\n")
+		b.WriteString("|------|------|------|--------|------|----------|\n")
+		for _, e := range result.Entries {
+			user := e.User.Username
+			if e.ImpersonatedUser != nil {
+				user += fmt.Sprintf(" (impersonated user: %s)", e.ImpersonatedUser.Username)
+			}
+			var code string
+			if e.ResponseStatus != nil && e.ResponseStatus.Code != 0 {
+				code = fmt.Sprint(e.ResponseStatus.Code)
+			}
+			var ts string
+			if !e.RequestReceivedTimestamp.IsZero() {
+				ts = e.RequestReceivedTimestamp.UTC().Format(time.RFC3339)
+			}
+			writeRow(&b, ts, e.Verb, user,
+				objectText(e), code, string(e.AuditID))
+		}
+	}
+	if len(result.Redactions) > 0 {
+		fmt.Fprintf(&b, "\nSensitive fields of %d events were redacted.\n", len(result.Redactions))
+	}
+	if result.Note != "" {
+		b.WriteString("\n" + result.Note)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// objectText returns the resource and the namespace/name of the object of
+// the event, e.g. "deployments default/nginx".
+func objectText(e types.AuditLogEntry) string {
+	ref := e.ObjectRef
+	if ref == nil {
+		return e.RequestURI
+	}
+	resource := ref.Resource
+	if ref.Subresource != "" {
+		resource += "/" + ref.Subresource
+	}
+	name := ref.Name
+	if ref.Namespace != "" {
+		name = ref.Namespace + "/" + name
+	}
+	return strings.TrimSpace(resource + " " + name)
+}
+
+// clustersResultText renders the clusters as a markdown table, with the
+// filters that the providers support.
+func clustersResultText(result ClustersResult) string {
+	var b strings.Builder
+	b.WriteString("| Cluster | Provider | Alias | Status | Filters | Description |\n")
+	b.WriteString("|---------|----------|-------|--------|---------|-------------|\n")
+	for _, c := range result.Clusters {
+		var status []string
+		if c.Name == result.DefaultCluster {
+			status = append(status, "default")
+		}
+		if c.Disabled {
+			status = append(status, "disabled")
+		}
+		var filters string
+		if c.Capabilities != nil {
+			filters = strings.Join(c.Capabilities.Filters, ", ")
+		}
+		writeRow(&b, c.Name, c.Provider, strings.Join(c.Alias, ", "), strings.Join(status, ", "), filters, c.Description)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// resourceTypesText renders the resource types as a list per category.
+func resourceTypesText(resourceTypes map[string][]string) string {
+	categories := make([]string, 0, len(resourceTypes))
+	for category := range resourceTypes {
+		categories = append(categories, category)
+	}
+	slices.Sort(categories)
+
+	var b strings.Builder
+	for _, category := range categories {
+		fmt.Fprintf(&b, "- %s: %s\n", category, strings.Join(resourceTypes[category], ", "))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func writeRow(b *strings.Builder, cells ...string) {
+	b.WriteString("|")
+	for _, cell := range cells {
+		cell = strings.ReplaceAll(cell, "|", `\|`)
+		cell = strings.ReplaceAll(cell, "\n", " ")
+		b.WriteString(" " + cell + " |")
+	}
+	b.WriteString("\n")
+}
@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/mozillazg/kube-audit-mcp/pkg/toolset"
+)
+
+// NewServerTools returns all tools of the MCP server: the built-in tools
+// that are not disabled, and the saved queries. The tools are built from
+// cfg again when the config is reloaded, because the schema of the tools
+// depends on the clusters, the sessions are kept.
+func NewServerTools(cfg *config.Config, sessions *SessionClusters) []server.ServerTool {
+	query := NewQueryAuditLogTool(cfg, sessions)
+	builtins := []struct {
+		name string
+		tool server.ServerTool
+	}{
+		{toolset.QueryAuditLog, query.ServerTool()},
+		{toolset.ListCommonResourceTypes, (&ListCommonResourceTypesTool{}).ServerTool()},
+		{toolset.ListClusters, NewListClustersTool(cfg).ServerTool()},
+	}
+
+	var tools []server.ServerTool
+	for _, builtin := range builtins {
+		if cfg.Tools.Enabled(builtin.name) {
+			tools = append(tools, builtin.tool)
+		}
+	}
+	if cfg.Tools != nil {
+		for _, saved := range cfg.Tools.SavedQueries {
+			tools = append(tools, NewSavedQueryTool(query, saved).ServerTool())
+		}
+	}
+	return tools
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/config"
+	"github.com/mozillazg/kube-audit-mcp/pkg/provider"
+	"github.com/mozillazg/kube-audit-mcp/pkg/transport"
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+)
+
+// mockProvider is the provider of the clusters of newTestConfig, it records
+// the params of the queries.
+type mockProvider struct {
+	mu           sync.Mutex
+	result       types.AuditLogResult
+	err          error
+	capabilities provider.Capabilities
+	queries      []types.QueryAuditLogParams
+}
+
+func (m *mockProvider) QueryAuditLog(_ context.Context, params types.QueryAuditLogParams) (types.AuditLogResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queries = append(m.queries, params)
+	return m.result.DeepCopy(), m.err
+}
+
+func (m *mockProvider) Capabilities() provider.Capabilities {
+	return m.capabilities
+}
+
+func (m *mockProvider) Queries() []types.QueryAuditLogParams {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.queries
+}
+
+type mockProviderConfig struct {
+	ID string `json:"id"`
+}
+
+var mockProviders sync.Map
+
+func init() {
+	provider.Register(provider.Registration{
+		Name:         "tools-mock",
+		DecodeConfig: provider.DecodeConfig[mockProviderConfig],
+		New: func(config any, _ *transport.Config) (provider.Provider, error) {
+			p, _ := mockProviders.Load(config.(*mockProviderConfig).ID)
+			return p.(*mockProvider), nil
+		},
+	})
+}
+
+// newTestConfig returns an initialized config with a cluster of each
+// provider, the first cluster is the default cluster.
+func newTestConfig(t *testing.T, clusters []string, providers ...*mockProvider) *config.Config {
+	t.Helper()
+	cfg := &config.Config{DefaultCluster: clusters[0]}
+	for i, name := range clusters {
+		id := t.Name() + "/" + name
+		mockProviders.Store(id, providers[i])
+		t.Cleanup(func() { mockProviders.Delete(id) })
+		cfg.Clusters = append(cfg.Clusters, &config.Cluster{
+			Name: name,
+			Provider: config.ProviderConfig{
+				Name:    "tools-mock",
+				Options: map[string]any{"id": id},
+			},
+		})
+	}
+	if err := cfg.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
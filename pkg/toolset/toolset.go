@@ -0,0 +1,164 @@
+// Package toolset implements the tools config: the built-in tools that are
+// disabled, and the saved queries, the canned queries of query_audit_log
+// that are registered as tools of their own.
+package toolset
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+
+	"github.com/mozillazg/kube-audit-mcp/pkg/types"
+)
+
+// The built-in tools.
+const (
+	QueryAuditLog           = "query_audit_log"
+	ListClusters            = "list_clusters"
+	ListCommonResourceTypes = "list_common_resource_types"
+)
+
+// BuiltinTools are the names of the built-in tools.
+var BuiltinTools = []string{QueryAuditLog, ListClusters, ListCommonResourceTypes}
+
+// QueryArguments are the arguments of the query_audit_log tool, which are
+// the params and the arguments of the saved queries.
+var QueryArguments = []string{
+	"cluster_name", "namespace", "verbs", "resource_types", "resource_name",
+	"user", "start_time", "end_time", "limit", "summarize",
+}
+
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+type Config struct {
+	// DisableBuiltinTools are the names of the built-in tools that are not
+	// registered, e.g. to only expose the saved queries.
+	DisableBuiltinTools []string `yaml:"disable_builtin_tools,omitempty" json:"disable_builtin_tools,omitempty"`
+	// SavedQueries are registered as tools, after the built-in tools.
+	SavedQueries []SavedQuery `yaml:"saved_queries,omitempty" json:"saved_queries,omitempty"`
+}
+
+// SavedQuery is a query of query_audit_log with fixed params, registered
+// as the tool Name. The arguments of the tool override the params.
+type SavedQuery struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	// Params are the fixed arguments of query_audit_log, e.g.
+	// resource_types. Relative times, e.g. start_time 24h, are relative to
+	// the time of the call.
+	Params map[string]any `yaml:"params,omitempty" json:"params,omitempty"`
+	// Arguments are the arguments of query_audit_log that the agent may
+	// set, they default to the params.
+	Arguments []Argument `yaml:"arguments,omitempty" json:"arguments,omitempty"`
+}
+
+type Argument struct {
+	Name string `yaml:"name" json:"name"`
+	// Description replaces the description of the argument of
+	// query_audit_log.
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Required    bool   `yaml:"required,omitempty" json:"required,omitempty"`
+}
+
+// Enabled reports whether the built-in tool is enabled.
+func (c *Config) Enabled(tool string) bool {
+	return c == nil || !slices.Contains(c.DisableBuiltinTools, tool)
+}
+
+// Validate validates the disabled tools and the saved queries.
+func (c *Config) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, name := range c.DisableBuiltinTools {
+		if !slices.Contains(BuiltinTools, name) {
+			errs = append(errs, fmt.Errorf("unknown built-in tool %s in disable_builtin_tools, the built-in tools are %v", name, BuiltinTools))
+		}
+	}
+	names := map[string]bool{}
+	for _, q := range c.SavedQueries {
+		if err := q.validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if names[q.Name] {
+			errs = append(errs, fmt.Errorf("duplicate saved query %s", q.Name))
+		}
+		names[q.Name] = true
+	}
+	return errors.Join(errs...)
+}
+
+func (q *SavedQuery) validate() error {
+	if !toolNamePattern.MatchString(q.Name) {
+		return fmt.Errorf("invalid saved query name %q, it must be 1 to 64 letters, digits, underscores or hyphens", q.Name)
+	}
+	if slices.Contains(BuiltinTools, q.Name) {
+		return fmt.Errorf("saved query %s has the name of a built-in tool", q.Name)
+	}
+	if q.Description == "" {
+		return fmt.Errorf("description of saved query %s is required", q.Name)
+	}
+	for name := range q.Params {
+		if !slices.Contains(QueryArguments, name) {
+			return fmt.Errorf("unknown param %s of saved query %s, the params are %v", name, q.Name, QueryArguments)
+		}
+	}
+	if _, err := q.QueryParams(nil); err != nil {
+		return fmt.Errorf("invalid params of saved query %s: %w", q.Name, err)
+	}
+
+	args := map[string]bool{}
+	for _, arg := range q.Arguments {
+		if !slices.Contains(QueryArguments, arg.Name) {
+			return fmt.Errorf("unknown argument %s of saved query %s, the arguments are %v", arg.Name, q.Name, QueryArguments)
+		}
+		if args[arg.Name] {
+			return fmt.Errorf("duplicate argument %s of saved query %s", arg.Name, q.Name)
+		}
+		args[arg.Name] = true
+	}
+	return nil
+}
+
+// QueryArguments returns the arguments of query_audit_log: the params,
+// overridden by the declared arguments of args. The other arguments are
+// ignored, the saved query tool rejects them.
+func (q *SavedQuery) QueryArguments(args map[string]any) map[string]any {
+	merged := maps.Clone(q.Params)
+	if merged == nil {
+		merged = map[string]any{}
+	}
+	for _, arg := range q.Arguments {
+		if value, ok := args[arg.Name]; ok && value != nil {
+			merged[arg.Name] = value
+		}
+	}
+	return merged
+}
+
+// QueryParams returns the params of query_audit_log of the arguments, see
+// QueryArguments.
+func (q *SavedQuery) QueryParams(args map[string]any) (types.QueryAuditLogParams, error) {
+	var params types.QueryAuditLogParams
+	merged := q.QueryArguments(args)
+	if summarize, ok := merged["summarize"]; ok {
+		if _, ok := summarize.(bool); !ok {
+			return params, fmt.Errorf("summarize must be a boolean, got %v", summarize)
+		}
+		delete(merged, "summarize")
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return params, err
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return params, err
+	}
+	return params, nil
+}
@@ -0,0 +1,125 @@
+package toolset
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			config: `
+disable_builtin_tools: [list_common_resource_types]
+saved_queries:
+  - name: recent_rbac_changes
+    description: RBAC changes of the last day.
+    params:
+      resource_types: [roles, rolebindings]
+      start_time: 24h
+      summarize: true
+    arguments:
+      - name: namespace
+        required: true
+`,
+		},
+		{
+			name:        "unknown built-in tool",
+			config:      `disable_builtin_tools: [foo]`,
+			expectedErr: "unknown built-in tool foo in disable_builtin_tools, the built-in tools are [query_audit_log list_clusters list_common_resource_types]",
+		},
+		{
+			name:        "invalid name",
+			config:      `saved_queries: [{name: "recent changes", description: x}]`,
+			expectedErr: `invalid saved query name "recent changes", it must be 1 to 64 letters, digits, underscores or hyphens`,
+		},
+		{
+			name:        "name of a built-in tool",
+			config:      `saved_queries: [{name: list_clusters, description: x}]`,
+			expectedErr: "saved query list_clusters has the name of a built-in tool",
+		},
+		{
+			name:        "missing description",
+			config:      `saved_queries: [{name: a}]`,
+			expectedErr: "description of saved query a is required",
+		},
+		{
+			name:        "unknown param",
+			config:      `saved_queries: [{name: a, description: x, params: {audit_id: "1"}}]`,
+			expectedErr: "unknown param audit_id of saved query a, the params are [cluster_name namespace verbs resource_types resource_name user start_time end_time limit summarize]",
+		},
+		{
+			name:        "invalid param",
+			config:      `saved_queries: [{name: a, description: x, params: {verbs: get}}]`,
+			expectedErr: "invalid params of saved query a: json: cannot unmarshal string into Go struct field QueryAuditLogParams.verbs of type []string",
+		},
+		{
+			name:        "duplicate argument",
+			config:      `saved_queries: [{name: a, description: x, arguments: [{name: user}, {name: user}]}]`,
+			expectedErr: "duplicate argument user of saved query a",
+		},
+		{
+			name:        "duplicate saved query",
+			config:      `saved_queries: [{name: a, description: x}, {name: a, description: y}]`,
+			expectedErr: "duplicate saved query a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config Config
+			if err := yaml.UnmarshalStrict([]byte(tt.config), &config); err != nil {
+				t.Fatal(err)
+			}
+			err := config.Validate()
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Enabled(t *testing.T) {
+	var config *Config
+	assert.True(t, config.Enabled(QueryAuditLog))
+
+	config = &Config{DisableBuiltinTools: []string{ListClusters}}
+	assert.True(t, config.Enabled(QueryAuditLog))
+	assert.False(t, config.Enabled(ListClusters))
+}
+
+func TestSavedQuery_QueryParams(t *testing.T) {
+	q := SavedQuery{
+		Name: "recent_rbac_changes",
+		Params: map[string]any{
+			"resource_types": []any{"roles"},
+			"verbs":          []any{"create", "delete"},
+			"start_time":     "24h",
+		},
+		Arguments: []Argument{{Name: "namespace"}, {Name: "start_time"}},
+	}
+
+	params, err := q.QueryParams(map[string]any{"namespace": "default", "verbs": []any{"get"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "default", params.Namespace)
+	assert.Equal(t, []string{"create", "delete"}, params.Verbs, "verbs is not an argument")
+	assert.Equal(t, []string{"roles"}, params.ResourceTypes)
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), params.StartTime.Time, time.Minute)
+
+	params, err = q.QueryParams(map[string]any{"start_time": "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.WithinDuration(t, time.Now().Add(-time.Hour), params.StartTime.Time, time.Minute)
+	assert.Equal(t, "24h", q.Params["start_time"], "the params are not modified")
+}
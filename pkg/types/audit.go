@@ -1,6 +1,10 @@
 package types
 
-import k8saudit "k8s.io/apiserver/pkg/apis/audit"
+import (
+	"time"
+
+	k8saudit "k8s.io/apiserver/pkg/apis/audit"
+)
 
 type AuditLogEntry k8saudit.Event
 
@@ -10,4 +14,127 @@ type AuditLogResult struct {
 	ProviderQuery string              `json:"-"`
 	Params        QueryAuditLogParams `json:"-"`
 	Note          string              `json:"note"`
+	Redactions    []Redaction         `json:"redactions,omitempty"`
+	Cache         *CacheStats         `json:"cache,omitempty"`
+	Stats         *QueryStats         `json:"stats,omitempty"`
+	// Partial is true when the query did not complete in time and the
+	// entries are the results found so far.
+	Partial bool `json:"partial,omitempty"`
+	// Summary is the summary of the events in the summarize mode of
+	// query_audit_log, the entries are the representative events.
+	Summary *AuditLogSummary `json:"summary,omitempty"`
+}
+
+// AuditLogSummary summarizes the events of a query.
+type AuditLogSummary struct {
+	// Summary is the summary in natural language.
+	Summary string `json:"summary"`
+	// Method is how the summary was written, SummaryMethodSampling or
+	// SummaryMethodGroups.
+	Method string `json:"method"`
+	// EventsSummarized is the number of the events that were summarized.
+	EventsSummarized int `json:"events_summarized"`
+	// Truncated is true when the query returned the maximum number of
+	// events of the query or of the provider, or partial results, so more
+	// events may match than were summarized.
+	Truncated bool      `json:"truncated,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	// Groups are the largest groups of similar events.
+	Groups []EventGroup `json:"groups"`
+	// OtherEvents is the number of the events that are not in Groups.
+	OtherEvents int `json:"other_events,omitempty"`
+	// RepresentativeAuditIDs are the audit IDs of the events that the
+	// summary refers to, their events are the entries of the result.
+	RepresentativeAuditIDs []string `json:"representative_audit_ids"`
+}
+
+// The methods of AuditLogSummary.
+const (
+	// SummaryMethodSampling means the model of the client wrote the summary,
+	// using MCP sampling.
+	SummaryMethodSampling = "sampling"
+	// SummaryMethodGroups means the summary lists the largest groups of
+	// events, when the client does not support sampling.
+	SummaryMethodGroups = "groups"
+)
+
+// EventGroup is a group of events with the same user, verb, object type and
+// response code.
+type EventGroup struct {
+	User      string    `json:"user"`
+	Verb      string    `json:"verb"`
+	Resource  string    `json:"resource,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Code      int32     `json:"code,omitempty"`
+	Count     int       `json:"count"`
+	FirstTime time.Time `json:"first_time"`
+	LastTime  time.Time `json:"last_time"`
+	// AuditIDs are the audit IDs of a few events of the group, newest
+	// first.
+	AuditIDs []string `json:"audit_ids"`
+}
+
+// Redaction records the fields of an audit event that were redacted.
+type Redaction struct {
+	AuditID string   `json:"audit_id"`
+	Fields  []string `json:"fields"`
+}
+
+// CacheStats reports whether the result was served from the cache, and the
+// hit/miss counts of the cache.
+type CacheStats struct {
+	Hit    bool   `json:"hit"`
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// QueryStats reports how much data the provider scanned for the query.
+type QueryStats struct {
+	BytesScanned   int64 `json:"bytes_scanned,omitempty"`
+	RecordsScanned int64 `json:"records_scanned,omitempty"`
+	RecordsMatched int64 `json:"records_matched,omitempty"`
+	// Estimated is true when the numbers are estimates, e.g. when the
+	// provider only returns the number of matched records.
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// DeepCopy returns a copy of the result that shares no entries with r.
+func (r AuditLogResult) DeepCopy() AuditLogResult {
+	out := r
+	if r.Entries != nil {
+		out.Entries = make([]AuditLogEntry, len(r.Entries))
+		for i := range r.Entries {
+			event := k8saudit.Event(r.Entries[i])
+			out.Entries[i] = AuditLogEntry(*event.DeepCopy())
+		}
+	}
+	if r.Redactions != nil {
+		out.Redactions = make([]Redaction, len(r.Redactions))
+		for i, redaction := range r.Redactions {
+			redaction.Fields = append([]string(nil), redaction.Fields...)
+			out.Redactions[i] = redaction
+		}
+	}
+	if r.Cache != nil {
+		stats := *r.Cache
+		out.Cache = &stats
+	}
+	if r.Stats != nil {
+		stats := *r.Stats
+		out.Stats = &stats
+	}
+	if r.Summary != nil {
+		summary := *r.Summary
+		summary.Groups = make([]EventGroup, len(r.Summary.Groups))
+		for i, group := range r.Summary.Groups {
+			group.AuditIDs = append([]string(nil), group.AuditIDs...)
+			summary.Groups[i] = group
+		}
+		summary.RepresentativeAuditIDs = append([]string(nil), r.Summary.RepresentativeAuditIDs...)
+		out.Summary = &summary
+	}
+	out.Params.Verbs = append([]string(nil), r.Params.Verbs...)
+	out.Params.ResourceTypes = append([]string(nil), r.Params.ResourceTypes...)
+	return out
 }
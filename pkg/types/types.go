@@ -15,7 +15,11 @@ type QueryAuditLogParams struct {
 	Verbs         []string  `json:"verbs"`
 	ResourceTypes []string  `json:"resource_types"`
 	ResourceName  string    `json:"resource_name"`
-	Limit         int       `json:"limit"`
+	// AuditID matches the audit ID of the event, it is not an input of the
+	// query_audit_log tool, but is used to look up a single event. It has no
+	// JSON name, so that the tool arguments can't set it.
+	AuditID string `json:"-"`
+	Limit   int    `json:"limit"`
 }
 
 type TimeParam struct {
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadSecret reads a secret from the environment variable env, or from file
+// when env is not set. It returns an empty string when neither is
+// configured, secrets are never configured inline.
+func ReadSecret(env, file string) (string, error) {
+	switch {
+	case env != "":
+		value := os.Getenv(env)
+		if value == "" {
+			return "", fmt.Errorf("env %s is empty", env)
+		}
+		return value, nil
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("read secret file: %w", err)
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "" {
+			return "", fmt.Errorf("secret file %s is empty", file)
+		}
+		return value, nil
+	}
+	return "", nil
+}
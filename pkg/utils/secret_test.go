@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadSecret(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SECRET", "from-env")
+	t.Setenv("TEST_EMPTY_SECRET", "")
+
+	tests := []struct {
+		name        string
+		env         string
+		file        string
+		expected    string
+		expectedErr string
+	}{
+		{name: "not configured"},
+		{name: "env", env: "TEST_SECRET", expected: "from-env"},
+		{name: "env takes precedence", env: "TEST_SECRET", file: secretFile, expected: "from-env"},
+		{name: "file", file: secretFile, expected: "from-file"},
+		{name: "empty env", env: "TEST_EMPTY_SECRET", expectedErr: "env TEST_EMPTY_SECRET is empty"},
+		{name: "empty file", file: emptyFile, expectedErr: "is empty"},
+		{name: "missing file", file: filepath.Join(dir, "missing"), expectedErr: "read secret file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := ReadSecret(tt.env, tt.file)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("ReadSecret() error = %v, want %q", err, tt.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("ReadSecret() unexpected error: %v", err)
+			}
+			if value != tt.expected {
+				t.Errorf("ReadSecret() = %q, want %q", value, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Similar returns the candidates that are similar to s, case insensitively,
+// the most similar first. A candidate is similar when it is within a few
+// edits of s, or when one of them is a prefix of the other.
+func Similar(s string, candidates []string) []string {
+	type match struct {
+		candidate string
+		distance  int
+	}
+	s = strings.ToLower(s)
+	var matches []match
+	for _, candidate := range candidates {
+		c := strings.ToLower(candidate)
+		distance := Levenshtein(s, c)
+		similar := distance <= max(2, len(c)/3) ||
+			len(s) >= 3 && (strings.HasPrefix(c, s) || strings.HasPrefix(s, c))
+		if similar {
+			matches = append(matches, match{candidate, distance})
+		}
+	}
+	slices.SortFunc(matches, func(a, b match) int {
+		return cmp.Or(cmp.Compare(a.distance, b.distance), cmp.Compare(a.candidate, b.candidate))
+	})
+
+	result := make([]string, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, m.candidate)
+	}
+	return RemoveDuplicates(result)
+}
+
+// Levenshtein returns the edit distance of a and b.
+func Levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
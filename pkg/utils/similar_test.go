@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSimilar(t *testing.T) {
+	candidates := []string{"prod-us", "prod-eu", "dev", "staging"}
+	tests := []struct {
+		name     string
+		s        string
+		expected []string
+	}{
+		{name: "typo", s: "prod-uss", expected: []string{"prod-us"}},
+		{name: "prefix", s: "prod", expected: []string{"prod-eu", "prod-us"}},
+		{name: "case insensitive", s: "STAGING", expected: []string{"staging"}},
+		{name: "short prefix is not similar", s: "st", expected: []string{}},
+		{name: "not similar", s: "kube-system", expected: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Similar(tt.s, candidates); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Similar(%q) = %v, expected %v", tt.s, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"pods", "", 4},
+		{"pods", "pods", 0},
+		{"pod", "pods", 1},
+		{"deploymnets", "deployments", 2},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := Levenshtein(tt.a, tt.b); got != tt.expected {
+			t.Errorf("Levenshtein(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}